@@ -0,0 +1,226 @@
+package camera
+
+import (
+	"context"
+	"errors"
+	"github.com/austingebauer/go-ray-tracer/canvas"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/austingebauer/go-ray-tracer/world"
+	"math"
+)
+
+// adaptiveSampling holds the settings of a camera that samples pixels adaptively.
+type adaptiveSampling struct {
+	// The number of samples taken for every pixel, and for each refinement of a pixel
+	minSamples int
+	// The maximum number of samples taken for any pixel
+	maxSamples int
+	// The luminance error or contrast above which a pixel is refined
+	threshold float64
+}
+
+// pixelStats tracks the luminance of the samples taken for a pixel.
+// It uses Welford's algorithm to compute the variance in a single pass.
+type pixelStats struct {
+	count int
+	mean  float64
+	m2    float64
+}
+
+// add adds the luminance of a sample to the pixel statistics.
+func (s *pixelStats) add(luminance float64) {
+	s.count++
+	delta := luminance - s.mean
+	s.mean += delta / float64(s.count)
+	s.m2 += delta * (luminance - s.mean)
+}
+
+// standardError returns the estimated standard error of the pixel's mean luminance.
+func (s *pixelStats) standardError() float64 {
+	if s.count < 2 {
+		return 0
+	}
+
+	variance := s.m2 / float64(s.count-1)
+	return math.Sqrt(variance / float64(s.count))
+}
+
+// SetAdaptiveSampling configures the camera to sample pixels adaptively.
+//
+// Every pixel is first rendered with minSamples samples. Afterward, pixels are refined
+// minSamples at a time while the standard error of their luminance, or the difference
+// between their luminance and the luminance of a neighboring pixel, exceeds the passed
+// threshold. No pixel receives more than maxSamples samples.
+//
+// The first samples of a pixel are distributed using the camera's sampling strategy.
+// Refinements use jittered sampling in place of regular grid sampling so that they
+// don't repeat the locations of earlier samples. The number of samples per pixel
+// set using SetAntiAliasing is not used by a camera that samples adaptively.
+func (c *Camera) SetAdaptiveSampling(minSamples, maxSamples int, threshold float64) error {
+	if minSamples < 1 {
+		return errors.New("minimum samples per pixel must be at least 1")
+	}
+	if maxSamples < minSamples {
+		return errors.New("maximum samples per pixel must not be less than the minimum")
+	}
+	if threshold < 0 {
+		return errors.New("threshold must not be negative")
+	}
+
+	c.adaptive = &adaptiveSampling{
+		minSamples: minSamples,
+		maxSamples: maxSamples,
+		threshold:  threshold,
+	}
+	return nil
+}
+
+// renderAdaptive samples every pixel of the camera and then
+// refines pixels until they have converged.
+func renderAdaptive(ctx context.Context, c *Camera, w *world.World, rs *renderState) error {
+	a := c.adaptive
+	width := c.horizontalSizeInPixels
+
+	// The refinement strategy must not repeat the sample locations of a regular grid
+	refineStrategy := c.samplingStrategy
+	if refineStrategy == RegularGridSampling {
+		refineStrategy = JitteredSampling
+	}
+
+	ts := tiles(c)
+	for pass := rs.resumePass(); ; pass++ {
+		// Decide which pixels of each tile to sample in this pass before sampling any of them,
+		// so that the decision doesn't depend on the order in which pixels are sampled. A
+		// resumed pass samples the pixels that were decided on before it was interrupted.
+		include := func(idx int) bool {
+			return pass == 0 || needsRefinement(c, rs.stats, idx)
+		}
+		if resumed := rs.resumePending(pass); resumed != nil {
+			include = func(idx int) bool {
+				return resumed[idx]
+			}
+		}
+
+		var pendingTiles []tile
+		pendingPixels := 0
+		pending := make(map[int][]int)
+		for _, t := range ts {
+			for y := t.y0; y < t.y1; y++ {
+				for x := t.x0; x < t.x1; x++ {
+					idx := y*width + x
+					if include(idx) {
+						pending[t.index] = append(pending[t.index], idx)
+					}
+				}
+			}
+
+			if len(pending[t.index]) > 0 {
+				pendingTiles = append(pendingTiles, t)
+				pendingPixels += len(pending[t.index])
+			}
+		}
+
+		if len(pendingTiles) == 0 {
+			return nil
+		}
+
+		strategy := refineStrategy
+		if pass == 0 {
+			strategy = c.samplingStrategy
+		}
+
+		rs.tracker.startPass(pass, len(pendingTiles), pendingPixels)
+		rs.startPass(pass, pending)
+		err := renderTiles(ctx, c, rs, pendingTiles, func(ctx context.Context, t tile, tf *film,
+			stats []pixelStats, buf *world.Buffer) error {
+			for _, idx := range pending[t.index] {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+
+				x, y := idx%width, idx/width
+				s := &stats[t.pixelIndex(x, y)]
+
+				// Grid strategies can round the samples up past the maximum, in which case
+				// the samples that fit are placed randomly, since they can't fill a grid
+				pixelStrategy := strategy
+				samples := sampleCount(strategy, a.minSamples)
+				if remaining := a.maxSamples - s.count; samples > remaining {
+					pixelStrategy = RandomSampling
+					samples = remaining
+				}
+
+				err := samplePixel(c, w, buf, tf, s, x, y, pixelStrategy, samples,
+					newSampleRand(x, y, pass))
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = rs.takeSnapshot(c, pass)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// needsRefinement returns true if the pixel at the passed index has not reached
+// the maximum number of samples and exceeds the camera's adaptive threshold.
+func needsRefinement(c *Camera, stats []pixelStats, idx int) bool {
+	a := c.adaptive
+	s := stats[idx]
+	if s.count >= a.maxSamples {
+		return false
+	}
+
+	if s.standardError() > a.threshold {
+		return true
+	}
+
+	// Compare the pixel's luminance against its horizontal and vertical neighbors
+	x, y := idx%c.horizontalSizeInPixels, idx/c.horizontalSizeInPixels
+	neighbors := [][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}}
+	for _, n := range neighbors {
+		if n[0] < 0 || n[0] >= c.horizontalSizeInPixels ||
+			n[1] < 0 || n[1] >= c.verticalSizeInPixels {
+			continue
+		}
+
+		neighbor := stats[n[1]*c.horizontalSizeInPixels+n[0]]
+		if math.Abs(s.mean-neighbor.mean) > a.threshold {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sampleHeatmap returns a canvas showing the number of samples
+// taken for each pixel relative to the most sampled pixel.
+func sampleHeatmap(c *Camera, stats []pixelStats) *canvas.Canvas {
+	maxCount := 0
+	for _, s := range stats {
+		if s.count > maxCount {
+			maxCount = s.count
+		}
+	}
+
+	heatmap := canvas.NewCanvas(c.horizontalSizeInPixels, c.verticalSizeInPixels)
+	if maxCount == 0 {
+		return heatmap
+	}
+
+	for idx, s := range stats {
+		level := float64(s.count) / float64(maxCount)
+		heatmap.Pixels[idx/c.horizontalSizeInPixels][idx%c.horizontalSizeInPixels] =
+			*color.NewColor(level, level, level)
+	}
+
+	return heatmap
+}
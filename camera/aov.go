@@ -0,0 +1,351 @@
+package camera
+
+import (
+	"context"
+	"fmt"
+	"github.com/austingebauer/go-ray-tracer/canvas"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/austingebauer/go-ray-tracer/vector"
+	"github.com/austingebauer/go-ray-tracer/world"
+	"math"
+)
+
+// The names of the arbitrary output variables (AOVs) that a render can produce alongside its
+// image. The geometric passes, which are depth, position, normal, and object ID, describe the
+// object closest to the camera and are averaged over the samples of a pixel that hit an object.
+// Their canvases have no alpha, and pixels that no sample hit are black. The other passes are
+// colors, which are averaged over every sample of a pixel like the image, and their canvases
+// have the same alpha as the image.
+const (
+	// AOVDepth is the distance from the camera to the surface along the camera's rays.
+	AOVDepth = "depth"
+	// AOVPosition is the world-space position of the surface.
+	AOVPosition = "position"
+	// AOVNormal is the world-space shading normal of the surface, facing the camera,
+	// with its x, y, and z components in the red, green, and blue of the canvas.
+	AOVNormal = "normal"
+	// AOVAlbedo is the color of the material of the surface, without any lighting.
+	AOVAlbedo = "albedo"
+	// AOVObjectID is a 24-bit hash of the ID of the object that is closest to the center
+	// of each pixel, which is exact in 16-bit and 32-bit floating point image formats.
+	AOVObjectID = "object_id"
+	// AOVDiffuse is the light from the light source that is reflected diffusely.
+	AOVDiffuse = "diffuse"
+	// AOVIndirectDiffuse is the ambient light reflected by the surface,
+	// which stands in for indirect light in the Phong reflection model.
+	AOVIndirectDiffuse = "indirect_diffuse"
+	// AOVSpecular is the light from the light source that is reflected specularly.
+	AOVSpecular = "specular"
+	// AOVShadow is the mask of the surfaces in shadow, which is 1 in shadow and 0 in light.
+	AOVShadow = "shadow"
+	// AOVVariance is the variance of the mean of the colors of the samples of each pixel,
+	// which estimates the noise of the pixel. It's averaged over every sample of a pixel, but
+	// its canvas has no alpha. Samples aren't filtered for it, so it only estimates the noise
+	// of an image reconstructed with a box filter, but it still guides Canvas.Denoise.
+	AOVVariance = "variance"
+)
+
+// aovNames holds the names of every AOV in the order that they're listed in.
+var aovNames = []string{
+	AOVDepth,
+	AOVPosition,
+	AOVNormal,
+	AOVAlbedo,
+	AOVObjectID,
+	AOVDiffuse,
+	AOVIndirectDiffuse,
+	AOVSpecular,
+	AOVShadow,
+	AOVVariance,
+}
+
+// aovChannels holds the number of values of each AOV for a sample.
+var aovChannels = map[string]int{
+	AOVDepth:           1,
+	AOVPosition:        3,
+	AOVNormal:          3,
+	AOVAlbedo:          3,
+	AOVObjectID:        1,
+	AOVDiffuse:         3,
+	AOVIndirectDiffuse: 3,
+	AOVSpecular:        3,
+	AOVShadow:          1,
+	// The sums of the colors and of their squares
+	AOVVariance: 6,
+}
+
+// AOVNames returns the names of every AOV that a render can produce.
+func AOVNames() []string {
+	return append([]string(nil), aovNames...)
+}
+
+// SetAOVs sets the AOVs that renders by the camera produce alongside their images, which are
+// returned by RenderWithAOVs and RenderContextWithAOVs. Calling it without names produces no
+// AOVs, which is the default. Tiles rendered with RenderTile don't produce AOVs.
+func (c *Camera) SetAOVs(names ...string) error {
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if _, ok := aovChannels[name]; !ok {
+			return fmt.Errorf("unknown AOV %q", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("AOV %q is repeated", name)
+		}
+		seen[name] = true
+	}
+
+	c.aovs = nil
+	if len(names) > 0 {
+		c.aovs = append([]string(nil), names...)
+	}
+	return nil
+}
+
+// RenderWithAOVs uses the passed camera to render the passed world into a canvas, along with
+// a canvas for each AOV set using SetAOVs, keyed by its name.
+func RenderWithAOVs(c *Camera, w *world.World) (*canvas.Canvas, map[string]*canvas.Canvas, error) {
+	return RenderContextWithAOVs(context.Background(), c, w, nil)
+}
+
+// RenderContextWithAOVs uses the passed camera to render the passed world into a canvas using
+// the passed options, which may be nil, in the same way as RenderContext. It also returns a
+// canvas for each AOV set using SetAOVs, keyed by its name.
+func RenderContextWithAOVs(ctx context.Context, c *Camera, w *world.World,
+	opts *RenderOptions) (*canvas.Canvas, map[string]*canvas.Canvas, error) {
+	rs, err := renderWithState(ctx, c, w, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rs.film.toCanvas(c.outputTransform), rs.film.aov.toCanvases(rs.film), nil
+}
+
+const (
+	// The offsets of the values of a pixel of an aovFilm that precede the values of its AOVs
+	aovSamples = iota
+	aovHits
+	aovIDDistance
+	aovID
+	aovHeader
+)
+
+// aovFilm accumulates the AOVs of the samples taken by a camera. Unlike the image, the AOVs
+// aren't filtered, so each sample only contributes to the pixel that it's located in.
+type aovFilm struct {
+	// The names of the AOVs
+	names []string
+	// The offset of the values of each AOV among the values of a pixel
+	offsets []int
+	// The number of values of each pixel
+	stride int
+	// Whether the object ID AOV is among the AOVs, which the hit closest to
+	// the center of each pixel is only tracked for
+	objectIDs bool
+	// The values of each pixel, which are the number of samples taken, the number of them
+	// that hit an object, the distance from the center of the pixel of the hit closest to
+	// it and its object ID, followed by the sums of the values of each AOV
+	values []float64
+}
+
+// newAOVFilm returns a new aovFilm with the passed number of pixels for the passed AOVs,
+// or nil if there aren't any.
+func newAOVFilm(names []string, pixels int) *aovFilm {
+	if len(names) == 0 {
+		return nil
+	}
+
+	a := &aovFilm{names: names, offsets: make([]int, len(names)), stride: aovHeader}
+	for i, name := range names {
+		a.offsets[i] = a.stride
+		a.stride += aovChannels[name]
+		if name == AOVObjectID {
+			a.objectIDs = true
+		}
+	}
+	a.values = make([]float64, pixels*a.stride)
+	return a
+}
+
+// addSample adds the AOVs of the passed trace of a sample to the pixel with the passed
+// index. The distance is from the sample to the center of the pixel.
+func (a *aovFilm) addSample(idx int, result *world.TraceResult, distance float64) {
+	v := a.values[idx*a.stride : (idx+1)*a.stride]
+	v[aovSamples]++
+	for i, name := range a.names {
+		if name == AOVVariance {
+			// Samples that miss every object are black, like in the image
+			o := a.offsets[i]
+			addColorValues(v[o:], result.Color)
+			addColorValues(v[o+3:], *color.Multiply(result.Color, result.Color))
+		}
+	}
+	if !result.Hit {
+		return
+	}
+
+	if a.objectIDs && (v[aovHits] == 0 || distance < v[aovIDDistance]) {
+		v[aovIDDistance] = distance
+		v[aovID] = objectID(result)
+	}
+	v[aovHits]++
+
+	shadow := 0.0
+	if result.InShadow {
+		shadow = 1
+	}
+
+	for i, name := range a.names {
+		o := a.offsets[i]
+		switch name {
+		case AOVDepth:
+			v[o] += result.T
+		case AOVPosition:
+			v[o] += result.Point.X
+			v[o+1] += result.Point.Y
+			v[o+2] += result.Point.Z
+		case AOVNormal:
+			v[o] += result.Normal.X
+			v[o+1] += result.Normal.Y
+			v[o+2] += result.Normal.Z
+		case AOVAlbedo:
+			addColorValues(v[o:], result.Albedo)
+		case AOVDiffuse:
+			addColorValues(v[o:], result.Shading.Diffuse)
+		case AOVIndirectDiffuse:
+			addColorValues(v[o:], result.Shading.Ambient)
+		case AOVSpecular:
+			addColorValues(v[o:], result.Shading.Specular)
+		case AOVShadow:
+			v[o] += shadow
+		}
+	}
+}
+
+// addColorValues adds the components of the passed color to the first three passed values.
+func addColorValues(v []float64, c color.Color) {
+	v[0] += c.Red
+	v[1] += c.Green
+	v[2] += c.Blue
+}
+
+// mergePixel merges the pixel with the passed index of the passed aovFilm into the pixel with
+// the passed index of this aovFilm. The object ID of the hit closest to the center of the pixel
+// is kept, preferring this aovFilm's on a tie.
+func (a *aovFilm) mergePixel(dst int, other *aovFilm, src int) {
+	d := a.values[dst*a.stride : (dst+1)*a.stride]
+	s := other.values[src*other.stride : (src+1)*other.stride]
+	if s[aovHits] > 0 && (d[aovHits] == 0 || s[aovIDDistance] < d[aovIDDistance]) {
+		d[aovIDDistance] = s[aovIDDistance]
+		d[aovID] = s[aovID]
+	}
+
+	for i := range d {
+		if i != aovIDDistance && i != aovID {
+			d[i] += s[i]
+		}
+	}
+}
+
+// toCanvases returns a canvas for each AOV of the film, keyed by its name, which have the
+// width and height of the passed film. It returns nil if the aovFilm is nil.
+func (a *aovFilm) toCanvases(f *film) map[string]*canvas.Canvas {
+	if a == nil {
+		return nil
+	}
+
+	canvases := make(map[string]*canvas.Canvas, len(a.names))
+	for i, name := range a.names {
+		o := a.offsets[i]
+		geometric := name == AOVDepth || name == AOVPosition || name == AOVNormal ||
+			name == AOVObjectID
+
+		var image *canvas.Canvas
+		if geometric || name == AOVVariance {
+			image = canvas.NewCanvas(f.width, f.height)
+		} else {
+			image = canvas.NewCanvasWithAlpha(f.width, f.height)
+		}
+
+		for y := 0; y < f.height; y++ {
+			for x := 0; x < f.width; x++ {
+				v := a.values[(y*f.width+x)*a.stride:]
+				if v[aovSamples] == 0 {
+					continue
+				}
+				if geometric && v[aovHits] == 0 {
+					continue
+				}
+
+				// Geometric AOVs average over the hits, and the others over the samples
+				count := v[aovSamples]
+				if geometric {
+					count = v[aovHits]
+				} else if image.Alpha != nil {
+					image.Alpha[y][x] = v[aovHits] / v[aovSamples]
+				}
+
+				var clr color.Color
+				switch name {
+				case AOVObjectID:
+					clr = color.Color{Red: v[aovID], Green: v[aovID], Blue: v[aovID]}
+				case AOVVariance:
+					clr = color.Color{
+						Red:   meanVariance(v[o], v[o+3], count),
+						Green: meanVariance(v[o+1], v[o+4], count),
+						Blue:  meanVariance(v[o+2], v[o+5], count),
+					}
+				case AOVNormal:
+					// Normals that cancel out have no direction and are left black
+					normal := vector.NewVector(v[o], v[o+1], v[o+2])
+					if normal.Magnitude() > 0 {
+						normal.Normalize()
+						clr = color.Color{Red: normal.X, Green: normal.Y, Blue: normal.Z}
+					}
+				default:
+					if aovChannels[name] == 1 {
+						clr = color.Color{Red: v[o], Green: v[o], Blue: v[o]}
+					} else {
+						clr = color.Color{Red: v[o], Green: v[o+1], Blue: v[o+2]}
+					}
+					clr = color.Color{Red: clr.Red / count, Green: clr.Green / count,
+						Blue: clr.Blue / count}
+				}
+				image.Pixels[y][x] = clr
+			}
+		}
+
+		canvases[name] = image
+	}
+
+	return canvases
+}
+
+// meanVariance returns the variance of the mean of the passed number of values,
+// which have the passed sum and sum of squares.
+func meanVariance(sum, sumSquares, count float64) float64 {
+	mean := sum / count
+	return math.Max(sumSquares/count-mean*mean, 0) / count
+}
+
+// objectID returns the 24-bit hash of the ID of the object hit by the passed trace,
+// which is never 0 so that it differs from pixels that no sample hit. The ID is hashed
+// with 32-bit FNV-1a, which is written out so that hashing it doesn't allocate.
+func objectID(result *world.TraceResult) float64 {
+	id := uint32(2166136261)
+	for i := 0; i < len(result.Object.Id); i++ {
+		id ^= uint32(result.Object.Id[i])
+		id *= 16777619
+	}
+
+	id &= 0xffffff
+	if id == 0 {
+		id = 1
+	}
+	return float64(id)
+}
+
+// sampleDistance returns the distance from the passed location on the film to the
+// center of the pixel at (x, y).
+func sampleDistance(filmX, filmY float64, x, y int) float64 {
+	return math.Hypot(filmX-(float64(x)+0.5), filmY-(float64(y)+0.5))
+}
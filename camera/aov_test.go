@@ -0,0 +1,226 @@
+package camera
+
+import (
+	"context"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/austingebauer/go-ray-tracer/matrix"
+	"github.com/austingebauer/go-ray-tracer/point"
+	"github.com/austingebauer/go-ray-tracer/sphere"
+	"github.com/austingebauer/go-ray-tracer/vector"
+	"github.com/austingebauer/go-ray-tracer/world"
+	"github.com/stretchr/testify/assert"
+	"hash/fnv"
+	"math"
+	"testing"
+)
+
+func TestCamera_SetAOVs(t *testing.T) {
+	tests := []struct {
+		name    string
+		aovs    []string
+		wantErr bool
+	}{
+		{
+			name: "no AOVs",
+		},
+		{
+			name: "every AOV",
+			aovs: AOVNames(),
+		},
+		{
+			name: "some AOVs",
+			aovs: []string{AOVShadow, AOVDepth},
+		},
+		{
+			name:    "unknown AOV",
+			aovs:    []string{AOVDepth, "velocity"},
+			wantErr: true,
+		},
+		{
+			name:    "repeated AOV",
+			aovs:    []string{AOVNormal, AOVNormal},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCamera(10, 10, math.Pi/2)
+			err := c.SetAOVs(tt.aovs...)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, c.aovs)
+				return
+			}
+
+			assert.NoError(t, err)
+			if len(tt.aovs) == 0 {
+				assert.Nil(t, c.aovs)
+			} else {
+				assert.Equal(t, tt.aovs, c.aovs)
+			}
+		})
+	}
+}
+
+func TestRenderWithAOVs(t *testing.T) {
+	w := world.NewDefaultWorld()
+	c, err := NewCameraWithTransform(11, 11, math.Pi/2,
+		matrix.ViewTransformMatrix4(
+			*point.NewPoint(0, 0, -5),
+			*point.NewPoint(0, 0, 0),
+			*vector.NewVector(0, 1, 0)))
+	assert.NoError(t, err)
+	assert.NoError(t, c.SetTiles(4, ScanlineTileOrder))
+
+	// Without AOVs, no AOV canvases are returned
+	want, err := Render(c, w)
+	assert.NoError(t, err)
+	image, aovs, err := RenderWithAOVs(c, w)
+	assert.NoError(t, err)
+	assert.Equal(t, want, image)
+	assert.Nil(t, aovs)
+
+	// The AOVs don't change the image
+	assert.NoError(t, c.SetAOVs(AOVNames()...))
+	image, aovs, err = RenderWithAOVs(c, w)
+	assert.NoError(t, err)
+	assert.Equal(t, want, image)
+	assert.Len(t, aovs, len(AOVNames()))
+
+	// The ray through the center pixel hits the front of the outer sphere
+	assert.InDelta(t, 4, aovs[AOVDepth].Pixels[5][5].Red, 1e-9)
+	assert.True(t, color.Equals(*color.NewColor(0, 0, -1), aovs[AOVPosition].Pixels[5][5]))
+	assert.True(t, color.Equals(*color.NewColor(0, 0, -1), aovs[AOVNormal].Pixels[5][5]))
+	assert.Equal(t, w.Objects[0].Material.Color, aovs[AOVAlbedo].Pixels[5][5])
+	id := aovs[AOVObjectID].Pixels[5][5].Red
+	assert.NotZero(t, id)
+	assert.Equal(t, float64(int(id)&0xffffff), id)
+	assert.Equal(t, 0.0, aovs[AOVShadow].Pixels[5][5].Red)
+
+	// The lighting AOVs sum to the image
+	for y := 0; y < image.Height; y++ {
+		for x := 0; x < image.Width; x++ {
+			sum := color.Add(*color.Add(aovs[AOVDiffuse].Pixels[y][x],
+				aovs[AOVIndirectDiffuse].Pixels[y][x]), aovs[AOVSpecular].Pixels[y][x])
+			assert.True(t, color.Equals(image.Pixels[y][x], *sum))
+		}
+	}
+
+	// The corners miss the spheres, so geometric AOVs are black and opaque,
+	// and the others are transparent like the image
+	for _, name := range AOVNames() {
+		assert.Equal(t, color.Color{}, aovs[name].Pixels[0][0], name)
+		if name == AOVDepth || name == AOVPosition || name == AOVNormal || name == AOVObjectID ||
+			name == AOVVariance {
+			assert.Nil(t, aovs[name].Alpha, name)
+		} else {
+			assert.Equal(t, image.Alpha, aovs[name].Alpha, name)
+		}
+	}
+}
+
+func TestRenderWithAOVsObjectID(t *testing.T) {
+	w := world.NewDefaultWorld()
+	c, err := NewCameraWithTransform(21, 21, math.Pi/2,
+		matrix.ViewTransformMatrix4(
+			*point.NewPoint(0, 0, -5),
+			*point.NewPoint(0, 0, 0),
+			*vector.NewVector(0, 1, 0)))
+	assert.NoError(t, err)
+	assert.NoError(t, c.SetAntiAliasing(16, JitteredSampling, NewBoxFilter()))
+	assert.NoError(t, c.SetAOVs(AOVObjectID, AOVVariance))
+
+	// A pixel on the edge of the sphere has the ID of the sphere, even though
+	// some of its samples miss it. Its samples vary, since some of them are the
+	// black of the background, while those of the background don't vary at all.
+	image, aovs, err := RenderWithAOVs(c, w)
+	assert.NoError(t, err)
+	center := aovs[AOVObjectID].Pixels[10][10].Red
+	edges := 0
+	for y := 0; y < image.Height; y++ {
+		for x := 0; x < image.Width; x++ {
+			alpha := image.Alpha[y][x]
+			id := aovs[AOVObjectID].Pixels[y][x].Red
+			variance := aovs[AOVVariance].Pixels[y][x].Red
+			if alpha == 0 {
+				assert.Equal(t, 0.0, id)
+				assert.Equal(t, 0.0, variance)
+			} else if alpha < 1 {
+				edges++
+				assert.Equal(t, center, id)
+				assert.Greater(t, variance, 0.0)
+			}
+		}
+	}
+	assert.Greater(t, edges, 0)
+}
+
+func TestRenderResumeWithAOVs(t *testing.T) {
+	w := world.NewDefaultWorld()
+	c, err := NewCameraWithTransform(12, 8, math.Pi/2,
+		matrix.ViewTransformMatrix4(
+			*point.NewPoint(0, 0, -5),
+			*point.NewPoint(0, 0, 0),
+			*vector.NewVector(0, 1, 0)))
+	assert.NoError(t, err)
+	assert.NoError(t, c.SetAntiAliasing(4, JitteredSampling, NewBoxFilter()))
+	assert.NoError(t, c.SetTiles(4, ScanlineTileOrder))
+	assert.NoError(t, c.SetAOVs(AOVDepth, AOVObjectID, AOVDiffuse))
+
+	_, want, err := RenderWithAOVs(c, w)
+	assert.NoError(t, err)
+
+	var cp *Checkpoint
+	_, _, err = RenderContextWithAOVs(context.Background(), c, w, &RenderOptions{
+		Checkpoint: func(checkpoint *Checkpoint) error {
+			if cp == nil {
+				cp = checkpoint
+			}
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{AOVDepth, AOVObjectID, AOVDiffuse}, cp.AOVs)
+
+	// The resumed render has the same AOVs as the render that was never interrupted
+	_, aovs, err := RenderContextWithAOVs(context.Background(), c, w,
+		&RenderOptions{Resume: cp})
+	assert.NoError(t, err)
+	assert.Equal(t, want, aovs)
+
+	// A checkpoint with different AOVs can't be resumed
+	assert.NoError(t, c.SetAOVs(AOVDepth))
+	_, _, err = RenderContextWithAOVs(context.Background(), c, w, &RenderOptions{Resume: cp})
+	assert.Error(t, err)
+}
+
+func TestObjectID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+	}{
+		{
+			name: "an object with an ID",
+			id:   "floor",
+		},
+		{
+			name: "an object without an ID",
+			id:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &world.TraceResult{Hit: true, Object: sphere.NewUnitSphere(tt.id)}
+
+			// The ID is the same as the 24-bit FNV-1a hash of the object ID
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(tt.id))
+			assert.Equal(t, float64(h.Sum32()&0xffffff), objectID(result))
+
+			// Hashing the ID doesn't allocate
+			assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() {
+				objectID(result)
+			}))
+		})
+	}
+}
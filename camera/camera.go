@@ -2,13 +2,17 @@
 package camera
 
 import (
+	"context"
+	"errors"
 	"github.com/austingebauer/go-ray-tracer/canvas"
+	"github.com/austingebauer/go-ray-tracer/color"
 	"github.com/austingebauer/go-ray-tracer/matrix"
 	"github.com/austingebauer/go-ray-tracer/point"
 	"github.com/austingebauer/go-ray-tracer/ray"
 	"github.com/austingebauer/go-ray-tracer/vector"
 	"github.com/austingebauer/go-ray-tracer/world"
 	"math"
+	"time"
 )
 
 // Camera is a virtual Camera that can be moved around,
@@ -21,9 +25,9 @@ type Camera struct {
 	// An angle that describes how much the camera can see
 	fieldOfView float64
 	// A matrix describing how the world should be moved/oriented relative to the camera
-	transform *matrix.Matrix
+	transform matrix.Matrix4
 	// The inverse of the transform matrix for this camera
-	inverseTransform *matrix.Matrix
+	inverseTransform matrix.Matrix4
 	// The ratio of the horizontal size of the canvas to its vertical size
 	aspectRatio float64
 	// Half of the width of the canvas
@@ -32,34 +36,98 @@ type Camera struct {
 	halfHeight float64
 	// The size, in world-space units, of the pixels on the canvas
 	pixelSize float64
+	// The number of samples requested for each pixel
+	samplesPerPixel int
+	// How the samples taken for a pixel are distributed over its area
+	samplingStrategy SamplingStrategy
+	// The filter used to reconstruct pixels from samples
+	filter Filter
+	// The adaptive sampling settings, or nil if every pixel receives the same number of samples
+	adaptive *adaptiveSampling
+	// The radius of the lens aperture in world-space units, which is 0 for a pinhole camera
+	apertureRadius float64
+	// The distance from the camera to the plane that is in focus
+	focalDistance float64
+	// The number of blades forming a polygonal aperture, which is 0 for a circular aperture
+	apertureBlades int
+	// The angle, in radians, by which a polygonal aperture is rotated
+	apertureRotation float64
+	// The projection used to map locations on the canvas to rays
+	projection Projection
+	// The width of the view, in world-space units, of an orthographic projection
+	orthographicWidth float64
+	// The horizontal offset of the canvas of a perspective projection, in world-space
+	// units at one unit in front of the camera. Positive values shift the view to the left.
+	lensShiftX float64
+	// The distance to the left of each ray's direction that an omni-directional
+	// stereo eye casts the ray from. Negative values offset the ray to the right.
+	stereoEyeOffset float64
+	// The time at which the shutter opens
+	shutterOpen float64
+	// The time at which the shutter closes
+	shutterClose float64
+	// The width and height, in pixels, of the tiles that the image is divided into
+	tileSize int
+	// The order in which tiles are rendered
+	tileOrder TileOrder
+	// The number of goroutines that render tiles, which is 0 to use runtime.GOMAXPROCS
+	workers int
+	// The output transform of the images rendered by the camera, or nil to clamp their colors
+	outputTransform *canvas.OutputTransform
+	// The names of the AOVs that renders produce alongside their images
+	aovs []string
+	// The number of IDs for each pixel of the ID mattes that renders produce
+	// alongside their images, which is 0 if they don't produce ID mattes
+	cryptomatteRank int
+}
+
+// cameraSample is the location of a single sample on the canvas and lens of a camera.
+type cameraSample struct {
+	// The location on the canvas, measured in pixels from its top left corner
+	filmX, filmY float64
+	// The location on the lens, in the range [0, 1) along each axis
+	lensU, lensV float64
+	// The time at which the sample is taken
+	time float64
 }
 
 // NewCamera returns a new camera having the passed horizontal
 // and vertical size in pixels, and field of view angle.
 func NewCamera(horizontalSize int, verticalSize int, fieldOfView float64) *Camera {
-	return NewCameraWithTransform(horizontalSize, verticalSize, fieldOfView,
-		matrix.NewIdentityMatrix(4))
-}
-
-// NewCameraWithTransform returns a new camera having the passed horizontal
-// and vertical size in pixels, and field of view angle, and transform.
-func NewCameraWithTransform(horizontalSize int, verticalSize int, fieldOfView float64,
-	transform *matrix.Matrix) *Camera {
-
 	c := &Camera{
 		horizontalSizeInPixels: horizontalSize,
 		verticalSizeInPixels:   verticalSize,
 		fieldOfView:            fieldOfView,
-		transform:              transform,
+		transform:              matrix.NewIdentityMatrix4(),
+		inverseTransform:       matrix.NewIdentityMatrix4(),
+		samplesPerPixel:        1,
+		samplingStrategy:       RegularGridSampling,
+		filter:                 NewBoxFilter(),
+		tileSize:               DefaultTileSize,
+		tileOrder:              ScanlineTileOrder,
 	}
 	c.prepareWorldSpaceUnits()
+	c.orthographicWidth = c.halfWidth * 2
+
+	return c
+}
 
+// NewCameraWithTransform returns a new camera having the passed horizontal
+// and vertical size in pixels, and field of view angle, and transform.
+// Returns an error if the transform can't be inverted.
+func NewCameraWithTransform(horizontalSize int, verticalSize int, fieldOfView float64,
+	transform matrix.Matrix4) (*Camera, error) {
 	// Cache the inverse of the transform, which never
 	// changes and is used in rendering routines often.
-	inverseTransform, _ := matrix.Inverse(transform)
-	c.inverseTransform = inverseTransform
+	inverse, err := transform.Inverse()
+	if err != nil {
+		return nil, err
+	}
 
-	return c
+	c := NewCamera(horizontalSize, verticalSize, fieldOfView)
+	c.transform = transform
+	c.inverseTransform = inverse
+	return c, nil
 }
 
 // prepareWorldSpaceUnits sets attributes on this camera related to world space units.
@@ -91,65 +159,283 @@ func (c *Camera) prepareWorldSpaceUnits() {
 	c.pixelSize = (c.halfWidth * 2) / float64(c.horizontalSizeInPixels)
 }
 
+// SetAntiAliasing configures the number of samples that the camera takes for each
+// pixel, how they are distributed over the pixel, and the filter used to reconstruct
+// the pixel from them. Grid based sampling strategies round the number of samples
+// up to the next perfect square.
+//
+// By default, a camera takes a single sample at the center of each pixel.
+func (c *Camera) SetAntiAliasing(samplesPerPixel int, strategy SamplingStrategy,
+	filter Filter) error {
+	if samplesPerPixel < 1 {
+		return errors.New("samples per pixel must be at least 1")
+	}
+	if filter == nil {
+		return errors.New("filter must not be nil")
+	}
+
+	c.samplesPerPixel = samplesPerPixel
+	c.samplingStrategy = strategy
+	c.filter = filter
+	return nil
+}
+
+// SetShutter sets the times at which the camera's shutter opens and closes. The samples
+// for each pixel are taken at random times while the shutter is open, which blurs objects
+// that move during that interval. By default, the shutter opens and closes at time 0.
+func (c *Camera) SetShutter(open, close float64) error {
+	if close < open {
+		return errors.New("shutter must not close before it opens")
+	}
+
+	c.shutterOpen = open
+	c.shutterClose = close
+	return nil
+}
+
+// SetOutputTransform sets the output transform of the images that the camera renders,
+// which exposes, tone maps, and encodes their colors when they're written to files with
+// 8 bits per color component. The colors of the images themselves are left linear.
+// By default, the colors are clamped to the range [0, 1] when they're written.
+func (c *Camera) SetOutputTransform(transform *canvas.OutputTransform) {
+	c.outputTransform = transform
+}
+
 // RayForPixel returns a new ray that starts at the passed camera
-// and passes through the indicated (x, y) pixel on the canvas.
+// and passes through the center of the indicated (x, y) pixel on the canvas.
+//
+// For a camera with an aperture, the ray passes through the center of the lens.
+// The ray is cast at the time that the camera's shutter opens.
+// For a fisheye camera, ErrOutsideImageCircle is returned for pixels outside of the image circle.
 func RayForPixel(c *Camera, px int, py int) (*ray.Ray, error) {
-	// Compute the offset from the left edge of the canvas to the pixel's center
-	xOffset := c.pixelSize * (float64(px) + 0.5)
-	YOffset := c.pixelSize * (float64(py) + 0.5)
+	r, err := rayForSample(c, cameraSample{
+		filmX: float64(px) + 0.5,
+		filmY: float64(py) + 0.5,
+		lensU: 0.5,
+		lensV: 0.5,
+		time:  c.shutterOpen,
+	})
+	if err == nil && r == nil {
+		return nil, ErrOutsideImageCircle
+	}
 
-	// The untransformed coordinates of the pixel in world space.
-	// Note that the camera looks toward -z, so +x is to the left.
-	worldX := c.halfWidth - xOffset
-	worldY := c.halfHeight - YOffset
+	return r, err
+}
+
+// rayForSample returns a new ray that starts at the passed camera and passes
+// through the locations on the canvas and lens described by the passed sample.
+// If the camera's projection does not cover the location on the canvas,
+// then a nil ray is returned.
+func rayForSample(c *Camera, s cameraSample) (*ray.Ray, error) {
+	// Project the sample to an untransformed point on the ray and the ray's origin
+	pixelPt, originPt, ok := project(c, s)
+	if !ok {
+		return nil, nil
+	}
 
-	// Using the camera matrix, transform the canvas point and
+	// Using the camera matrix, transform the projected point and
 	// the origin, and then compute the ray's direction vector.
-	// Note that the canvas is at z=-1
-	pixelM := matrix.Multiply4x4(c.inverseTransform,
-		matrix.PointToMatrix(point.NewPoint(worldX, worldY, -1)))
-	originM := matrix.Multiply4x4(c.inverseTransform,
-		matrix.PointToMatrix(point.NewPoint(0, 0, 0)))
+	worldPixelPt := c.inverseTransform.MultiplyPoint(*pixelPt)
+	worldOriginPt := c.inverseTransform.MultiplyPoint(*originPt)
 
-	pixelPt, err := matrix.MatrixToPoint(pixelM)
+	directionVec := vector.Normalize(*point.Subtract(worldPixelPt, worldOriginPt))
+	r := ray.NewRay(worldOriginPt, *directionVec)
+	r.Time = s.time
+	return r, nil
+}
+
+// RenderOptions holds optional settings for RenderContext.
+type RenderOptions struct {
+	// Progress, if not nil, is called after each tile is rendered with the progress of the
+	// render. It is called from the goroutine that called RenderContext, one call at a time.
+	Progress func(Progress)
+
+	// Progressive renders the image in passes that each take one sample for every pixel,
+	// so that the whole image is refined gradually rather than tile by tile. The number
+	// of passes is the number of samples per pixel set using SetAntiAliasing. A camera
+	// that samples adaptively already renders in passes, so this has no effect on it.
+	Progressive bool
+
+	// Snapshot, if not nil, is called after each pass of a progressive or adaptive render
+	// with the index of the pass, starting at 0, and the image rendered so far. If it
+	// returns an error, the render stops and returns the error.
+	Snapshot func(pass int, image *canvas.Canvas) error
+
+	// Checkpoint, if not nil, is called with a checkpoint of the render after a tile is
+	// rendered, at most once every CheckpointInterval. If it returns an error, the render
+	// stops and returns the error. CheckpointToFile returns a function that saves
+	// checkpoints to a file.
+	Checkpoint func(cp *Checkpoint) error
+
+	// CheckpointInterval is the minimum time between checkpoints.
+	CheckpointInterval time.Duration
+
+	// Resume, if not nil, is a checkpoint of an earlier render of the same world using
+	// the same camera and options, which the render resumes from. The resumed render
+	// produces the same image as a render that was never interrupted.
+	Resume *Checkpoint
+}
+
+// Render uses the passed camera to render the passed world into a canvas.
+func Render(c *Camera, w *world.World) (*canvas.Canvas, error) {
+	image, _, err := RenderWithSampleHeatmap(c, w)
+	return image, err
+}
+
+// RenderContext uses the passed camera to render the passed world into a canvas using the
+// passed options, which may be nil. If the context is cancelled or its deadline passes
+// before the render completes, the render stops and the context's error is returned.
+func RenderContext(ctx context.Context, c *Camera, w *world.World,
+	opts *RenderOptions) (*canvas.Canvas, error) {
+	image, _, err := render(ctx, c, w, opts)
+	return image, err
+}
+
+// RenderWithSampleHeatmap uses the passed camera to render the passed world into a canvas.
+// It also returns a heatmap canvas showing the number of samples taken for each pixel,
+// where black is no samples and white is the largest number of samples taken.
+func RenderWithSampleHeatmap(c *Camera, w *world.World) (*canvas.Canvas, *canvas.Canvas, error) {
+	return render(context.Background(), c, w, nil)
+}
+
+// render uses the passed camera to render the passed world into a canvas using the passed
+// options, which may be nil. It also returns a heatmap of the samples taken for each pixel.
+func render(ctx context.Context, c *Camera, w *world.World,
+	opts *RenderOptions) (*canvas.Canvas, *canvas.Canvas, error) {
+	rs, err := renderWithState(ctx, c, w, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rs.film.toCanvas(c.outputTransform), sampleHeatmap(c, rs.stats), nil
+}
+
+// renderWithState uses the passed camera to render the passed world using the passed
+// options, which may be nil, and returns the state of the completed render.
+func renderWithState(ctx context.Context, c *Camera, w *world.World,
+	opts *RenderOptions) (*renderState, error) {
+	if opts == nil {
+		opts = &RenderOptions{}
+	}
+
+	rs, err := newRenderState(c, opts)
 	if err != nil {
 		return nil, err
 	}
-	originPt, err := matrix.MatrixToPoint(originM)
+
+	switch rs.mode {
+	case adaptiveRender:
+		err = renderAdaptive(ctx, c, w, rs)
+	case progressiveRender:
+		err = renderProgressive(ctx, c, w, rs)
+	default:
+		err = renderUniform(ctx, c, w, rs)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	directionVec := vector.Normalize(*point.Subtract(*pixelPt, *originPt))
-	return ray.NewRay(*originPt, *directionVec), nil
+	return rs, nil
 }
 
-// Render uses the passed camera to render the passed world into a canvas.
-func Render(c *Camera, w *world.World) (*canvas.Canvas, error) {
-	image := canvas.NewCanvas(c.horizontalSizeInPixels, c.verticalSizeInPixels)
+// renderUniform takes the same number of samples for every pixel of the camera.
+func renderUniform(ctx context.Context, c *Camera, w *world.World, rs *renderState) error {
+	ts := tiles(c)
+	rs.tracker.startPass(0, len(ts), c.horizontalSizeInPixels*c.verticalSizeInPixels)
+	rs.startPass(0, nil)
 
-	// For each pixel of the camera
-	for y := 0; y < c.verticalSizeInPixels; y++ {
-		for x := 0; x < c.horizontalSizeInPixels; x++ {
-			// Compute the ray for the current pixel
-			r, err := RayForPixel(c, x, y)
-			if err != nil {
-				return nil, err
-			}
+	return renderTiles(ctx, c, rs, ts, func(ctx context.Context, t tile, tf *film,
+		stats []pixelStats, buf *world.Buffer) error {
+		return renderUniformTile(ctx, c, w, buf, t, tf, stats)
+	})
+}
 
-			// Intersect the ray with the world to get the color at the intersection
-			c, err := world.ColorAt(w, r)
-			if err != nil {
-				return nil, err
+// renderUniformTile takes the same number of samples for every pixel of the passed tile,
+// adding them to the passed film and recording them in the passed tile statistics.
+func renderUniformTile(ctx context.Context, c *Camera, w *world.World, buf *world.Buffer,
+	t tile, tf *film, stats []pixelStats) error {
+	samples := sampleCount(c.samplingStrategy, c.samplesPerPixel)
+
+	// For each pixel of the tile
+	for y := t.y0; y < t.y1; y++ {
+		for x := t.x0; x < t.x1; x++ {
+			if err := ctx.Err(); err != nil {
+				return err
 			}
 
-			// Write the color to the canvas at the current pixel
-			err = image.WritePixel(x, y, *c)
+			err := samplePixel(c, w, buf, tf, &stats[t.pixelIndex(x, y)],
+				x, y, c.samplingStrategy, samples, newSampleRand(x, y))
 			if err != nil {
-				return nil, err
+				return err
 			}
 		}
 	}
 
-	return image, nil
+	return nil
+}
+
+// samplePixel takes n samples of the pixel at (x, y) using the passed strategy,
+// adds them to the passed film, and records their luminance in the passed stats.
+// The rays of the samples use the passed world Buffer.
+func samplePixel(c *Camera, w *world.World, buf *world.Buffer, f *film, stats *pixelStats, x, y int,
+	strategy SamplingStrategy, n int, rnd *sampleRand) error {
+	for i := 0; i < n; i++ {
+		u, v := samplePosition(strategy, i, n, rnd)
+		err := takeSample(c, w, buf, f, stats, x, y, u, v, rnd)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// takeSample takes a sample at the passed offset (u, v) within the pixel at (x, y),
+// adds it to the passed film, and records its luminance in the passed stats.
+// The ray of the sample uses the passed world Buffer.
+func takeSample(c *Camera, w *world.World, buf *world.Buffer, f *film, stats *pixelStats, x, y int,
+	u, v float64, rnd *sampleRand) error {
+	s := cameraSample{
+		filmX: float64(x) + u,
+		filmY: float64(y) + v,
+		lensU: 0.5,
+		lensV: 0.5,
+		time:  c.shutterOpen,
+	}
+	if c.apertureRadius > 0 {
+		s.lensU, s.lensV = rnd.Float64(), rnd.Float64()
+	}
+	if c.shutterClose > c.shutterOpen {
+		s.time += rnd.Float64() * (c.shutterClose - c.shutterOpen)
+	}
+
+	// Compute the ray for the current sample
+	r, err := rayForSample(c, s)
+	if err != nil {
+		return err
+	}
+
+	// Intersect the ray with the world to get the color at the intersection. Samples
+	// that miss every object, or that the camera's projection doesn't cover, are
+	// transparent black.
+	var result world.TraceResult
+	if r != nil {
+		f.rays++
+		err = world.TraceWithBuffer(w, r, buf, &result)
+		if err != nil {
+			return err
+		}
+	}
+
+	alpha := 0.0
+	if result.Hit {
+		alpha = 1
+	}
+
+	// Add the color and coverage to the film at the sample location, along with its AOVs
+	f.addSample(s.filmX, s.filmY, result.Color, alpha)
+	f.addAOVSample(s.filmX, s.filmY, &result)
+	f.addIDSample(s.filmX, s.filmY, &result)
+	stats.add(color.Luminance(result.Color))
+	return nil
 }
@@ -1,11 +1,13 @@
 package camera
 
 import (
+	"context"
 	"github.com/austingebauer/go-ray-tracer/canvas"
 	"github.com/austingebauer/go-ray-tracer/color"
 	"github.com/austingebauer/go-ray-tracer/matrix"
 	"github.com/austingebauer/go-ray-tracer/point"
 	"github.com/austingebauer/go-ray-tracer/ray"
+	"github.com/austingebauer/go-ray-tracer/sphere"
 	"github.com/austingebauer/go-ray-tracer/vector"
 	"github.com/austingebauer/go-ray-tracer/world"
 	"github.com/stretchr/testify/assert"
@@ -35,12 +37,19 @@ func TestNewCamera1(t *testing.T) {
 				horizontalSizeInPixels: 160,
 				verticalSizeInPixels:   120,
 				fieldOfView:            math.Pi / 2,
-				transform:              matrix.NewIdentityMatrix(4),
-				inverseTransform:       matrix.NewIdentityMatrix(4),
+				transform:              matrix.NewIdentityMatrix4(),
+				inverseTransform:       matrix.NewIdentityMatrix4(),
 				aspectRatio:            1.3333333333333333,
 				halfWidth:              1,
 				halfHeight:             0.75,
 				pixelSize:              0.0125,
+				samplesPerPixel:        1,
+				samplingStrategy:       RegularGridSampling,
+				filter:                 NewBoxFilter(),
+				projection:             PerspectiveProjection,
+				orthographicWidth:      2,
+				tileSize:               DefaultTileSize,
+				tileOrder:              ScanlineTileOrder,
 			},
 		},
 	}
@@ -52,6 +61,40 @@ func TestNewCamera1(t *testing.T) {
 	}
 }
 
+func TestNewCameraWithTransform(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform matrix.Matrix4
+		wantErr   bool
+	}{
+		{
+			name:      "constructing a new camera with a transform",
+			transform: matrix.NewTranslationMatrix4(0, -2, 5).RotateY(math.Pi / 4),
+		},
+		{
+			name:      "constructing a new camera with a transform that can't be inverted",
+			transform: matrix.NewScalingMatrix4(1, 0, 1),
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewCameraWithTransform(160, 120, math.Pi/2, tt.transform)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, c)
+				return
+			}
+
+			assert.NoError(t, err)
+			inverse, err := tt.transform.Inverse()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.transform, c.transform)
+			assert.Equal(t, inverse, c.inverseTransform)
+		})
+	}
+}
+
 func TestPixelSize(t *testing.T) {
 	type args struct {
 		horizontalSize int
@@ -91,6 +134,10 @@ func TestPixelSize(t *testing.T) {
 }
 
 func TestRayForPixel(t *testing.T) {
+	transformed, err := NewCameraWithTransform(201, 101, math.Pi/2,
+		matrix.NewTranslationMatrix4(0, -2, 5).RotateY(math.Pi/4))
+	assert.NoError(t, err)
+
 	type args struct {
 		c *Camera
 		x int
@@ -126,10 +173,7 @@ func TestRayForPixel(t *testing.T) {
 		{
 			name: "constructing a ray when the camera is transformed",
 			args: args{
-				c: NewCameraWithTransform(201, 101, math.Pi/2, matrix.Multiply4x4(
-					matrix.NewYRotationMatrix(math.Pi/4),
-					matrix.NewTranslationMatrix(0, -2, 5)),
-				),
+				c: transformed,
 				x: 100,
 				y: 50,
 			},
@@ -151,6 +195,13 @@ func TestRayForPixel(t *testing.T) {
 }
 
 func TestRender(t *testing.T) {
+	c, err := NewCameraWithTransform(11, 11, math.Pi/2,
+		matrix.ViewTransformMatrix4(
+			*point.NewPoint(0, 0, -5),
+			*point.NewPoint(0, 0, 0),
+			*vector.NewVector(0, 1, 0)))
+	assert.NoError(t, err)
+
 	type args struct {
 		c *Camera
 		w *world.World
@@ -164,11 +215,7 @@ func TestRender(t *testing.T) {
 		{
 			name: "rendering a world with a camera",
 			args: args{
-				c: NewCameraWithTransform(11, 11, math.Pi/2,
-					matrix.ViewTransform(
-						*point.NewPoint(0, 0, -5),
-						*point.NewPoint(0, 0, 0),
-						*vector.NewVector(0, 1, 0))),
+				c: c,
 				w: world.NewDefaultWorld(),
 			},
 		},
@@ -188,3 +235,316 @@ func TestRender(t *testing.T) {
 		})
 	}
 }
+
+func TestCamera_SetAntiAliasing(t *testing.T) {
+	type args struct {
+		samplesPerPixel int
+		strategy        SamplingStrategy
+		filter          Filter
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "anti-aliasing with jittered sampling and a mitchell filter",
+			args: args{
+				samplesPerPixel: 16,
+				strategy:        JitteredSampling,
+				filter:          NewMitchellFilter(2, 1.0/3, 1.0/3),
+			},
+			wantErr: false,
+		},
+		{
+			name: "anti-aliasing with less than one sample per pixel",
+			args: args{
+				samplesPerPixel: 0,
+				strategy:        RandomSampling,
+				filter:          NewBoxFilter(),
+			},
+			wantErr: true,
+		},
+		{
+			name: "anti-aliasing with a nil filter",
+			args: args{
+				samplesPerPixel: 4,
+				strategy:        RegularGridSampling,
+				filter:          nil,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCamera(10, 10, math.Pi/2)
+			err := c.SetAntiAliasing(tt.args.samplesPerPixel, tt.args.strategy, tt.args.filter)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Equal(t, 1, c.samplesPerPixel)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.args.samplesPerPixel, c.samplesPerPixel)
+				assert.Equal(t, tt.args.strategy, c.samplingStrategy)
+				assert.Equal(t, tt.args.filter, c.filter)
+			}
+		})
+	}
+}
+
+func TestRenderAntiAliased(t *testing.T) {
+	newCamera := func() *Camera {
+		c, err := NewCameraWithTransform(11, 11, math.Pi/2,
+			matrix.ViewTransformMatrix4(
+				*point.NewPoint(0, 0, -5),
+				*point.NewPoint(0, 0, 0),
+				*vector.NewVector(0, 1, 0)))
+		assert.NoError(t, err)
+		return c
+	}
+
+	// The center of pixel (4, 4) lies just outside of the silhouette of the
+	// outer sphere in the default world, but part of the pixel covers it.
+	aliased, err := Render(newCamera(), world.NewDefaultWorld())
+	assert.NoError(t, err)
+	aliasedColor, err := aliased.PixelAt(4, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, *color.NewColor(0, 0, 0), aliasedColor)
+
+	filters := []Filter{
+		NewBoxFilter(),
+		NewTentFilter(1),
+		NewGaussianFilter(1.5, 2),
+		NewMitchellFilter(2, 1.0/3, 1.0/3),
+	}
+	for _, filter := range filters {
+		c := newCamera()
+		assert.NoError(t, c.SetAntiAliasing(16, JitteredSampling, filter))
+		image, err := Render(c, world.NewDefaultWorld())
+		assert.NoError(t, err)
+
+		edgeColor, err := image.PixelAt(4, 4)
+		assert.NoError(t, err)
+		centerColor, err := image.PixelAt(5, 5)
+		assert.NoError(t, err)
+		assert.Greater(t, edgeColor.Red, 0.0)
+		assert.Less(t, edgeColor.Red, centerColor.Red)
+	}
+}
+
+func TestCamera_SetAdaptiveSampling(t *testing.T) {
+	type args struct {
+		minSamples int
+		maxSamples int
+		threshold  float64
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name:    "adaptive sampling between 4 and 64 samples",
+			args:    args{minSamples: 4, maxSamples: 64, threshold: 0.01},
+			wantErr: false,
+		},
+		{
+			name:    "adaptive sampling with less than one minimum sample",
+			args:    args{minSamples: 0, maxSamples: 64, threshold: 0.01},
+			wantErr: true,
+		},
+		{
+			name:    "adaptive sampling with a maximum less than the minimum",
+			args:    args{minSamples: 16, maxSamples: 4, threshold: 0.01},
+			wantErr: true,
+		},
+		{
+			name:    "adaptive sampling with a negative threshold",
+			args:    args{minSamples: 4, maxSamples: 64, threshold: -1},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCamera(10, 10, math.Pi/2)
+			err := c.SetAdaptiveSampling(tt.args.minSamples, tt.args.maxSamples, tt.args.threshold)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, c.adaptive)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, &adaptiveSampling{
+					minSamples: tt.args.minSamples,
+					maxSamples: tt.args.maxSamples,
+					threshold:  tt.args.threshold,
+				}, c.adaptive)
+			}
+		})
+	}
+}
+
+func TestRenderWithSampleHeatmap(t *testing.T) {
+	c, err := NewCameraWithTransform(11, 11, math.Pi/2,
+		matrix.ViewTransformMatrix4(
+			*point.NewPoint(0, 0, -5),
+			*point.NewPoint(0, 0, 0),
+			*vector.NewVector(0, 1, 0)))
+	assert.NoError(t, err)
+	assert.NoError(t, c.SetAntiAliasing(1, JitteredSampling, NewTentFilter(1)))
+	assert.NoError(t, c.SetAdaptiveSampling(4, 16, 0.01))
+
+	image, heatmap, err := RenderWithSampleHeatmap(c, world.NewDefaultWorld())
+	assert.NoError(t, err)
+
+	// The background far from the sphere is flat and receives only the minimum samples
+	background, err := heatmap.PixelAt(0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, *color.NewColor(0.25, 0.25, 0.25), background)
+
+	// The edge of the sphere is refined up to the maximum samples
+	edge, err := heatmap.PixelAt(4, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, *color.NewColor(1, 1, 1), edge)
+
+	edgeColor, err := image.PixelAt(4, 4)
+	assert.NoError(t, err)
+	assert.Greater(t, edgeColor.Red, 0.0)
+
+	// A camera that doesn't sample adaptively takes the same number of samples for every pixel
+	c = NewCamera(4, 4, math.Pi/2)
+	_, heatmap, err = RenderWithSampleHeatmap(c, world.NewDefaultWorld())
+	assert.NoError(t, err)
+	for y := 0; y < heatmap.Height; y++ {
+		for x := 0; x < heatmap.Width; x++ {
+			assert.Equal(t, *color.NewColor(1, 1, 1), heatmap.Pixels[y][x])
+		}
+	}
+}
+
+func TestRenderAdaptiveMaxSamples(t *testing.T) {
+	// Grid strategies round the minimum samples up past the maximum, which is still respected
+	for _, strategy := range []SamplingStrategy{RegularGridSampling, JitteredSampling,
+		RandomSampling} {
+		c, err := NewCameraWithTransform(11, 11, math.Pi/2,
+			matrix.ViewTransformMatrix4(
+				*point.NewPoint(0, 0, -5),
+				*point.NewPoint(0, 0, 0),
+				*vector.NewVector(0, 1, 0)))
+		assert.NoError(t, err)
+		assert.NoError(t, c.SetAntiAliasing(1, strategy, NewBoxFilter()))
+		assert.NoError(t, c.SetAdaptiveSampling(2, 2, 0.01))
+
+		rs, err := renderWithState(context.Background(), c, world.NewDefaultWorld(), nil)
+		assert.NoError(t, err)
+		for _, s := range rs.stats {
+			assert.Equal(t, 2, s.count)
+		}
+	}
+}
+
+func TestCamera_SetShutter(t *testing.T) {
+	c := NewCamera(10, 10, math.Pi/2)
+	assert.NoError(t, c.SetShutter(0.25, 0.75))
+	assert.Equal(t, 0.25, c.shutterOpen)
+	assert.Equal(t, 0.75, c.shutterClose)
+	assert.Error(t, c.SetShutter(1, 0))
+
+	r, err := RayForPixel(c, 5, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.25, r.Time)
+}
+
+func TestRenderMotionBlur(t *testing.T) {
+	// A sphere moves across the center of the view while the shutter is open
+	w := world.NewDefaultWorld()
+	w.Objects = w.Objects[:1]
+	assert.NoError(t, w.Objects[0].SetTransformKeyframes(
+		sphere.TransformKeyframe{Time: 0, Transform: matrix.NewTranslationMatrix4(-3, 0, 0)},
+		sphere.TransformKeyframe{Time: 1, Transform: matrix.NewTranslationMatrix4(3, 0, 0)},
+	))
+
+	c, err := NewCameraWithTransform(11, 11, math.Pi/2,
+		matrix.ViewTransformMatrix4(
+			*point.NewPoint(0, 0, -5),
+			*point.NewPoint(0, 0, 0),
+			*vector.NewVector(0, 1, 0)))
+	assert.NoError(t, err)
+	assert.NoError(t, c.SetAntiAliasing(64, JitteredSampling, NewBoxFilter()))
+
+	// With the shutter open for an instant, the sphere is sharp and off center
+	image, err := Render(c, w)
+	assert.NoError(t, err)
+	center, err := image.PixelAt(5, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, *color.NewColor(0, 0, 0), center)
+
+	// With the shutter open while the sphere moves, the sphere is smeared across the center
+	assert.NoError(t, c.SetShutter(0, 1))
+	image, err = Render(c, w)
+	assert.NoError(t, err)
+	center, err = image.PixelAt(5, 5)
+	assert.NoError(t, err)
+	assert.Greater(t, center.Red, 0.0)
+	assert.Less(t, center.Red, 0.38066)
+}
+
+func TestCamera_SetOutputTransform(t *testing.T) {
+	w := world.NewDefaultWorld()
+	c, err := NewCameraWithTransform(11, 11, math.Pi/2,
+		matrix.ViewTransformMatrix4(
+			*point.NewPoint(0, 0, -5),
+			*point.NewPoint(0, 0, 0),
+			*vector.NewVector(0, 1, 0)))
+	assert.NoError(t, err)
+
+	// By default, the rendered image has no output transform
+	image, err := Render(c, w)
+	assert.NoError(t, err)
+	assert.Nil(t, image.OutputTransform)
+	linear, err := image.PixelAt(5, 5)
+	assert.NoError(t, err)
+
+	// The output transform is attached to the image, whose colors are left linear
+	transform := canvas.NewOutputTransform()
+	c.SetOutputTransform(transform)
+	image, err = Render(c, w)
+	assert.NoError(t, err)
+	assert.Equal(t, transform, image.OutputTransform)
+	center, err := image.PixelAt(5, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, linear, center)
+}
+
+func TestRenderAlpha(t *testing.T) {
+	w := world.NewDefaultWorld()
+	c, err := NewCameraWithTransform(11, 11, math.Pi/2,
+		matrix.ViewTransformMatrix4(
+			*point.NewPoint(0, 0, -5),
+			*point.NewPoint(0, 0, 0),
+			*vector.NewVector(0, 1, 0)))
+	assert.NoError(t, err)
+	assert.NoError(t, c.SetAntiAliasing(16, RegularGridSampling, NewBoxFilter()))
+
+	image, err := Render(c, w)
+	assert.NoError(t, err)
+	assert.NotNil(t, image.Alpha)
+
+	// The sphere covers the center of the image, and the background is transparent
+	assert.Equal(t, 1.0, image.Alpha[5][5])
+	assert.Equal(t, 0.0, image.Alpha[0][0])
+	assert.Equal(t, *color.NewColor(0, 0, 0), image.Pixels[0][0])
+
+	// Pixels on the edge of the sphere are partly covered, and their colors are
+	// premultiplied by their coverage
+	edges := 0
+	for y := 0; y < image.Height; y++ {
+		for x := 0; x < image.Width; x++ {
+			alpha := image.Alpha[y][x]
+			if alpha > 0 && alpha < 1 {
+				edges++
+			}
+			assert.True(t, image.Pixels[y][x].Red <= alpha)
+		}
+	}
+	assert.Greater(t, edges, 0)
+}
@@ -0,0 +1,252 @@
+package camera
+
+import (
+	"encoding/gob"
+	"errors"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Checkpoint is the state of an interrupted render, from which the render can be resumed.
+//
+// The samples of each pixel are drawn from a random number generator that is seeded from
+// the location of the pixel and the pass being rendered, so the pass is all that is needed
+// to restore the state of the random number generators.
+type Checkpoint struct {
+	// The width and height of the image
+	Width, Height int
+	// The size of the tiles of the image
+	TileSize int
+	// Whether the render is progressive
+	Progressive bool
+	// Whether the render samples adaptively
+	Adaptive bool
+	// The pass being rendered
+	Pass int
+	// The indices of the tiles of the pass that have been rendered
+	CompletedTiles []int
+	// The indices of the pixels to sample in the pass of an adaptive render
+	PendingPixels []int
+	// The sum of the filter weighted sample colors for each pixel
+	ColorSums []color.Color
+	// The sum of the filter weights of the samples for each pixel
+	WeightSums []float64
+	// The sum of the filter weighted sample coverages for each pixel
+	AlphaSums []float64
+	// The names of the AOVs of the render
+	AOVs []string
+	// The values of the AOVs of each pixel
+	AOVValues []float64
+	// The filter weighted coverage of each pixel by the hashes of object IDs and material
+	// names, which are empty if the render doesn't produce ID mattes
+	CryptomatteObjects   []map[uint32]float64
+	CryptomatteMaterials []map[uint32]float64
+	// The object IDs and material names keyed by their hashes
+	CryptomatteObjectNames   map[uint32]string
+	CryptomatteMaterialNames map[uint32]string
+	// The number of rays cast from the camera
+	Rays int
+	// The number of samples taken for each pixel
+	SampleCounts []int
+	// The mean luminance of the samples of each pixel
+	SampleMeans []float64
+	// The sum of the squared differences from the mean luminance of the samples of each pixel
+	SampleSquaredDeviations []float64
+}
+
+// checkpoint returns a checkpoint of the render by the passed camera.
+func (rs *renderState) checkpoint(c *Camera) *Checkpoint {
+	cp := &Checkpoint{
+		Width:                   c.horizontalSizeInPixels,
+		Height:                  c.verticalSizeInPixels,
+		TileSize:                c.tileSize,
+		Progressive:             rs.mode == progressiveRender,
+		Adaptive:                rs.mode == adaptiveRender,
+		Pass:                    rs.pass,
+		CompletedTiles:          append([]int(nil), rs.completed...),
+		ColorSums:               append([]color.Color(nil), rs.film.colorSums...),
+		WeightSums:              append([]float64(nil), rs.film.weightSums...),
+		AlphaSums:               append([]float64(nil), rs.film.alphaSums...),
+		Rays:                    rs.film.rays,
+		SampleCounts:            make([]int, len(rs.stats)),
+		SampleMeans:             make([]float64, len(rs.stats)),
+		SampleSquaredDeviations: make([]float64, len(rs.stats)),
+	}
+
+	if rs.film.aov != nil {
+		cp.AOVs = append([]string(nil), rs.film.aov.names...)
+		cp.AOVValues = append([]float64(nil), rs.film.aov.values...)
+	}
+	if cf := rs.film.crypto; cf != nil {
+		cp.CryptomatteObjects = copyCoverages(cf.objects)
+		cp.CryptomatteMaterials = copyCoverages(cf.materials)
+		cp.CryptomatteObjectNames = copyNames(cf.objectNames)
+		cp.CryptomatteMaterialNames = copyNames(cf.materialNames)
+	}
+
+	if rs.pending != nil {
+		for _, t := range tiles(c) {
+			cp.PendingPixels = append(cp.PendingPixels, rs.pending[t.index]...)
+		}
+	}
+
+	for idx, s := range rs.stats {
+		cp.SampleCounts[idx] = s.count
+		cp.SampleMeans[idx] = s.mean
+		cp.SampleSquaredDeviations[idx] = s.m2
+	}
+
+	return cp
+}
+
+// restore restores the state of the render by the passed camera from the passed checkpoint.
+func (rs *renderState) restore(c *Camera, cp *Checkpoint) error {
+	if cp.Width != c.horizontalSizeInPixels || cp.Height != c.verticalSizeInPixels {
+		return errors.New("checkpoint image size does not match the camera")
+	}
+	if cp.TileSize != c.tileSize {
+		return errors.New("checkpoint tile size does not match the camera")
+	}
+	if cp.Progressive != (rs.mode == progressiveRender) || cp.Adaptive != (rs.mode == adaptiveRender) {
+		return errors.New("checkpoint sampling does not match the render")
+	}
+
+	pixels := c.horizontalSizeInPixels * c.verticalSizeInPixels
+	if len(cp.ColorSums) != pixels || len(cp.WeightSums) != pixels ||
+		len(cp.SampleCounts) != pixels || len(cp.SampleMeans) != pixels ||
+		len(cp.SampleSquaredDeviations) != pixels ||
+		len(cp.AlphaSums) != pixels {
+		return errors.New("checkpoint buffers do not match the image size")
+	}
+	if !equalStrings(cp.AOVs, c.aovs) {
+		return errors.New("checkpoint AOVs do not match the camera")
+	}
+	if rs.film.aov != nil && len(cp.AOVValues) != len(rs.film.aov.values) {
+		return errors.New("checkpoint AOV values do not match the image size")
+	}
+	if (rs.film.crypto != nil) != (len(cp.CryptomatteObjects) > 0) {
+		return errors.New("checkpoint ID mattes do not match the camera")
+	}
+	if rs.film.crypto != nil &&
+		(len(cp.CryptomatteObjects) != pixels || len(cp.CryptomatteMaterials) != pixels) {
+		return errors.New("checkpoint ID mattes do not match the image size")
+	}
+
+	copy(rs.film.colorSums, cp.ColorSums)
+	copy(rs.film.weightSums, cp.WeightSums)
+	copy(rs.film.alphaSums, cp.AlphaSums)
+	if rs.film.aov != nil {
+		copy(rs.film.aov.values, cp.AOVValues)
+	}
+	if cf := rs.film.crypto; cf != nil {
+		cf.objects = copyCoverages(cp.CryptomatteObjects)
+		cf.materials = copyCoverages(cp.CryptomatteMaterials)
+		cf.objectNames = copyNames(cp.CryptomatteObjectNames)
+		cf.materialNames = copyNames(cp.CryptomatteMaterialNames)
+	}
+	rs.film.rays = cp.Rays
+	for idx := range rs.stats {
+		rs.stats[idx] = pixelStats{
+			count: cp.SampleCounts[idx],
+			mean:  cp.SampleMeans[idx],
+			m2:    cp.SampleSquaredDeviations[idx],
+		}
+	}
+
+	rs.resume = cp
+	return nil
+}
+
+// equalStrings returns true if the passed slices hold the same strings in the same order.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// copyCoverages returns a copy of the passed coverages of pixels by ID hashes.
+func copyCoverages(coverages []map[uint32]float64) []map[uint32]float64 {
+	copied := make([]map[uint32]float64, len(coverages))
+	for idx, coverage := range coverages {
+		if len(coverage) == 0 {
+			continue
+		}
+
+		copied[idx] = make(map[uint32]float64, len(coverage))
+		for hash, weight := range coverage {
+			copied[idx][hash] = weight
+		}
+	}
+
+	return copied
+}
+
+// copyNames returns a copy of the passed names keyed by their hashes.
+func copyNames(names map[uint32]string) map[uint32]string {
+	copied := make(map[uint32]string, len(names))
+	for hash, name := range names {
+		copied[hash] = name
+	}
+
+	return copied
+}
+
+// WriteCheckpoint writes the passed checkpoint to the passed writer.
+func WriteCheckpoint(w io.Writer, cp *Checkpoint) error {
+	return gob.NewEncoder(w).Encode(cp)
+}
+
+// ReadCheckpoint reads a checkpoint written by WriteCheckpoint from the passed reader.
+func ReadCheckpoint(r io.Reader) (*Checkpoint, error) {
+	cp := &Checkpoint{}
+	err := gob.NewDecoder(r).Decode(cp)
+	if err != nil {
+		return nil, err
+	}
+
+	return cp, nil
+}
+
+// LoadCheckpoint reads a checkpoint from the file at the passed path.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadCheckpoint(file)
+}
+
+// CheckpointToFile returns a function for RenderOptions.Checkpoint that saves checkpoints to
+// the file at the passed path. Each checkpoint is written to a temporary file that replaces
+// the file at the path once it is complete, so an interruption never corrupts the file.
+func CheckpointToFile(path string) func(cp *Checkpoint) error {
+	return func(cp *Checkpoint) error {
+		file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+		if err != nil {
+			return err
+		}
+
+		err = WriteCheckpoint(file, cp)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(file.Name())
+			return err
+		}
+
+		return os.Rename(file.Name(), path)
+	}
+}
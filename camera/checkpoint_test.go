@@ -0,0 +1,192 @@
+package camera
+
+import (
+	"bytes"
+	"context"
+	"github.com/austingebauer/go-ray-tracer/matrix"
+	"github.com/austingebauer/go-ray-tracer/point"
+	"github.com/austingebauer/go-ray-tracer/vector"
+	"github.com/austingebauer/go-ray-tracer/world"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderResume(t *testing.T) {
+	newTestCamera := func(configure func(c *Camera)) *Camera {
+		c, err := NewCameraWithTransform(20, 12, math.Pi/2,
+			matrix.ViewTransformMatrix4(
+				*point.NewPoint(0, 0, -5),
+				*point.NewPoint(0, 0, 0),
+				*vector.NewVector(0, 1, 0)))
+		assert.NoError(t, err)
+		assert.NoError(t, c.SetAntiAliasing(4, JitteredSampling, NewTentFilter(1.5)))
+		assert.NoError(t, c.SetTiles(4, HilbertTileOrder))
+		assert.NoError(t, c.SetWorkers(3))
+		configure(c)
+		return c
+	}
+
+	tests := []struct {
+		name        string
+		progressive bool
+		configure   func(c *Camera)
+	}{
+		{
+			name:      "uniform render",
+			configure: func(c *Camera) {},
+		},
+		{
+			name:        "progressive render",
+			progressive: true,
+			configure:   func(c *Camera) {},
+		},
+		{
+			name: "adaptive render",
+			configure: func(c *Camera) {
+				assert.NoError(t, c.SetAdaptiveSampling(2, 10, 0.01))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := world.NewDefaultWorld()
+
+			// Render without interruption
+			want, wantHeatmap, err := render(context.Background(), newTestCamera(tt.configure), w,
+				&RenderOptions{Progressive: tt.progressive})
+			assert.NoError(t, err)
+
+			// Interrupt the render after a number of checkpoints, and then resume it from
+			// the last checkpoint, which is written and read back as it would be from a file.
+			for _, interruptAfter := range []int{1, 7, 14} {
+				ctx, cancel := context.WithCancel(context.Background())
+				var saved []byte
+				checkpoints := 0
+				_, _, err := render(ctx, newTestCamera(tt.configure), w, &RenderOptions{
+					Progressive: tt.progressive,
+					Checkpoint: func(cp *Checkpoint) error {
+						var buf bytes.Buffer
+						assert.NoError(t, WriteCheckpoint(&buf, cp))
+						saved = buf.Bytes()
+
+						checkpoints++
+						if checkpoints == interruptAfter {
+							cancel()
+						}
+						return nil
+					},
+				})
+				cancel()
+				assert.Equal(t, context.Canceled, err)
+
+				cp, err := ReadCheckpoint(bytes.NewReader(saved))
+				assert.NoError(t, err)
+
+				got, gotHeatmap, err := render(context.Background(), newTestCamera(tt.configure), w,
+					&RenderOptions{Progressive: tt.progressive, Resume: cp})
+				assert.NoError(t, err)
+				assert.Equal(t, want, got)
+				assert.Equal(t, wantHeatmap, gotHeatmap)
+			}
+		})
+	}
+}
+
+func TestRenderResumeMismatch(t *testing.T) {
+	w := world.NewDefaultWorld()
+	c := NewCamera(8, 8, math.Pi/2)
+
+	var cp *Checkpoint
+	_, err := RenderContext(context.Background(), c, w, &RenderOptions{
+		Checkpoint: func(checkpoint *Checkpoint) error {
+			cp = checkpoint
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+
+	// Checkpoints taken before renders had alpha have no alpha sums
+	withoutAlpha := *cp
+	withoutAlpha.AlphaSums = nil
+	fewerAlpha := *cp
+	fewerAlpha.AlphaSums = cp.AlphaSums[1:]
+
+	tests := []struct {
+		name   string
+		camera *Camera
+		opts   *RenderOptions
+	}{
+		{
+			name:   "different image size",
+			camera: NewCamera(8, 9, math.Pi/2),
+			opts:   &RenderOptions{Resume: cp},
+		},
+		{
+			name: "different tile size",
+			camera: func() *Camera {
+				c := NewCamera(8, 8, math.Pi/2)
+				assert.NoError(t, c.SetTiles(4, ScanlineTileOrder))
+				return c
+			}(),
+			opts: &RenderOptions{Resume: cp},
+		},
+		{
+			name:   "different sampling",
+			camera: NewCamera(8, 8, math.Pi/2),
+			opts:   &RenderOptions{Resume: cp, Progressive: true},
+		},
+		{
+			name:   "no alpha sums",
+			camera: NewCamera(8, 8, math.Pi/2),
+			opts:   &RenderOptions{Resume: &withoutAlpha},
+		},
+		{
+			name:   "fewer alpha sums than pixels",
+			camera: NewCamera(8, 8, math.Pi/2),
+			opts:   &RenderOptions{Resume: &fewerAlpha},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			image, err := RenderContext(context.Background(), tt.camera, w, tt.opts)
+			assert.Error(t, err)
+			assert.Nil(t, image)
+		})
+	}
+}
+
+func TestCheckpointToFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "render.checkpoint")
+	w := world.NewDefaultWorld()
+	c := NewCamera(8, 8, math.Pi/2)
+	assert.NoError(t, c.SetTiles(4, ScanlineTileOrder))
+
+	want, err := RenderContext(context.Background(), c, w, &RenderOptions{
+		Checkpoint: CheckpointToFile(path),
+	})
+	assert.NoError(t, err)
+
+	// The file holds the checkpoint taken after the last tile, and no temporary files remain
+	cp, err := LoadCheckpoint(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3}, cp.CompletedTiles)
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(files))
+
+	// Resuming from a completed render produces the same image
+	got, err := RenderContext(context.Background(), c, w, &RenderOptions{Resume: cp})
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	_, err = LoadCheckpoint(filepath.Join(dir, "missing.checkpoint"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,400 @@
+package camera
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/austingebauer/go-ray-tracer/canvas"
+	"github.com/austingebauer/go-ray-tracer/maths"
+	"github.com/austingebauer/go-ray-tracer/world"
+	"io"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const (
+	// DefaultCryptomatteRank is the number of IDs that Cryptomatte mattes usually store for
+	// each pixel, which is enough for the anti-aliased edges between a few objects.
+	DefaultCryptomatteRank = 6
+
+	// CryptomatteObjectLayer is the name of the layer of ID mattes keyed by the IDs of objects.
+	CryptomatteObjectLayer = "CryptoObject"
+	// CryptomatteMaterialLayer is the name of the layer of ID mattes keyed by the names of the
+	// materials of objects. Materials without a name are keyed by unnamedMaterial.
+	CryptomatteMaterialLayer = "CryptoMaterial"
+
+	// unnamedMaterial is the name that materials without a name are keyed by in ID mattes.
+	unnamedMaterial = "default"
+)
+
+// SetCryptomatte sets the rank of the Cryptomatte ID mattes that renders by the camera produce
+// alongside their images, which are returned by RenderWithCryptomatte and
+// RenderContextWithCryptomatte. The rank is the largest number of IDs stored for each pixel,
+// ranked by their coverage of the pixel. A rank of 0 produces no ID mattes, which is the
+// default. Tiles rendered with RenderTile don't produce ID mattes.
+func (c *Camera) SetCryptomatte(rank int) error {
+	if rank < 0 {
+		return errors.New("cryptomatte rank must not be negative")
+	}
+
+	c.cryptomatteRank = rank
+	return nil
+}
+
+// RenderWithCryptomatte uses the passed camera to render the passed world into a canvas, along
+// with the ID mattes of the render, which are nil unless they're enabled using SetCryptomatte.
+func RenderWithCryptomatte(c *Camera, w *world.World) (*canvas.Canvas, *Cryptomatte, error) {
+	return RenderContextWithCryptomatte(context.Background(), c, w, nil)
+}
+
+// RenderContextWithCryptomatte uses the passed camera to render the passed world into a canvas
+// using the passed options, which may be nil, in the same way as RenderContext. It also returns
+// the ID mattes of the render, which are nil unless they're enabled using SetCryptomatte.
+func RenderContextWithCryptomatte(ctx context.Context, c *Camera, w *world.World,
+	opts *RenderOptions) (*canvas.Canvas, *Cryptomatte, error) {
+	rs, err := renderWithState(ctx, c, w, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rs.film.toCanvas(c.outputTransform), rs.film.crypto.toCryptomatte(rs.film,
+		c.cryptomatteRank), nil
+}
+
+// Cryptomatte holds the ID mattes of a render in the layout of the Cryptomatte standard, which
+// compositors use to isolate objects and materials after the render without re-rendering.
+type Cryptomatte struct {
+	Width  int
+	Height int
+	// Rank is the largest number of IDs stored for each pixel
+	Rank int
+	// Layers holds the layer keyed by objects, followed by the layer keyed by materials
+	Layers []CryptomatteLayer
+}
+
+// CryptomatteLayer is a layer of ID mattes, which are keyed by the hashes of names.
+type CryptomatteLayer struct {
+	// Name is the name of the layer, such as CryptomatteObjectLayer
+	Name string
+	// Manifest maps each name in the layer to its hash
+	Manifest map[string]uint32
+	// Pixels holds the IDs that cover each pixel, row by row from the top left pixel,
+	// which are ranked from the largest coverage to the smallest
+	Pixels [][]CryptomatteID
+}
+
+// CryptomatteID is the hash of a name and its coverage of a pixel.
+type CryptomatteID struct {
+	// Hash is the hash of the name, which is also the bits of its ID as a float32
+	Hash uint32
+	// Coverage is the fraction of the pixel, weighted by the filter of the camera,
+	// that is covered by the name
+	Coverage float64
+}
+
+// Layer returns the layer of the ID mattes with the passed name, or nil if there isn't one.
+func (m *Cryptomatte) Layer(name string) *CryptomatteLayer {
+	for i := range m.Layers {
+		if m.Layers[i].Name == name {
+			return &m.Layers[i]
+		}
+	}
+
+	return nil
+}
+
+// Matte returns a matte of the passed names in the layer, with the passed width and height,
+// where the value of each pixel is its coverage by the names.
+func (l *CryptomatteLayer) Matte(width, height int, names ...string) *canvas.Canvas {
+	selected := make(map[uint32]bool, len(names))
+	for _, name := range names {
+		selected[CryptomatteHash(name)] = true
+	}
+
+	matte := canvas.NewCanvas(width, height)
+	for idx, ids := range l.Pixels {
+		coverage := 0.0
+		for _, id := range ids {
+			if selected[id.Hash] {
+				coverage += id.Coverage
+			}
+		}
+
+		pixel := &matte.Pixels[idx/width][idx%width]
+		pixel.Red, pixel.Green, pixel.Blue = coverage, coverage, coverage
+	}
+
+	return matte
+}
+
+// EXRImage returns the ID mattes as an OpenEXR image in the layout of the Cryptomatte standard.
+// Each layer has a channel group for each pair of ranks, named after the layer and the index
+// of the group, such as CryptoObject00. Its R and B channels hold the IDs of the pair, and its
+// G and A channels hold their coverage. The manifest of each layer is in the metadata.
+func (m *Cryptomatte) EXRImage() *canvas.EXRImage {
+	img := &canvas.EXRImage{
+		Width:      m.Width,
+		Height:     m.Height,
+		Attributes: map[string]string{},
+	}
+
+	groups := (m.Rank + 1) / 2
+	for _, layer := range m.Layers {
+		for group := 0; group < groups; group++ {
+			prefix := fmt.Sprintf("%s%02d.", layer.Name, group)
+			channels := make([]canvas.EXRChannel, 4)
+			for i, suffix := range []string{"R", "G", "B", "A"} {
+				channels[i] = canvas.EXRChannel{
+					Name:   prefix + suffix,
+					Values: make([]float32, m.Width*m.Height),
+				}
+			}
+
+			for idx, ids := range layer.Pixels {
+				for i := 0; i < 2; i++ {
+					rank := group*2 + i
+					if rank >= len(ids) {
+						break
+					}
+					channels[i*2].Values[idx] = math.Float32frombits(ids[rank].Hash)
+					channels[i*2+1].Values[idx] = float32(ids[rank].Coverage)
+				}
+			}
+
+			img.Channels = append(img.Channels, channels...)
+		}
+
+		// The metadata of the layer is keyed by the first 7 hex digits of the hash of its name
+		manifest := make(map[string]string, len(layer.Manifest))
+		for name, hash := range layer.Manifest {
+			manifest[name] = fmt.Sprintf("%08x", hash)
+		}
+		manifestJSON, _ := json.Marshal(manifest)
+
+		key := fmt.Sprintf("cryptomatte/%07x/", murmurHash3(layer.Name)>>4)
+		img.Attributes[key+"name"] = layer.Name
+		img.Attributes[key+"hash"] = "MurmurHash3_32"
+		img.Attributes[key+"conversion"] = "uint32_to_float32"
+		img.Attributes[key+"manifest"] = string(manifestJSON)
+	}
+
+	return img
+}
+
+// WriteEXR writes the ID mattes to the passed writer as an OpenEXR file in the layout of
+// the Cryptomatte standard, with the passed compression. The values are written as 32-bit
+// floats, which the IDs need to be exact.
+func (m *Cryptomatte) WriteEXR(writer io.Writer, compression canvas.EXRCompression) error {
+	return m.EXRImage().WriteEXR(writer, canvas.EXRFloat, compression)
+}
+
+// CryptomatteHash returns the hash of the passed name in ID mattes, which is the MurmurHash3
+// of the name, adjusted so that it is the bits of a finite, normal float32.
+func CryptomatteHash(name string) uint32 {
+	hash := murmurHash3(name)
+
+	// Flip a bit of the exponent of hashes that would be denormal, infinite, or NaN
+	exponent := hash >> 23 & 0xff
+	if exponent == 0 || exponent == 0xff {
+		hash ^= 1 << 23
+	}
+
+	return hash
+}
+
+// murmurHash3 returns the 32-bit x86 variant of the MurmurHash3 of the passed string,
+// with a seed of 0.
+func murmurHash3(s string) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+
+	data := []byte(s)
+	var h uint32
+	blocks := len(data) / 4
+	for i := 0; i < blocks; i++ {
+		k := binary.LittleEndian.Uint32(data[i*4:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	var k uint32
+	tail := data[blocks*4:]
+	switch len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+// cryptoFilm accumulates the coverage of each pixel by the objects and materials hit by
+// the samples taken by a camera, which is weighted by the filter of the camera like the image.
+type cryptoFilm struct {
+	// The filter weighted coverage of each pixel by the hashes of the IDs of objects
+	objects []map[uint32]float64
+	// The filter weighted coverage of each pixel by the hashes of the names of materials
+	materials []map[uint32]float64
+	// The IDs of the objects and names of the materials keyed by their hashes
+	objectNames   map[uint32]string
+	materialNames map[uint32]string
+}
+
+// newCryptoFilm returns a new cryptoFilm with the passed number of pixels,
+// or nil if the passed rank is 0.
+func newCryptoFilm(rank, pixels int) *cryptoFilm {
+	if rank == 0 {
+		return nil
+	}
+
+	return &cryptoFilm{
+		objects:       make([]map[uint32]float64, pixels),
+		materials:     make([]map[uint32]float64, pixels),
+		objectNames:   map[uint32]string{},
+		materialNames: map[uint32]string{},
+	}
+}
+
+// hashes returns the hashes of the object and material hit by the passed trace,
+// recording their names.
+func (cf *cryptoFilm) hashes(result *world.TraceResult) (uint32, uint32) {
+	objectName := result.Object.Id
+	materialName := result.Object.Material.Name
+	if materialName == "" {
+		materialName = unnamedMaterial
+	}
+
+	object := CryptomatteHash(objectName)
+	if _, ok := cf.objectNames[object]; !ok {
+		cf.objectNames[object] = objectName
+	}
+	material := CryptomatteHash(materialName)
+	if _, ok := cf.materialNames[material]; !ok {
+		cf.materialNames[material] = materialName
+	}
+
+	return object, material
+}
+
+// add adds the passed filter weight of a sample that hit the passed object
+// and material hashes to the pixel with the passed index.
+func (cf *cryptoFilm) add(idx int, object, material uint32, weight float64) {
+	if cf.objects[idx] == nil {
+		cf.objects[idx] = map[uint32]float64{}
+		cf.materials[idx] = map[uint32]float64{}
+	}
+
+	cf.objects[idx][object] += weight
+	cf.materials[idx][material] += weight
+}
+
+// mergePixel merges the pixel with the passed index of the passed cryptoFilm
+// into the pixel with the passed index of this cryptoFilm.
+func (cf *cryptoFilm) mergePixel(dst int, other *cryptoFilm, src int) {
+	if len(other.objects[src]) == 0 {
+		return
+	}
+	if cf.objects[dst] == nil {
+		cf.objects[dst] = map[uint32]float64{}
+		cf.materials[dst] = map[uint32]float64{}
+	}
+	for object, weight := range other.objects[src] {
+		cf.objects[dst][object] += weight
+	}
+	for material, weight := range other.materials[src] {
+		cf.materials[dst][material] += weight
+	}
+}
+
+// mergeNames merges the names of the passed cryptoFilm into this cryptoFilm.
+func (cf *cryptoFilm) mergeNames(other *cryptoFilm) {
+	for hash, name := range other.objectNames {
+		cf.objectNames[hash] = name
+	}
+	for hash, name := range other.materialNames {
+		cf.materialNames[hash] = name
+	}
+}
+
+// toCryptomatte returns the ID mattes of the passed film with the passed rank,
+// or nil if the cryptoFilm is nil.
+func (cf *cryptoFilm) toCryptomatte(f *film, rank int) *Cryptomatte {
+	if cf == nil {
+		return nil
+	}
+
+	return &Cryptomatte{
+		Width:  f.width,
+		Height: f.height,
+		Rank:   rank,
+		Layers: []CryptomatteLayer{
+			cryptomatteLayer(CryptomatteObjectLayer, cf.objects, cf.objectNames, f.weightSums, rank),
+			cryptomatteLayer(CryptomatteMaterialLayer, cf.materials, cf.materialNames,
+				f.weightSums, rank),
+		},
+	}
+}
+
+// cryptomatteLayer returns a layer of ID mattes with the passed name, whose pixels have the
+// passed coverages and weight sums, keeping the passed rank of IDs with the largest coverage.
+func cryptomatteLayer(name string, coverages []map[uint32]float64, names map[uint32]string,
+	weightSums []float64, rank int) CryptomatteLayer {
+	layer := CryptomatteLayer{
+		Name:     name,
+		Manifest: make(map[string]uint32, len(names)),
+		Pixels:   make([][]CryptomatteID, len(coverages)),
+	}
+	for hash, name := range names {
+		layer.Manifest[name] = hash
+	}
+
+	for idx, coverage := range coverages {
+		if len(coverage) == 0 || weightSums[idx] <= maths.Epsilon {
+			continue
+		}
+
+		ids := make([]CryptomatteID, 0, len(coverage))
+		for hash, weight := range coverage {
+			ids = append(ids, CryptomatteID{Hash: hash, Coverage: weight / weightSums[idx]})
+		}
+
+		// Rank the IDs by their coverage, breaking ties by hash so that the order is repeatable
+		sort.Slice(ids, func(i, j int) bool {
+			if ids[i].Coverage != ids[j].Coverage {
+				return ids[i].Coverage > ids[j].Coverage
+			}
+			return ids[i].Hash < ids[j].Hash
+		})
+		if len(ids) > rank {
+			ids = ids[:rank]
+		}
+		layer.Pixels[idx] = ids
+	}
+
+	return layer
+}
@@ -0,0 +1,283 @@
+package camera
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/austingebauer/go-ray-tracer/canvas"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/austingebauer/go-ray-tracer/light"
+	"github.com/austingebauer/go-ray-tracer/material"
+	"github.com/austingebauer/go-ray-tracer/matrix"
+	"github.com/austingebauer/go-ray-tracer/point"
+	"github.com/austingebauer/go-ray-tracer/sphere"
+	"github.com/austingebauer/go-ray-tracer/vector"
+	"github.com/austingebauer/go-ray-tracer/world"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestCryptomatteHash(t *testing.T) {
+	tests := []struct {
+		name       string
+		wantMurmur uint32
+		want       uint32
+	}{
+		{
+			name:       "",
+			wantMurmur: 0,
+			// The hash would be a float32 of 0, so a bit of its exponent is flipped
+			want: 0x00800000,
+		},
+		{
+			name:       "hello",
+			wantMurmur: 0x248bfa47,
+			want:       0x248bfa47,
+		},
+		{
+			name:       "The quick brown fox jumps over the lazy dog",
+			wantMurmur: 0x2e4ff723,
+			want:       0x2e4ff723,
+		},
+		{
+			// The examples of the Cryptomatte specification
+			name:       "bunny",
+			wantMurmur: 0x13851a76,
+			want:       0x13851a76,
+		},
+		{
+			name:       "default",
+			wantMurmur: 0x42c9679f,
+			want:       0x42c9679f,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantMurmur, murmurHash3(tt.name))
+			assert.Equal(t, tt.want, CryptomatteHash(tt.name))
+
+			id := math.Float32frombits(CryptomatteHash(tt.name))
+			assert.False(t, math.IsNaN(float64(id)) || math.IsInf(float64(id), 0))
+		})
+	}
+}
+
+func TestCamera_SetCryptomatte(t *testing.T) {
+	c := NewCamera(10, 10, math.Pi/2)
+	assert.Equal(t, 0, c.cryptomatteRank)
+	assert.NoError(t, c.SetCryptomatte(DefaultCryptomatteRank))
+	assert.Equal(t, DefaultCryptomatteRank, c.cryptomatteRank)
+	assert.Error(t, c.SetCryptomatte(-1))
+	assert.Equal(t, DefaultCryptomatteRank, c.cryptomatteRank)
+}
+
+// newCryptomatteTestScene returns a world of two touching spheres with different materials,
+// and a camera that sees both of them.
+func newCryptomatteTestScene(t *testing.T) (*world.World, *Camera) {
+	left := sphere.NewSphere("left", *point.NewPoint(-1, 0, 0), 1)
+	left.Material = material.NewDefaultMaterial()
+	left.Material.Name = "red"
+	right := sphere.NewSphere("right", *point.NewPoint(1, 0, 0), 1)
+	right.Material = material.NewDefaultMaterial()
+
+	w := world.NewWorld()
+	w.Objects = []*sphere.Sphere{left, right}
+	w.Light = light.NewPointLight(*point.NewPoint(-10, 10, -10), *color.NewColor(1, 1, 1))
+
+	c, err := NewCameraWithTransform(16, 8, math.Pi/3,
+		matrix.ViewTransformMatrix4(
+			*point.NewPoint(0, 0, -6),
+			*point.NewPoint(0, 0, 0),
+			*vector.NewVector(0, 1, 0)))
+	assert.NoError(t, err)
+	assert.NoError(t, c.SetAntiAliasing(16, JitteredSampling, NewTentFilter(1)))
+	assert.NoError(t, c.SetTiles(4, ScanlineTileOrder))
+	return w, c
+}
+
+func TestRenderWithCryptomatte(t *testing.T) {
+	w, c := newCryptomatteTestScene(t)
+
+	// Without ID mattes, none are returned
+	want, err := Render(c, w)
+	assert.NoError(t, err)
+	image, mattes, err := RenderWithCryptomatte(c, w)
+	assert.NoError(t, err)
+	assert.Equal(t, want, image)
+	assert.Nil(t, mattes)
+
+	// The ID mattes don't change the image
+	assert.NoError(t, c.SetCryptomatte(2))
+	image, mattes, err = RenderWithCryptomatte(c, w)
+	assert.NoError(t, err)
+	assert.Equal(t, want, image)
+	assert.Equal(t, 16, mattes.Width)
+	assert.Equal(t, 8, mattes.Height)
+	assert.Equal(t, 2, mattes.Rank)
+
+	objects := mattes.Layer(CryptomatteObjectLayer)
+	materials := mattes.Layer(CryptomatteMaterialLayer)
+	assert.Nil(t, mattes.Layer("CryptoAsset"))
+	assert.Equal(t, map[string]uint32{
+		"left":  CryptomatteHash("left"),
+		"right": CryptomatteHash("right"),
+	}, objects.Manifest)
+	assert.Equal(t, map[string]uint32{
+		"red":           CryptomatteHash("red"),
+		unnamedMaterial: CryptomatteHash(unnamedMaterial),
+	}, materials.Manifest)
+
+	// The IDs of each pixel are ranked by coverage, and cover the pixel as much as the image
+	// does. The pixels where the spheres touch are covered by both of them.
+	both := 0
+	for idx, ids := range objects.Pixels {
+		assert.True(t, len(ids) <= 2)
+		coverage := 0.0
+		for i, id := range ids {
+			coverage += id.Coverage
+			if i > 0 {
+				assert.True(t, ids[i-1].Coverage >= id.Coverage)
+			}
+		}
+		assert.InDelta(t, image.Alpha[idx/16][idx%16], coverage, 1e-9)
+		if len(ids) == 2 {
+			both++
+		}
+	}
+	assert.Greater(t, both, 0)
+	assert.Equal(t, []CryptomatteID{{Hash: CryptomatteHash("left"), Coverage: 1}},
+		objects.Pixels[4*16+5])
+	assert.Equal(t, []CryptomatteID{{Hash: CryptomatteHash(unnamedMaterial), Coverage: 1}},
+		materials.Pixels[4*16+10])
+
+	// The mattes of both objects add up to the coverage of the image
+	left := objects.Matte(16, 8, "left")
+	leftAndRight := objects.Matte(16, 8, "left", "right")
+	assert.Equal(t, 1.0, left.Pixels[4][5].Red)
+	assert.Equal(t, 0.0, left.Pixels[4][10].Red)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			assert.InDelta(t, image.Alpha[y][x], leftAndRight.Pixels[y][x].Red, 1e-9)
+		}
+	}
+}
+
+func TestCryptomatte_WriteEXR(t *testing.T) {
+	w, c := newCryptomatteTestScene(t)
+	assert.NoError(t, c.SetCryptomatte(3))
+	_, mattes, err := RenderWithCryptomatte(c, w)
+	assert.NoError(t, err)
+
+	writer := &bytes.Buffer{}
+	assert.NoError(t, mattes.WriteEXR(writer, canvas.EXRZIPCompression))
+	img, err := canvas.ReadEXRImage(bytes.NewReader(writer.Bytes()))
+	assert.NoError(t, err)
+
+	// Each layer has two channel groups to hold three ranks
+	var names []string
+	for _, channel := range img.Channels {
+		names = append(names, channel.Name)
+	}
+	assert.Equal(t, []string{
+		"CryptoMaterial00.A", "CryptoMaterial00.B", "CryptoMaterial00.G", "CryptoMaterial00.R",
+		"CryptoMaterial01.A", "CryptoMaterial01.B", "CryptoMaterial01.G", "CryptoMaterial01.R",
+		"CryptoObject00.A", "CryptoObject00.B", "CryptoObject00.G", "CryptoObject00.R",
+		"CryptoObject01.A", "CryptoObject01.B", "CryptoObject01.G", "CryptoObject01.R",
+	}, names)
+
+	// The IDs are the bits of the hashes as floats, next to their coverage
+	objects := mattes.Layer(CryptomatteObjectLayer)
+	for idx, ids := range objects.Pixels {
+		channels := []string{"CryptoObject00.R", "CryptoObject00.G",
+			"CryptoObject00.B", "CryptoObject00.A", "CryptoObject01.R", "CryptoObject01.G"}
+		for rank := 0; rank < 3; rank++ {
+			id := img.Channel(channels[rank*2]).Values[idx]
+			coverage := img.Channel(channels[rank*2+1]).Values[idx]
+			if rank < len(ids) {
+				assert.Equal(t, ids[rank].Hash, math.Float32bits(id))
+				assert.Equal(t, float32(ids[rank].Coverage), coverage)
+			} else {
+				assert.Equal(t, float32(0), id)
+				assert.Equal(t, float32(0), coverage)
+			}
+		}
+	}
+
+	// The metadata of each layer is keyed by the hash of its name, and has a manifest
+	// that maps names to the hex digits of their hashes
+	assert.Equal(t, "CryptoObject", img.Attributes["cryptomatte/3ae39a5/name"])
+	assert.Equal(t, "MurmurHash3_32", img.Attributes["cryptomatte/3ae39a5/hash"])
+	assert.Equal(t, "uint32_to_float32", img.Attributes["cryptomatte/3ae39a5/conversion"])
+	var manifest map[string]string
+	assert.NoError(t, json.Unmarshal([]byte(img.Attributes["cryptomatte/3ae39a5/manifest"]),
+		&manifest))
+	assert.Equal(t, map[string]string{"left": "a94a3318", "right": "6a7c91ff"}, manifest)
+}
+
+func TestRenderResumeWithCryptomatte(t *testing.T) {
+	w, c := newCryptomatteTestScene(t)
+	assert.NoError(t, c.SetCryptomatte(DefaultCryptomatteRank))
+	_, want, err := RenderWithCryptomatte(c, w)
+	assert.NoError(t, err)
+
+	var cp *Checkpoint
+	_, _, err = RenderContextWithCryptomatte(context.Background(), c, w, &RenderOptions{
+		Checkpoint: func(checkpoint *Checkpoint) error {
+			if cp == nil {
+				cp = checkpoint
+			}
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+
+	// Checkpoints survive being written and read
+	buf := &bytes.Buffer{}
+	assert.NoError(t, WriteCheckpoint(buf, cp))
+	cp, err = ReadCheckpoint(buf)
+	assert.NoError(t, err)
+
+	// The resumed render has the same ID mattes as the render that was never interrupted
+	_, mattes, err := RenderContextWithCryptomatte(context.Background(), c, w,
+		&RenderOptions{Resume: cp})
+	assert.NoError(t, err)
+	assert.Equal(t, want, mattes)
+
+	// A checkpoint without ID mattes can't be resumed by a render with them, or vice versa
+	assert.NoError(t, c.SetCryptomatte(0))
+	_, _, err = RenderContextWithCryptomatte(context.Background(), c, w, &RenderOptions{Resume: cp})
+	assert.Error(t, err)
+}
+
+func TestCryptoFilm_ToCryptomatte(t *testing.T) {
+	// A single sample between the centers of the pixels in the first row, which is
+	// in the negative lobe of the filter for the pixels at either end of the row
+	f := newFilm(4, 1, NewMitchellFilter(2, 1.0/3, 1.0/3))
+	f.crypto = newCryptoFilm(2, 4)
+	result := &world.TraceResult{Hit: true, Object: sphere.NewUnitSphere("ball")}
+	f.addSample(2, 0.5, *color.NewColor(1, 0.5, 0.25), 1)
+	f.addIDSample(2, 0.5, result)
+	assert.True(t, f.weightSums[0] < 0)
+	assert.True(t, f.weightSums[1] > 0)
+
+	image := f.toCanvas(nil)
+	objects := f.crypto.toCryptomatte(f, 2).Layer(CryptomatteObjectLayer)
+	ball := []CryptomatteID{{Hash: CryptomatteHash("ball"), Coverage: 1}}
+	tests := []struct {
+		name string
+		x    int
+		want []CryptomatteID
+	}{
+		{name: "first pixel has negative weight", x: 0, want: nil},
+		{name: "second pixel has positive weight", x: 1, want: ball},
+		{name: "third pixel has positive weight", x: 2, want: ball},
+		{name: "fourth pixel has negative weight", x: 3, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, objects.Pixels[tt.x])
+			assert.Equal(t, image.Alpha[0][tt.x], objects.Matte(4, 1, "ball").Pixels[0][tt.x].Red)
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package camera
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/austingebauer/go-ray-tracer/canvas"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/austingebauer/go-ray-tracer/world"
+	"sort"
+)
+
+// Tile is a rectangular region of the pixels of a camera's image that can be rendered
+// on its own using RenderTile, such as by another process, and later assembled into
+// the image using AssembleTiles.
+type Tile struct {
+	// The position of the tile when the tiles are in scanline order
+	Index int
+	// The top left pixel of the tile
+	X0, Y0 int
+	// The pixel past the bottom right pixel of the tile
+	X1, Y1 int
+}
+
+// TileFilm holds the samples taken for the pixels of a tile rendered using RenderTile.
+type TileFilm struct {
+	// The tile that was rendered
+	Tile Tile
+	// The region of the image that the samples of the tile contribute to, which
+	// includes the margin of pixels around the tile covered by the camera's filter
+	X, Y, Width, Height int
+	// The sum of the filter weighted sample colors for each pixel of the region
+	ColorSums []color.Color
+	// The sum of the filter weights of the samples for each pixel of the region
+	WeightSums []float64
+	// The sum of the filter weighted sample coverages for each pixel of the region
+	AlphaSums []float64
+	// The number of rays cast from the camera for the samples
+	Rays int
+}
+
+// Tiles returns the tiles of the passed camera's image in the camera's tile order.
+func Tiles(c *Camera) []Tile {
+	ts := tiles(c)
+	exported := make([]Tile, len(ts))
+	for i, t := range ts {
+		exported[i] = Tile{Index: t.index, X0: t.x0, Y0: t.y0, X1: t.x1, Y1: t.y1}
+	}
+
+	return exported
+}
+
+// RenderTile uses the passed camera to render the passed tile of its image of the passed
+// world. Tiles can only be rendered on their own by a camera that doesn't sample adaptively,
+// because adaptive sampling compares the pixels of a tile with their neighbors.
+func RenderTile(ctx context.Context, c *Camera, w *world.World, t Tile) (*TileFilm, error) {
+	if c.adaptive != nil {
+		return nil, errors.New("tiles can't be rendered on their own with adaptive sampling")
+	}
+
+	internal, err := findTile(c, t)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tiles rendered on their own don't carry AOVs or ID mattes
+	tf := tileFilm(c, internal)
+	tf.aov = nil
+	tf.crypto = nil
+	stats := make([]pixelStats, (internal.x1-internal.x0)*(internal.y1-internal.y0))
+	err = renderUniformTile(ctx, c, w, world.NewBuffer(), internal, tf, stats)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TileFilm{
+		Tile:       t,
+		X:          tf.x,
+		Y:          tf.y,
+		Width:      tf.width,
+		Height:     tf.height,
+		ColorSums:  tf.colorSums,
+		WeightSums: tf.weightSums,
+		AlphaSums:  tf.alphaSums,
+		Rays:       tf.rays,
+	}, nil
+}
+
+// AssembleTiles assembles the passed films of every tile of the passed camera's
+// image, rendered using RenderTile, into a canvas. The films may be passed in any
+// order, and the canvas is the same as the canvas rendered by Render.
+func AssembleTiles(c *Camera, films []*TileFilm) (*canvas.Canvas, error) {
+	ts := tiles(c)
+	if len(films) != len(ts) {
+		return nil, fmt.Errorf("expected films for %d tiles, got %d", len(ts), len(films))
+	}
+
+	// Merge the films in the order of their tiles' indices, as Render does
+	sorted := append([]*TileFilm(nil), films...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Tile.Index < sorted[j].Tile.Index
+	})
+
+	f := newFilm(c.horizontalSizeInPixels, c.verticalSizeInPixels, c.filter)
+	for i, tf := range sorted {
+		if tf.Tile.Index != i {
+			return nil, fmt.Errorf("missing film for tile %d", i)
+		}
+
+		t, err := findTile(c, tf.Tile)
+		if err != nil {
+			return nil, err
+		}
+
+		want := tileFilm(c, t)
+		if tf.X != want.x || tf.Y != want.y || tf.Width != want.width || tf.Height != want.height ||
+			len(tf.ColorSums) != want.width*want.height || len(tf.WeightSums) != want.width*want.height ||
+			len(tf.AlphaSums) != want.width*want.height {
+			return nil, fmt.Errorf("film for tile %d does not cover the tile's region", i)
+		}
+
+		f.merge(&film{
+			x:          tf.X,
+			y:          tf.Y,
+			width:      tf.Width,
+			height:     tf.Height,
+			filter:     c.filter,
+			colorSums:  tf.ColorSums,
+			weightSums: tf.WeightSums,
+			alphaSums:  tf.AlphaSums,
+			rays:       tf.Rays,
+		})
+	}
+
+	return f.toCanvas(c.outputTransform), nil
+}
+
+// findTile returns the tile of the passed camera's image that matches the passed tile.
+func findTile(c *Camera, t Tile) (tile, error) {
+	for _, candidate := range tiles(c) {
+		if candidate.index == t.Index {
+			if candidate.x0 != t.X0 || candidate.y0 != t.Y0 ||
+				candidate.x1 != t.X1 || candidate.y1 != t.Y1 {
+				break
+			}
+			return candidate, nil
+		}
+	}
+
+	return tile{}, fmt.Errorf("tile %d is not a tile of the camera's image", t.Index)
+}
@@ -0,0 +1,81 @@
+package camera
+
+import (
+	"context"
+	"github.com/austingebauer/go-ray-tracer/matrix"
+	"github.com/austingebauer/go-ray-tracer/point"
+	"github.com/austingebauer/go-ray-tracer/vector"
+	"github.com/austingebauer/go-ray-tracer/world"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestRenderTile(t *testing.T) {
+	w := world.NewDefaultWorld()
+	c, err := NewCameraWithTransform(21, 13, math.Pi/2,
+		matrix.ViewTransformMatrix4(
+			*point.NewPoint(0, 0, -5),
+			*point.NewPoint(0, 0, 0),
+			*vector.NewVector(0, 1, 0)))
+	assert.NoError(t, err)
+	assert.NoError(t, c.SetAntiAliasing(4, JitteredSampling, NewGaussianFilter(1.5, 2)))
+	assert.NoError(t, c.SetTiles(6, SpiralTileOrder))
+
+	// Render the tiles on their own in reverse order
+	ts := Tiles(c)
+	assert.Equal(t, 12, len(ts))
+	var films []*TileFilm
+	for i := len(ts) - 1; i >= 0; i-- {
+		tf, err := RenderTile(context.Background(), c, w, ts[i])
+		assert.NoError(t, err)
+		assert.Equal(t, ts[i], tf.Tile)
+		films = append(films, tf)
+	}
+
+	// The assembled tiles are the same as the rendered image
+	want, err := Render(c, w)
+	assert.NoError(t, err)
+	got, err := AssembleTiles(c, films)
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	// Every tile must be assembled exactly once
+	_, err = AssembleTiles(c, films[1:])
+	assert.Error(t, err)
+	_, err = AssembleTiles(c, append(films[1:], films[1]))
+	assert.Error(t, err)
+
+	// A film must cover the region of its tile
+	truncated := *films[0]
+	truncated.ColorSums = truncated.ColorSums[1:]
+	_, err = AssembleTiles(c, append([]*TileFilm{&truncated}, films[1:]...))
+	assert.Error(t, err)
+	truncated = *films[0]
+	truncated.AlphaSums = nil
+	_, err = AssembleTiles(c, append([]*TileFilm{&truncated}, films[1:]...))
+	assert.Error(t, err)
+}
+
+func TestRenderTileErrors(t *testing.T) {
+	w := world.NewDefaultWorld()
+	c := NewCamera(10, 10, math.Pi/2)
+	assert.NoError(t, c.SetTiles(5, ScanlineTileOrder))
+
+	// A tile that isn't a tile of the camera's image
+	_, err := RenderTile(context.Background(), c, w, Tile{Index: 0, X0: 0, Y0: 0, X1: 4, Y1: 5})
+	assert.Error(t, err)
+	_, err = RenderTile(context.Background(), c, w, Tile{Index: 4})
+	assert.Error(t, err)
+
+	// A cancelled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = RenderTile(ctx, c, w, Tiles(c)[0])
+	assert.Equal(t, context.Canceled, err)
+
+	// A camera that samples adaptively
+	assert.NoError(t, c.SetAdaptiveSampling(1, 4, 0.1))
+	_, err = RenderTile(context.Background(), c, w, Tiles(c)[0])
+	assert.Error(t, err)
+}
@@ -0,0 +1,192 @@
+package camera
+
+import (
+	"github.com/austingebauer/go-ray-tracer/canvas"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/austingebauer/go-ray-tracer/maths"
+	"github.com/austingebauer/go-ray-tracer/world"
+	"math"
+)
+
+// film accumulates the samples taken by a camera and reconstructs
+// the pixels of an image from them using a filter.
+//
+// A film may cover a region of a larger image, such as a tile and the margin of pixels
+// around it that its samples contribute to, in which case it is offset from the image's
+// top left corner. Samples are still located relative to the image's top left corner.
+type film struct {
+	// The offset of the film from the top left corner of the image
+	x, y   int
+	width  int
+	height int
+	filter Filter
+	// The sum of the filter weighted sample colors for each pixel
+	colorSums []color.Color
+	// The sum of the filter weights of the samples for each pixel
+	weightSums []float64
+	// The sum of the filter weighted sample coverages for each pixel,
+	// where a sample that hits an object covers it and one that misses doesn't
+	alphaSums []float64
+	// The number of rays cast from the camera for the samples on the film
+	rays int
+	// The AOVs of the samples on the film, or nil if the render doesn't produce AOVs
+	aov *aovFilm
+	// The coverage of the pixels by IDs, or nil if the render doesn't produce ID mattes
+	crypto *cryptoFilm
+}
+
+// newFilm returns a new film having the passed width, height, and filter.
+func newFilm(width, height int, filter Filter) *film {
+	return &film{
+		width:      width,
+		height:     height,
+		filter:     filter,
+		colorSums:  make([]color.Color, width*height),
+		weightSums: make([]float64, width*height),
+		alphaSums:  make([]float64, width*height),
+	}
+}
+
+// newRegionFilm returns a new film covering the passed region of an image, which
+// starts at (x, y) and has the passed width and height, using the passed filter.
+func newRegionFilm(x, y, width, height int, filter Filter) *film {
+	f := newFilm(width, height, filter)
+	f.x, f.y = x, y
+	return f
+}
+
+// addSample adds the passed sample color and alpha taken at the passed location on the film
+// to every pixel whose center lies within the radius of the film's filter. The location is
+// measured in pixels from the top left corner of the film, so the center of pixel (0, 0)
+// is at (0.5, 0.5).
+func (f *film) addSample(filmX, filmY float64, c color.Color, alpha float64) {
+	filmX, filmY, x0, x1, y0, y1 := f.sampleRange(filmX, filmY)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			weight := f.filter.Evaluate(filmX-(float64(x)+0.5), filmY-(float64(y)+0.5))
+			if weight == 0 {
+				continue
+			}
+
+			idx := y*f.width + x
+			f.colorSums[idx].Red += c.Red * weight
+			f.colorSums[idx].Green += c.Green * weight
+			f.colorSums[idx].Blue += c.Blue * weight
+			f.weightSums[idx] += weight
+			f.alphaSums[idx] += alpha * weight
+		}
+	}
+}
+
+// sampleRange returns the passed location of a sample relative to the film's own top left
+// corner, along with the inclusive range of the pixels of the film that have centers within
+// the radius of the film's filter.
+func (f *film) sampleRange(filmX, filmY float64) (float64, float64, int, int, int, int) {
+	radius := f.filter.Radius()
+
+	// Locate the sample relative to the film's own top left corner
+	filmX -= float64(f.x)
+	filmY -= float64(f.y)
+
+	// Find the range of pixels that have centers within the radius of the sample
+	x0 := int(math.Max(0, math.Ceil(filmX-0.5-radius)))
+	x1 := int(math.Min(float64(f.width-1), math.Floor(filmX-0.5+radius)))
+	y0 := int(math.Max(0, math.Ceil(filmY-0.5-radius)))
+	y1 := int(math.Min(float64(f.height-1), math.Floor(filmY-0.5+radius)))
+	return filmX, filmY, x0, x1, y0, y1
+}
+
+// addIDSample adds the coverage of the object and material hit by the passed trace of
+// a sample taken at the passed location on the film to every pixel whose center lies
+// within the radius of the film's filter, if the film has ID mattes.
+func (f *film) addIDSample(filmX, filmY float64, result *world.TraceResult) {
+	if f.crypto == nil || !result.Hit {
+		return
+	}
+
+	object, material := f.crypto.hashes(result)
+	filmX, filmY, x0, x1, y0, y1 := f.sampleRange(filmX, filmY)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			weight := f.filter.Evaluate(filmX-(float64(x)+0.5), filmY-(float64(y)+0.5))
+			if weight != 0 {
+				f.crypto.add(y*f.width+x, object, material, weight)
+			}
+		}
+	}
+}
+
+// addAOVSample adds the AOVs of the passed trace of a sample taken at the passed location
+// on the film to the pixel that it's located in, if the film has AOVs.
+func (f *film) addAOVSample(filmX, filmY float64, result *world.TraceResult) {
+	x := int(math.Floor(filmX)) - f.x
+	y := int(math.Floor(filmY)) - f.y
+	if f.aov == nil || x < 0 || x >= f.width || y < 0 || y >= f.height {
+		return
+	}
+
+	f.aov.addSample(y*f.width+x, result, sampleDistance(filmX, filmY, x+f.x, y+f.y))
+}
+
+// merge adds the sums of the passed film to the pixels of this film that it covers.
+func (f *film) merge(other *film) {
+	for y := 0; y < other.height; y++ {
+		for x := 0; x < other.width; x++ {
+			fx, fy := other.x+x-f.x, other.y+y-f.y
+			if fx < 0 || fx >= f.width || fy < 0 || fy >= f.height {
+				continue
+			}
+
+			src := y*other.width + x
+			dst := fy*f.width + fx
+			f.colorSums[dst].Red += other.colorSums[src].Red
+			f.colorSums[dst].Green += other.colorSums[src].Green
+			f.colorSums[dst].Blue += other.colorSums[src].Blue
+			f.weightSums[dst] += other.weightSums[src]
+			f.alphaSums[dst] += other.alphaSums[src]
+			if f.aov != nil && other.aov != nil {
+				f.aov.mergePixel(dst, other.aov, src)
+			}
+			if f.crypto != nil && other.crypto != nil {
+				f.crypto.mergePixel(dst, other.crypto, src)
+			}
+		}
+	}
+
+	if f.crypto != nil && other.crypto != nil {
+		f.crypto.mergeNames(other.crypto)
+	}
+
+	f.rays += other.rays
+}
+
+// toCanvas returns a new canvas containing the pixels reconstructed from the samples on the film.
+// The canvas has alpha, which is the coverage of each pixel by the samples that hit an object.
+// Pixels that did not receive any weight from a sample are transparent black, which includes
+// pixels whose weight is negative or nearly 0 due to the negative lobes of a filter, since
+// dividing by it would flip the sign of or blow up their colors. The canvas has the passed
+// output transform, which may be nil.
+func (f *film) toCanvas(transform *canvas.OutputTransform) *canvas.Canvas {
+	image := canvas.NewCanvasWithAlpha(f.width, f.height)
+	image.OutputTransform = transform
+	for y := 0; y < f.height; y++ {
+		for x := 0; x < f.width; x++ {
+			idx := y*f.width + x
+			if f.weightSums[idx] <= maths.Epsilon {
+				continue
+			}
+
+			image.Pixels[y][x] = color.Color{
+				Red:   f.colorSums[idx].Red / f.weightSums[idx],
+				Green: f.colorSums[idx].Green / f.weightSums[idx],
+				Blue:  f.colorSums[idx].Blue / f.weightSums[idx],
+			}
+
+			// Negative filter lobes can push the coverage outside of [0, 1]
+			alpha := f.alphaSums[idx] / f.weightSums[idx]
+			image.Alpha[y][x] = math.Min(math.Max(alpha, 0), 1)
+		}
+	}
+
+	return image
+}
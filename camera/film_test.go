@@ -0,0 +1,36 @@
+package camera
+
+import (
+	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFilm_ToCanvas(t *testing.T) {
+	// A single sample between the centers of the pixels in the first row, which is
+	// in the negative lobe of the filter for the pixels at either end of the row
+	f := newFilm(4, 1, NewMitchellFilter(2, 1.0/3, 1.0/3))
+	f.addSample(2, 0.5, *color.NewColor(1, 0.5, 0.25), 1)
+	assert.True(t, f.weightSums[0] < 0)
+	assert.True(t, f.weightSums[1] > 0)
+
+	image := f.toCanvas(nil)
+	tests := []struct {
+		name  string
+		x     int
+		want  color.Color
+		alpha float64
+	}{
+		{name: "first pixel has negative weight", x: 0, want: color.Color{}, alpha: 0},
+		{name: "second pixel has positive weight", x: 1, want: *color.NewColor(1, 0.5, 0.25), alpha: 1},
+		{name: "third pixel has positive weight", x: 2, want: *color.NewColor(1, 0.5, 0.25), alpha: 1},
+		{name: "fourth pixel has negative weight", x: 3, want: color.Color{}, alpha: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.True(t, color.Equals(tt.want, image.Pixels[0][tt.x]))
+			assert.Equal(t, tt.alpha, image.Alpha[0][tt.x])
+		})
+	}
+
+}
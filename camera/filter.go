@@ -0,0 +1,143 @@
+package camera
+
+import "math"
+
+// Filter is a reconstruction filter that determines how much a sample
+// contributes to the pixels surrounding it when an image is rendered.
+type Filter interface {
+	// Radius returns the extent, in pixels, of the filter in both x and y.
+	Radius() float64
+
+	// Evaluate returns the weight of a sample that is offset by
+	// x and y pixels from the center of the pixel being reconstructed.
+	Evaluate(x, y float64) float64
+}
+
+// BoxFilter is a filter that equally weights all samples within its radius.
+type BoxFilter struct {
+	radius float64
+}
+
+// NewBoxFilter returns a new BoxFilter having a radius of half of a pixel,
+// which weights only the samples taken inside of each pixel.
+func NewBoxFilter() *BoxFilter {
+	return &BoxFilter{radius: 0.5}
+}
+
+// Radius returns the radius of the box filter.
+func (f *BoxFilter) Radius() float64 {
+	return f.radius
+}
+
+// Evaluate returns 1 if the offset lies within the box and 0 otherwise.
+// The box is closed on its negative edges and open on its positive edges
+// so that a sample on the border between two pixels is counted once.
+func (f *BoxFilter) Evaluate(x, y float64) float64 {
+	if x < -f.radius || x >= f.radius || y < -f.radius || y >= f.radius {
+		return 0
+	}
+	return 1
+}
+
+// TentFilter is a filter whose weight falls off linearly from
+// the center of the pixel to its radius.
+type TentFilter struct {
+	radius float64
+}
+
+// NewTentFilter returns a new TentFilter having the passed radius in pixels.
+func NewTentFilter(radius float64) *TentFilter {
+	return &TentFilter{radius: radius}
+}
+
+// Radius returns the radius of the tent filter.
+func (f *TentFilter) Radius() float64 {
+	return f.radius
+}
+
+// Evaluate returns the weight of the tent filter at the passed offset.
+func (f *TentFilter) Evaluate(x, y float64) float64 {
+	return math.Max(0, 1-math.Abs(x)/f.radius) * math.Max(0, 1-math.Abs(y)/f.radius)
+}
+
+// GaussianFilter is a filter that weights samples using a Gaussian
+// bump which is shifted down so that it reaches zero at its radius.
+type GaussianFilter struct {
+	radius float64
+	alpha  float64
+	// The value of the Gaussian at the radius, which is subtracted from each weight
+	expRadius float64
+}
+
+// NewGaussianFilter returns a new GaussianFilter having the passed radius in pixels
+// and falloff rate alpha. Larger values of alpha produce a narrower filter.
+func NewGaussianFilter(radius, alpha float64) *GaussianFilter {
+	return &GaussianFilter{
+		radius:    radius,
+		alpha:     alpha,
+		expRadius: math.Exp(-1 * alpha * radius * radius),
+	}
+}
+
+// Radius returns the radius of the Gaussian filter.
+func (f *GaussianFilter) Radius() float64 {
+	return f.radius
+}
+
+// Evaluate returns the weight of the Gaussian filter at the passed offset.
+func (f *GaussianFilter) Evaluate(x, y float64) float64 {
+	return f.gaussian(x) * f.gaussian(y)
+}
+
+// gaussian evaluates the one dimensional Gaussian at the passed offset.
+func (f *GaussianFilter) gaussian(d float64) float64 {
+	return math.Max(0, math.Exp(-1*f.alpha*d*d)-f.expRadius)
+}
+
+// MitchellFilter is the Mitchell–Netravali filter, which is a parameterized family
+// of cubic filters that trades off between blurring (B) and ringing (C).
+//
+// Note that the filter has negative lobes, so it can sharpen edges at the cost
+// of producing color values outside of the range of the samples.
+type MitchellFilter struct {
+	radius float64
+	b      float64
+	c      float64
+}
+
+// NewMitchellFilter returns a new MitchellFilter having the passed radius
+// in pixels and B and C parameters. Mitchell and Netravali recommend
+// parameters that satisfy B + 2C = 1, such as B = C = 1/3.
+func NewMitchellFilter(radius, b, c float64) *MitchellFilter {
+	return &MitchellFilter{
+		radius: radius,
+		b:      b,
+		c:      c,
+	}
+}
+
+// Radius returns the radius of the Mitchell filter.
+func (f *MitchellFilter) Radius() float64 {
+	return f.radius
+}
+
+// Evaluate returns the weight of the Mitchell filter at the passed offset.
+func (f *MitchellFilter) Evaluate(x, y float64) float64 {
+	return f.mitchell(2*x/f.radius) * f.mitchell(2*y/f.radius)
+}
+
+// mitchell evaluates the one dimensional Mitchell cubic, which is defined over [-2, 2].
+func (f *MitchellFilter) mitchell(d float64) float64 {
+	d = math.Abs(d)
+	b, c := f.b, f.c
+
+	if d >= 2 {
+		return 0
+	}
+
+	if d >= 1 {
+		return ((-b-6*c)*d*d*d + (6*b+30*c)*d*d + (-12*b-48*c)*d + (8*b + 24*c)) / 6
+	}
+
+	return ((12-9*b-6*c)*d*d*d + (-18+12*b+6*c)*d*d + (6 - 2*b)) / 6
+}
@@ -0,0 +1,133 @@
+package camera
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFilter_Evaluate(t *testing.T) {
+	type args struct {
+		x float64
+		y float64
+	}
+	tests := []struct {
+		name   string
+		filter Filter
+		args   args
+		want   float64
+	}{
+		{
+			name:   "box filter at the center of a pixel",
+			filter: NewBoxFilter(),
+			args:   args{x: 0, y: 0},
+			want:   1,
+		},
+		{
+			name:   "box filter on the negative edge of a pixel",
+			filter: NewBoxFilter(),
+			args:   args{x: -0.5, y: 0},
+			want:   1,
+		},
+		{
+			name:   "box filter on the positive edge of a pixel",
+			filter: NewBoxFilter(),
+			args:   args{x: 0.5, y: 0},
+			want:   0,
+		},
+		{
+			name:   "tent filter at the center of a pixel",
+			filter: NewTentFilter(1),
+			args:   args{x: 0, y: 0},
+			want:   1,
+		},
+		{
+			name:   "tent filter halfway to its radius",
+			filter: NewTentFilter(1),
+			args:   args{x: 0.5, y: -0.5},
+			want:   0.25,
+		},
+		{
+			name:   "tent filter outside of its radius",
+			filter: NewTentFilter(1),
+			args:   args{x: 1.5, y: 0},
+			want:   0,
+		},
+		{
+			name:   "gaussian filter at the center of a pixel",
+			filter: NewGaussianFilter(1.5, 2),
+			args:   args{x: 0, y: 0},
+			want:   0.97791,
+		},
+		{
+			name:   "gaussian filter at its radius",
+			filter: NewGaussianFilter(1.5, 2),
+			args:   args{x: 1.5, y: 0},
+			want:   0,
+		},
+		{
+			name:   "mitchell filter at the center of a pixel",
+			filter: NewMitchellFilter(2, 1.0/3, 1.0/3),
+			args:   args{x: 0, y: 0},
+			want:   0.79012,
+		},
+		{
+			name:   "mitchell filter in its negative lobe",
+			filter: NewMitchellFilter(2, 1.0/3, 1.0/3),
+			args:   args{x: 1.5, y: 0},
+			want:   -0.03086,
+		},
+		{
+			name:   "mitchell filter at its radius",
+			filter: NewMitchellFilter(2, 1.0/3, 1.0/3),
+			args:   args{x: 0, y: 2},
+			want:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, tt.filter.Evaluate(tt.args.x, tt.args.y), 0.00001)
+		})
+	}
+}
+
+func TestSamplePosition(t *testing.T) {
+	// Each strategy places all of its samples inside of the pixel
+	for _, strategy := range []SamplingStrategy{RegularGridSampling, JitteredSampling,
+		RandomSampling} {
+		rnd := newSampleRand(3, 7)
+		n := sampleCount(strategy, 5)
+		for i := 0; i < n; i++ {
+			u, v := samplePosition(strategy, i, n, rnd)
+			assert.True(t, u >= 0 && u < 1)
+			assert.True(t, v >= 0 && v < 1)
+		}
+	}
+
+	// Grid strategies round up to a perfect square and take one sample per cell
+	assert.Equal(t, 9, sampleCount(RegularGridSampling, 5))
+	assert.Equal(t, 9, sampleCount(JitteredSampling, 9))
+	assert.Equal(t, 5, sampleCount(RandomSampling, 5))
+
+	u, v := samplePosition(RegularGridSampling, 3, 4, newSampleRand(0))
+	assert.Equal(t, 0.75, u)
+	assert.Equal(t, 0.75, v)
+}
+
+func TestNewSampleRand(t *testing.T) {
+	// The streams of nearby pixels and passes are distinct
+	seen := make(map[uint64][]int)
+	for pass := 0; pass < 4; pass++ {
+		for y := 0; y < 64; y++ {
+			for x := 0; x < 64; x++ {
+				first := newSampleRand(x, y, pass).next()
+				if other, ok := seen[first]; ok {
+					t.Fatalf("seeds %v and %v share their first value", other, []int{x, y, pass})
+				}
+				seen[first] = []int{x, y, pass}
+			}
+		}
+	}
+
+	// The same seeds produce the same stream
+	assert.Equal(t, newSampleRand(1, 2).next(), newSampleRand(1, 2).next())
+}
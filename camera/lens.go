@@ -0,0 +1,101 @@
+package camera
+
+import (
+	"errors"
+	"math"
+)
+
+// SetThinLens configures the camera to simulate a thin lens with an aperture of
+// the passed radius, focused on the plane at the passed distance from the camera.
+// Objects nearer or farther than the focal distance are blurred, and the amount
+// of blur increases with the radius of the aperture.
+//
+// An aperture radius of 0 produces a pinhole camera, for which everything is in focus.
+// Note that depth of field requires multiple samples per pixel to look smooth.
+func (c *Camera) SetThinLens(apertureRadius, focalDistance float64) error {
+	if apertureRadius < 0 {
+		return errors.New("aperture radius must not be negative")
+	}
+	if focalDistance <= 0 {
+		return errors.New("focal distance must be greater than zero")
+	}
+
+	c.apertureRadius = apertureRadius
+	c.focalDistance = focalDistance
+	return nil
+}
+
+// SetApertureBlades configures the camera's aperture to be a regular polygon formed by
+// the passed number of blades and rotated by the passed number of radians. Out of focus
+// highlights take on the shape of the aperture. A blade count of 0 produces a circular
+// aperture, which is the default.
+func (c *Camera) SetApertureBlades(blades int, rotation float64) error {
+	if blades != 0 && blades < 3 {
+		return errors.New("an aperture must have 0 or at least 3 blades")
+	}
+
+	c.apertureBlades = blades
+	c.apertureRotation = rotation
+	return nil
+}
+
+// sampleAperture maps the passed u and v values, which are in the range [0, 1),
+// to a point on the camera's aperture. Uniformly distributed u and v values
+// produce points that are uniformly distributed over the area of the aperture.
+func sampleAperture(c *Camera, u, v float64) (float64, float64) {
+	var x, y float64
+	if c.apertureBlades == 0 {
+		x, y = sampleDisk(u, v)
+	} else {
+		x, y = samplePolygon(c.apertureBlades, c.apertureRotation, u, v)
+	}
+
+	return x * c.apertureRadius, y * c.apertureRadius
+}
+
+// sampleDisk maps the passed u and v values to a point on the unit disk using
+// Shirley and Chiu's concentric mapping, which maps concentric squares to
+// concentric circles and so preserves the stratification of the samples.
+func sampleDisk(u, v float64) (float64, float64) {
+	// Map u and v to the range [-1, 1]
+	a := 2*u - 1
+	b := 2*v - 1
+	if a == 0 && b == 0 {
+		return 0, 0
+	}
+
+	var radius, theta float64
+	if math.Abs(a) > math.Abs(b) {
+		radius = a
+		theta = (math.Pi / 4) * (b / a)
+	} else {
+		radius = b
+		theta = (math.Pi / 2) - (math.Pi/4)*(a/b)
+	}
+
+	return radius * math.Cos(theta), radius * math.Sin(theta)
+}
+
+// samplePolygon maps the passed u and v values to a point on a regular polygon with the
+// passed number of sides, which is inscribed in the unit circle and rotated by the passed
+// number of radians. The polygon is divided into triangles that share its center, and u
+// selects the triangle before being reused to pick a point within it.
+func samplePolygon(sides int, rotation, u, v float64) (float64, float64) {
+	// Select the triangle and rescale u to the range [0, 1) within it
+	scaled := u * float64(sides)
+	triangle := math.Min(math.Floor(scaled), float64(sides-1))
+	u = scaled - triangle
+
+	// The two corners of the triangle on the edge of the polygon
+	angle := (2 * math.Pi) / float64(sides)
+	theta1 := rotation + triangle*angle
+	theta2 := theta1 + angle
+
+	// Pick a uniformly distributed point within the triangle formed
+	// by the center of the polygon and the two corners.
+	su := math.Sqrt(u)
+	w1 := su * (1 - v)
+	w2 := su * v
+
+	return w1*math.Cos(theta1) + w2*math.Cos(theta2), w1*math.Sin(theta1) + w2*math.Sin(theta2)
+}
@@ -0,0 +1,120 @@
+package camera
+
+import (
+	"github.com/austingebauer/go-ray-tracer/point"
+	"github.com/austingebauer/go-ray-tracer/vector"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestCamera_SetThinLens(t *testing.T) {
+	type args struct {
+		apertureRadius float64
+		focalDistance  float64
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name:    "thin lens with an aperture",
+			args:    args{apertureRadius: 0.1, focalDistance: 5},
+			wantErr: false,
+		},
+		{
+			name:    "thin lens with a pinhole aperture",
+			args:    args{apertureRadius: 0, focalDistance: 1},
+			wantErr: false,
+		},
+		{
+			name:    "thin lens with a negative aperture",
+			args:    args{apertureRadius: -0.1, focalDistance: 5},
+			wantErr: true,
+		},
+		{
+			name:    "thin lens with a zero focal distance",
+			args:    args{apertureRadius: 0.1, focalDistance: 0},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCamera(10, 10, math.Pi/2)
+			err := c.SetThinLens(tt.args.apertureRadius, tt.args.focalDistance)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.args.apertureRadius, c.apertureRadius)
+				assert.Equal(t, tt.args.focalDistance, c.focalDistance)
+			}
+		})
+	}
+}
+
+func TestCamera_SetApertureBlades(t *testing.T) {
+	c := NewCamera(10, 10, math.Pi/2)
+	assert.NoError(t, c.SetApertureBlades(6, math.Pi/6))
+	assert.Equal(t, 6, c.apertureBlades)
+	assert.Equal(t, math.Pi/6, c.apertureRotation)
+	assert.NoError(t, c.SetApertureBlades(0, 0))
+	assert.Error(t, c.SetApertureBlades(2, 0))
+	assert.Error(t, c.SetApertureBlades(-1, 0))
+}
+
+func TestRayForSampleThinLens(t *testing.T) {
+	// Every ray through the same point on the canvas converges on the focal plane
+	c := NewCamera(11, 11, math.Pi/2)
+	assert.NoError(t, c.SetThinLens(0.5, 5))
+	assert.NoError(t, c.SetApertureBlades(5, 0.3))
+
+	pinhole, err := RayForPixel(c, 2, 3)
+	assert.NoError(t, err)
+	focus := point.Add(pinhole.Origin, vector.Scale(*pinhole.Direction,
+		-5/pinhole.Direction.Z))
+
+	origins := 0
+	rnd := newSampleRand(1)
+	for i := 0; i < 16; i++ {
+		r, err := rayForSample(c, cameraSample{
+			filmX: 2.5,
+			filmY: 3.5,
+			lensU: rnd.Float64(),
+			lensV: rnd.Float64(),
+		})
+		assert.NoError(t, err)
+
+		// The ray starts on the lens and passes through the focus point
+		assert.Equal(t, 0.0, r.Origin.Z)
+		assert.LessOrEqual(t, math.Hypot(r.Origin.X, r.Origin.Y), 0.5+0.00001)
+		assert.True(t, r.Direction.Equals(vector.Normalize(*point.Subtract(*focus, *r.Origin))))
+		if !r.Origin.Equals(pinhole.Origin) {
+			origins++
+		}
+	}
+	assert.Equal(t, 16, origins)
+}
+
+func TestSampleDisk(t *testing.T) {
+	rnd := newSampleRand(2)
+	for i := 0; i < 100; i++ {
+		x, y := sampleDisk(rnd.Float64(), rnd.Float64())
+		assert.LessOrEqual(t, math.Hypot(x, y), 1.0)
+	}
+
+	x, y := sampleDisk(0.5, 0.5)
+	assert.Equal(t, 0.0, x)
+	assert.Equal(t, 0.0, y)
+}
+
+func TestSamplePolygon(t *testing.T) {
+	// A square aperture rotated by 45 degrees has its corners on the axes,
+	// so every point within it satisfies |x| + |y| <= 1.
+	rnd := newSampleRand(3)
+	for i := 0; i < 100; i++ {
+		x, y := samplePolygon(4, 0, rnd.Float64(), rnd.Float64())
+		assert.LessOrEqual(t, math.Abs(x)+math.Abs(y), 1.0+0.00001)
+	}
+}
@@ -0,0 +1,82 @@
+package camera
+
+import (
+	"time"
+)
+
+// Progress describes how much of a render has been completed.
+type Progress struct {
+	// The pass being rendered, which is always 0 unless rendering adaptively or progressively
+	Pass int
+	// The number of tiles that have been rendered
+	TilesDone int
+	// The number of tiles to render
+	TilesTotal int
+	// The number of pixels that have been sampled
+	PixelsDone int
+	// The number of pixels to sample
+	PixelsTotal int
+	// The number of rays that have been cast from the camera
+	RaysCast int
+	// The time since the render started
+	Elapsed time.Duration
+	// The estimated time until the render completes, based on the
+	// rate at which pixels have been sampled so far
+	Remaining time.Duration
+}
+
+// progressTracker tracks the progress of a render and reports it to a callback.
+//
+// With adaptive sampling, the number of pixels to sample grows with each pass that
+// refines pixels, so the totals and estimates only account for passes that have started.
+type progressTracker struct {
+	callback func(Progress)
+	start    time.Time
+	progress Progress
+	// The number of pixels that were sampled before the render was resumed
+	skippedPixels int
+}
+
+// newProgressTracker returns a new progress tracker that reports to the passed callback,
+// which may be nil.
+func newProgressTracker(callback func(Progress)) *progressTracker {
+	return &progressTracker{
+		callback: callback,
+		start:    time.Now(),
+	}
+}
+
+// startPass adds a pass with the passed number of tiles and pixels to the work to be done.
+func (p *progressTracker) startPass(pass, tiles, pixels int) {
+	p.progress.Pass = pass
+	p.progress.TilesTotal += tiles
+	p.progress.PixelsTotal += pixels
+}
+
+// tileDone records that a tile with the passed number of pixels and
+// rays has been rendered, and reports the progress to the callback.
+func (p *progressTracker) tileDone(pixels, rays int) {
+	p.progress.TilesDone++
+	p.progress.PixelsDone += pixels
+	p.progress.RaysCast += rays
+	p.progress.Elapsed = time.Since(p.start)
+
+	p.progress.Remaining = 0
+	if sampled := p.progress.PixelsDone - p.skippedPixels; sampled > 0 {
+		remaining := p.progress.PixelsTotal - p.progress.PixelsDone
+		p.progress.Remaining = time.Duration(float64(p.progress.Elapsed) *
+			float64(remaining) / float64(sampled))
+	}
+
+	if p.callback != nil {
+		p.callback(p.progress)
+	}
+}
+
+// skipTile records that a tile with the passed number of pixels was rendered
+// before the render was resumed. It doesn't report the progress to the callback.
+func (p *progressTracker) skipTile(pixels int) {
+	p.progress.TilesDone++
+	p.progress.PixelsDone += pixels
+	p.skippedPixels += pixels
+}
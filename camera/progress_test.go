@@ -0,0 +1,146 @@
+package camera
+
+import (
+	"context"
+	"github.com/austingebauer/go-ray-tracer/matrix"
+	"github.com/austingebauer/go-ray-tracer/point"
+	"github.com/austingebauer/go-ray-tracer/vector"
+	"github.com/austingebauer/go-ray-tracer/world"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func newProgressTestCamera(t *testing.T) *Camera {
+	c, err := NewCameraWithTransform(20, 10, math.Pi/2,
+		matrix.ViewTransformMatrix4(
+			*point.NewPoint(0, 0, -5),
+			*point.NewPoint(0, 0, 0),
+			*vector.NewVector(0, 1, 0)))
+	assert.NoError(t, err)
+	assert.NoError(t, c.SetTiles(4, SpiralTileOrder))
+	return c
+}
+
+func TestRenderContext(t *testing.T) {
+	w := world.NewDefaultWorld()
+	c := newProgressTestCamera(t)
+	assert.NoError(t, c.SetAntiAliasing(4, RegularGridSampling, NewBoxFilter()))
+
+	var reports []Progress
+	image, err := RenderContext(context.Background(), c, w, &RenderOptions{
+		Progress: func(p Progress) {
+			reports = append(reports, p)
+		},
+	})
+	assert.NoError(t, err)
+
+	// The image is the same as an image rendered without a context
+	want, err := Render(c, w)
+	assert.NoError(t, err)
+	assert.Equal(t, want, image)
+
+	// Progress is reported once for each of the 5x3 tiles
+	assert.Equal(t, 15, len(reports))
+	for i, p := range reports {
+		assert.Equal(t, i+1, p.TilesDone)
+		assert.Equal(t, 15, p.TilesTotal)
+		assert.Equal(t, 200, p.PixelsTotal)
+		assert.Equal(t, p.PixelsDone*4, p.RaysCast)
+	}
+
+	last := reports[len(reports)-1]
+	assert.Equal(t, 200, last.PixelsDone)
+	assert.Equal(t, 800, last.RaysCast)
+	assert.Equal(t, int64(0), int64(last.Remaining))
+}
+
+func TestRenderContextAdaptive(t *testing.T) {
+	w := world.NewDefaultWorld()
+	c := newProgressTestCamera(t)
+	assert.NoError(t, c.SetAdaptiveSampling(1, 8, 0.01))
+
+	var last Progress
+	_, heatmap, err := render(context.Background(), c, w, &RenderOptions{
+		Progress: func(p Progress) {
+			last = p
+		},
+	})
+	assert.NoError(t, err)
+
+	// Refinement passes add to the pixels to sample
+	assert.True(t, last.Pass > 0)
+	assert.True(t, last.PixelsTotal > 200)
+	assert.Equal(t, last.PixelsTotal, last.PixelsDone)
+	assert.Equal(t, last.TilesTotal, last.TilesDone)
+
+	// Every sample casts a ray from the camera
+	rays := 0
+	for _, s := range renderedSampleCounts(t, c, w) {
+		rays += s
+	}
+	assert.Equal(t, rays, last.RaysCast)
+	assert.NotNil(t, heatmap)
+}
+
+// renderedSampleCounts returns the number of samples taken for each pixel
+// when the passed camera renders the passed world.
+func renderedSampleCounts(t *testing.T, c *Camera, w *world.World) []int {
+	rs, err := newRenderState(c, &RenderOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, renderAdaptive(context.Background(), c, w, rs))
+
+	counts := make([]int, len(rs.stats))
+	for i, s := range rs.stats {
+		counts[i] = s.count
+	}
+	return counts
+}
+
+func TestRenderContextCancelled(t *testing.T) {
+	w := world.NewDefaultWorld()
+
+	t.Run("context cancelled before the render starts", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		image, err := RenderContext(ctx, newProgressTestCamera(t), w, nil)
+		assert.Equal(t, context.Canceled, err)
+		assert.Nil(t, image)
+	})
+
+	t.Run("context cancelled during the render", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c := newProgressTestCamera(t)
+		assert.NoError(t, c.SetWorkers(1))
+		tilesDone := 0
+		image, err := RenderContext(ctx, c, w, &RenderOptions{
+			Progress: func(p Progress) {
+				tilesDone = p.TilesDone
+				cancel()
+			},
+		})
+		assert.Equal(t, context.Canceled, err)
+		assert.Nil(t, image)
+		assert.True(t, tilesDone < 15)
+	})
+
+	t.Run("adaptive render cancelled during a refinement pass", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c := newProgressTestCamera(t)
+		assert.NoError(t, c.SetAdaptiveSampling(1, 8, 0.01))
+		image, err := RenderContext(ctx, c, w, &RenderOptions{
+			Progress: func(p Progress) {
+				if p.Pass > 0 {
+					cancel()
+				}
+			},
+		})
+		assert.Equal(t, context.Canceled, err)
+		assert.Nil(t, image)
+	})
+}
@@ -0,0 +1,58 @@
+package camera
+
+import (
+	"context"
+	"github.com/austingebauer/go-ray-tracer/world"
+)
+
+// renderProgressive renders the passes of a progressive render, each of
+// which takes one sample for every pixel of the camera.
+func renderProgressive(ctx context.Context, c *Camera, w *world.World, rs *renderState) error {
+	passes := sampleCount(c.samplingStrategy, c.samplesPerPixel)
+	ts := tiles(c)
+
+	// The number of passes is known up front, so account for all of them at once
+	firstPass := rs.resumePass()
+	rs.tracker.startPass(firstPass, len(ts)*(passes-firstPass),
+		c.horizontalSizeInPixels*c.verticalSizeInPixels*(passes-firstPass))
+
+	for pass := firstPass; pass < passes; pass++ {
+		rs.tracker.startPass(pass, 0, 0)
+		rs.startPass(pass, nil)
+		err := renderTiles(ctx, c, rs, ts, func(ctx context.Context, t tile, tf *film,
+			stats []pixelStats, buf *world.Buffer) error {
+			// For each pixel of the tile
+			for y := t.y0; y < t.y1; y++ {
+				for x := t.x0; x < t.x1; x++ {
+					if err := ctx.Err(); err != nil {
+						return err
+					}
+
+					// Each pass takes the next of the pixel's samples, so that the samples
+					// of all passes follow the sampling strategy. The first sample of each
+					// pixel is chosen at random so that early passes aren't biased toward
+					// the same region of every pixel.
+					first := int(newSampleRand(x, y).next() % uint64(passes))
+					rnd := newSampleRand(x, y, pass)
+					u, v := samplePosition(c.samplingStrategy, (first+pass)%passes, passes, rnd)
+					err := takeSample(c, w, buf, tf, &stats[t.pixelIndex(x, y)], x, y, u, v, rnd)
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = rs.takeSnapshot(c, pass)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package camera
+
+import (
+	"context"
+	"errors"
+	"github.com/austingebauer/go-ray-tracer/canvas"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/austingebauer/go-ray-tracer/world"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRenderProgressive(t *testing.T) {
+	w := world.NewDefaultWorld()
+	c := newProgressTestCamera(t)
+	assert.NoError(t, c.SetAntiAliasing(4, RegularGridSampling, NewBoxFilter()))
+
+	var passes []int
+	var snapshots []*canvas.Canvas
+	var last Progress
+	image, err := RenderContext(context.Background(), c, w, &RenderOptions{
+		Progressive: true,
+		Snapshot: func(pass int, image *canvas.Canvas) error {
+			passes = append(passes, pass)
+			snapshots = append(snapshots, image)
+			return nil
+		},
+		Progress: func(p Progress) {
+			last = p
+		},
+	})
+	assert.NoError(t, err)
+
+	// A snapshot is taken after each of the passes, and the last snapshot is the final image
+	assert.Equal(t, []int{0, 1, 2, 3}, passes)
+	assert.Equal(t, image, snapshots[len(snapshots)-1])
+
+	// Progress accounts for every pass from the start
+	assert.Equal(t, 3, last.Pass)
+	assert.Equal(t, 15*4, last.TilesTotal)
+	assert.Equal(t, 15*4, last.TilesDone)
+	assert.Equal(t, 200*4, last.PixelsDone)
+	assert.Equal(t, 200*4, last.RaysCast)
+
+	// The final image takes the same samples as a render that isn't progressive
+	want, err := Render(c, w)
+	assert.NoError(t, err)
+	for y := 0; y < want.Height; y++ {
+		for x := 0; x < want.Width; x++ {
+			assert.True(t, color.Equals(want.Pixels[y][x], image.Pixels[y][x]))
+		}
+	}
+
+	// The first snapshot is a preview that differs from the final image
+	assert.NotEqual(t, image, snapshots[0])
+}
+
+func TestRenderProgressiveSnapshotError(t *testing.T) {
+	w := world.NewDefaultWorld()
+	c := newProgressTestCamera(t)
+	assert.NoError(t, c.SetAntiAliasing(4, JitteredSampling, NewBoxFilter()))
+
+	snapshotErr := errors.New("disk full")
+	snapshots := 0
+	image, err := RenderContext(context.Background(), c, w, &RenderOptions{
+		Progressive: true,
+		Snapshot: func(pass int, image *canvas.Canvas) error {
+			snapshots++
+			return snapshotErr
+		},
+	})
+	assert.Equal(t, snapshotErr, err)
+	assert.Nil(t, image)
+	assert.Equal(t, 1, snapshots)
+}
+
+func TestRenderAdaptiveSnapshots(t *testing.T) {
+	w := world.NewDefaultWorld()
+	c := newProgressTestCamera(t)
+	assert.NoError(t, c.SetAdaptiveSampling(1, 8, 0.01))
+
+	var passes []int
+	_, err := RenderContext(context.Background(), c, w, &RenderOptions{
+		Snapshot: func(pass int, image *canvas.Canvas) error {
+			passes = append(passes, pass)
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+
+	// A snapshot is taken after the first pass and each refinement pass
+	assert.True(t, len(passes) > 1)
+	for i, pass := range passes {
+		assert.Equal(t, i, pass)
+	}
+}
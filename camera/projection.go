@@ -0,0 +1,174 @@
+package camera
+
+import (
+	"errors"
+	"github.com/austingebauer/go-ray-tracer/point"
+	"math"
+)
+
+// ErrOutsideImageCircle is returned by RayForPixel for the pixels of a fisheye camera
+// that lie outside of its image circle, through which no ray passes.
+var ErrOutsideImageCircle = errors.New("pixel is outside of the image circle")
+
+// Projection describes how a camera maps locations on its canvas to rays in the world.
+type Projection int
+
+const (
+	// PerspectiveProjection casts rays from a single point through a flat canvas, so that
+	// distant objects appear smaller. The field of view spans the longer side of the canvas.
+	PerspectiveProjection Projection = iota
+
+	// OrthographicProjection casts parallel rays from every point of a flat canvas, so that
+	// objects appear the same size regardless of their distance from the camera. The width
+	// of the view is set using SetOrthographicWidth.
+	OrthographicProjection
+
+	// FisheyeEquidistantProjection maps the angle between a ray and the camera's view
+	// direction linearly to the distance from the center of a circular image. The field
+	// of view spans the diameter of the image circle, which fits the shorter side of the canvas.
+	FisheyeEquidistantProjection
+
+	// FisheyeEquisolidProjection maps equal solid angles around the camera to equal areas
+	// of a circular image, which preserves the relative area of objects. The field of view
+	// spans the diameter of the image circle, which fits the shorter side of the canvas.
+	FisheyeEquisolidProjection
+
+	// EquirectangularProjection maps longitude around the camera to the horizontal axis of
+	// the canvas and latitude to its vertical axis, which captures a 360° panorama. The
+	// field of view is not used and the canvas should be twice as wide as it is tall.
+	EquirectangularProjection
+)
+
+// SetProjection sets the projection used by the camera.
+// By default, a camera uses a perspective projection.
+//
+// Note that the thin lens set by SetThinLens only applies to perspective projections.
+func (c *Camera) SetProjection(p Projection) error {
+	if p < PerspectiveProjection || p > EquirectangularProjection {
+		return errors.New("unknown projection")
+	}
+
+	c.projection = p
+	return nil
+}
+
+// SetOrthographicWidth sets the width, in world-space units, of the view of a camera
+// that uses an orthographic projection. The height of the view follows from the aspect
+// ratio of the canvas. By default, the view is as wide as the canvas of a perspective
+// projection having the same field of view, which is one unit in front of the camera.
+func (c *Camera) SetOrthographicWidth(width float64) error {
+	if width <= 0 {
+		return errors.New("orthographic width must be greater than zero")
+	}
+
+	c.orthographicWidth = width
+	return nil
+}
+
+// project maps the passed sample to an untransformed point on a ray and the untransformed
+// origin of the ray, using the passed camera's projection. The untransformed camera is at
+// the origin looking toward -z. Returns false if the projection doesn't cover the sample.
+func project(c *Camera, s cameraSample) (*point.Point, *point.Point, bool) {
+	switch c.projection {
+	case OrthographicProjection:
+		return projectOrthographic(c, s)
+	case FisheyeEquidistantProjection, FisheyeEquisolidProjection:
+		return projectFisheye(c, s)
+	case EquirectangularProjection:
+		return projectEquirectangular(c, s)
+	default:
+		return projectPerspective(c, s)
+	}
+}
+
+// projectPerspective projects the passed sample through a canvas one unit in front of the camera.
+func projectPerspective(c *Camera, s cameraSample) (*point.Point, *point.Point, bool) {
+	// Compute the offset from the left edge of the canvas to the point
+	xOffset := c.pixelSize * s.filmX
+	YOffset := c.pixelSize * s.filmY
+
+	// The untransformed coordinates of the pixel in world space, which are
+	// moved by the lens shift of an off-axis camera such as a stereo eye.
+	// Note that the camera looks toward -z, so +x is to the left.
+	worldX := c.halfWidth - xOffset + c.lensShiftX
+	worldY := c.halfHeight - YOffset
+
+	// A pinhole camera casts every ray from its origin through the canvas, which is at z=-1.
+	if c.apertureRadius == 0 {
+		return point.NewPoint(worldX, worldY, -1), point.NewPoint(0, 0, 0), true
+	}
+
+	// A camera with an aperture focuses the rays leaving the lens onto the plane at its
+	// focal distance. All of the rays for a point on the canvas converge where the ray
+	// through the center of the lens meets that plane, so objects on the plane are sharp.
+	lensX, lensY := sampleAperture(c, s.lensU, s.lensV)
+	return point.NewPoint(worldX*c.focalDistance, worldY*c.focalDistance, -1*c.focalDistance),
+		point.NewPoint(lensX, lensY, 0), true
+}
+
+// projectOrthographic projects the passed sample straight ahead from a point on the canvas.
+func projectOrthographic(c *Camera, s cameraSample) (*point.Point, *point.Point, bool) {
+	// Scale the perspective canvas to the width of the orthographic view
+	scale := c.orthographicWidth / (c.halfWidth * 2)
+	worldX := (c.halfWidth - c.pixelSize*s.filmX) * scale
+	worldY := (c.halfHeight - c.pixelSize*s.filmY) * scale
+
+	return point.NewPoint(worldX, worldY, -1), point.NewPoint(worldX, worldY, 0), true
+}
+
+// projectFisheye projects the passed sample using one of the fisheye projections.
+func projectFisheye(c *Camera, s cameraSample) (*point.Point, *point.Point, bool) {
+	// Compute the location of the sample relative to the image circle,
+	// where the center is (0, 0) and the edge is at a distance of 1.
+	width := float64(c.horizontalSizeInPixels)
+	height := float64(c.verticalSizeInPixels)
+	circleRadius := math.Min(width, height) / 2
+	nx := (s.filmX - width/2) / circleRadius
+	ny := (s.filmY - height/2) / circleRadius
+
+	radius := math.Hypot(nx, ny)
+	if radius > 1 {
+		return nil, nil, false
+	}
+
+	// Compute the angle between the ray and the view direction
+	var theta float64
+	if c.projection == FisheyeEquisolidProjection {
+		theta = 2 * math.Asin(radius*math.Sin(c.fieldOfView/4))
+	} else {
+		theta = radius * (c.fieldOfView / 2)
+	}
+
+	// Note that the camera looks toward -z, so +x is to the left and +y is up.
+	dirX, dirY := 0.0, 0.0
+	if radius > 0 {
+		dirX = -1 * math.Sin(theta) * (nx / radius)
+		dirY = -1 * math.Sin(theta) * (ny / radius)
+	}
+
+	return point.NewPoint(dirX, dirY, -1*math.Cos(theta)), point.NewPoint(0, 0, 0), true
+}
+
+// projectEquirectangular projects the passed sample using the longitude
+// and latitude that its location on the canvas corresponds to.
+//
+// An omni-directional stereo eye casts each ray from a circle around the camera, offset
+// perpendicular to the ray's longitude, rather than from a single point. The offset fades
+// toward the poles, where the eyes of a viewer looking up or down can't be separated.
+func projectEquirectangular(c *Camera, s cameraSample) (*point.Point, *point.Point, bool) {
+	// Longitude is 0 at the center of the canvas and increases to the right.
+	// Latitude is 0 at the center of the canvas and increases upward.
+	longitude := (s.filmX/float64(c.horizontalSizeInPixels) - 0.5) * 2 * math.Pi
+	latitude := (0.5 - s.filmY/float64(c.verticalSizeInPixels)) * math.Pi
+
+	// Note that the camera looks toward -z, so +x is to the left.
+	dirX := -1 * math.Sin(longitude) * math.Cos(latitude)
+	dirY := math.Sin(latitude)
+	dirZ := -1 * math.Cos(longitude) * math.Cos(latitude)
+
+	// Move the origin to the left of the ray's direction by the eye offset
+	offset := c.stereoEyeOffset * math.Cos(latitude)
+	origin := point.NewPoint(offset*math.Cos(longitude), 0, -1*offset*math.Sin(longitude))
+
+	return point.NewPoint(origin.X+dirX, origin.Y+dirY, origin.Z+dirZ), origin, true
+}
@@ -0,0 +1,160 @@
+package camera
+
+import (
+	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/austingebauer/go-ray-tracer/matrix"
+	"github.com/austingebauer/go-ray-tracer/point"
+	"github.com/austingebauer/go-ray-tracer/ray"
+	"github.com/austingebauer/go-ray-tracer/vector"
+	"github.com/austingebauer/go-ray-tracer/world"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestCamera_SetProjection(t *testing.T) {
+	c := NewCamera(10, 10, math.Pi/2)
+	assert.Equal(t, PerspectiveProjection, c.projection)
+	assert.NoError(t, c.SetProjection(EquirectangularProjection))
+	assert.Equal(t, EquirectangularProjection, c.projection)
+	assert.Error(t, c.SetProjection(Projection(-1)))
+	assert.Error(t, c.SetProjection(Projection(100)))
+	assert.Equal(t, EquirectangularProjection, c.projection)
+
+	assert.NoError(t, c.SetOrthographicWidth(4))
+	assert.Equal(t, 4.0, c.orthographicWidth)
+	assert.Error(t, c.SetOrthographicWidth(0))
+}
+
+func TestRayForSampleProjections(t *testing.T) {
+	type args struct {
+		projection  Projection
+		fieldOfView float64
+		filmX       float64
+		filmY       float64
+	}
+	tests := []struct {
+		name string
+		args args
+		want *ray.Ray
+	}{
+		{
+			name: "orthographic ray through the center of the canvas",
+			args: args{projection: OrthographicProjection, fieldOfView: math.Pi / 2,
+				filmX: 100, filmY: 50},
+			want: ray.NewRay(*point.NewPoint(0, 0, 0), *vector.NewVector(0, 0, -1)),
+		},
+		{
+			name: "orthographic ray through the corner of the canvas",
+			args: args{projection: OrthographicProjection, fieldOfView: math.Pi / 2,
+				filmX: 0, filmY: 0},
+			want: ray.NewRay(*point.NewPoint(1, 0.5, 0), *vector.NewVector(0, 0, -1)),
+		},
+		{
+			name: "equidistant fisheye ray through the center of the image circle",
+			args: args{projection: FisheyeEquidistantProjection, fieldOfView: math.Pi,
+				filmX: 100, filmY: 50},
+			want: ray.NewRay(*point.NewPoint(0, 0, 0), *vector.NewVector(0, 0, -1)),
+		},
+		{
+			name: "equidistant fisheye ray through the top of the image circle",
+			args: args{projection: FisheyeEquidistantProjection, fieldOfView: math.Pi,
+				filmX: 100, filmY: 0},
+			want: ray.NewRay(*point.NewPoint(0, 0, 0), *vector.NewVector(0, 1, 0)),
+		},
+		{
+			name: "equidistant fisheye ray halfway to the edge of the image circle",
+			args: args{projection: FisheyeEquidistantProjection, fieldOfView: math.Pi,
+				filmX: 125, filmY: 50},
+			want: ray.NewRay(*point.NewPoint(0, 0, 0),
+				*vector.NewVector(-1*math.Sqrt(2)/2, 0, -1*math.Sqrt(2)/2)),
+		},
+		{
+			name: "equisolid fisheye ray halfway to the edge of the image circle",
+			args: args{projection: FisheyeEquisolidProjection, fieldOfView: math.Pi,
+				filmX: 125, filmY: 50},
+			want: ray.NewRay(*point.NewPoint(0, 0, 0),
+				*vector.NewVector(-1*math.Sin(2*math.Asin(0.5*math.Sin(math.Pi/4))), 0,
+					-1*math.Cos(2*math.Asin(0.5*math.Sin(math.Pi/4))))),
+		},
+		{
+			name: "equirectangular ray through the center of the canvas",
+			args: args{projection: EquirectangularProjection, filmX: 100, filmY: 50},
+			want: ray.NewRay(*point.NewPoint(0, 0, 0), *vector.NewVector(0, 0, -1)),
+		},
+		{
+			name: "equirectangular ray a quarter turn to the right",
+			args: args{projection: EquirectangularProjection, filmX: 150, filmY: 50},
+			want: ray.NewRay(*point.NewPoint(0, 0, 0), *vector.NewVector(-1, 0, 0)),
+		},
+		{
+			name: "equirectangular ray behind the camera",
+			args: args{projection: EquirectangularProjection, filmX: 0, filmY: 50},
+			want: ray.NewRay(*point.NewPoint(0, 0, 0), *vector.NewVector(0, 0, 1)),
+		},
+		{
+			name: "equirectangular ray straight up",
+			args: args{projection: EquirectangularProjection, filmX: 10, filmY: 0},
+			want: ray.NewRay(*point.NewPoint(0, 0, 0), *vector.NewVector(0, 1, 0)),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCamera(200, 100, tt.args.fieldOfView)
+			assert.NoError(t, c.SetProjection(tt.args.projection))
+
+			r, err := rayForSample(c, cameraSample{filmX: tt.args.filmX, filmY: tt.args.filmY})
+			assert.NoError(t, err)
+			if !assert.True(t, ray.Equals(tt.want, r)) {
+				assert.Equal(t, tt.want, r)
+			}
+		})
+	}
+}
+
+func TestRayForPixelOutsideFisheyeCircle(t *testing.T) {
+	c := NewCamera(200, 100, math.Pi)
+	assert.NoError(t, c.SetProjection(FisheyeEquidistantProjection))
+
+	r, err := RayForPixel(c, 0, 0)
+	assert.Equal(t, ErrOutsideImageCircle, err)
+	assert.Nil(t, r)
+
+	// The center of the image circle has a ray
+	r, err = RayForPixel(c, 100, 50)
+	assert.NoError(t, err)
+	assert.NotNil(t, r)
+}
+
+func TestRenderProjections(t *testing.T) {
+	// Look at the default world from its center, so that every
+	// direction sees the inside of the outer sphere.
+	projections := []Projection{
+		OrthographicProjection,
+		FisheyeEquidistantProjection,
+		FisheyeEquisolidProjection,
+		EquirectangularProjection,
+	}
+	for _, projection := range projections {
+		c, err := NewCameraWithTransform(20, 10, math.Pi, matrix.NewTranslationMatrix4(0, 0, -0.75))
+		assert.NoError(t, err)
+		assert.NoError(t, c.SetProjection(projection))
+		assert.NoError(t, c.SetOrthographicWidth(1))
+
+		image, err := Render(c, world.NewDefaultWorld())
+		assert.NoError(t, err)
+
+		center, err := image.PixelAt(10, 5)
+		assert.NoError(t, err)
+		assert.NotEqual(t, *color.NewColor(0, 0, 0), center)
+
+		// Fisheye projections leave the corners of the canvas black
+		corner, err := image.PixelAt(0, 0)
+		assert.NoError(t, err)
+		if projection == FisheyeEquidistantProjection || projection == FisheyeEquisolidProjection {
+			assert.Equal(t, *color.NewColor(0, 0, 0), corner)
+		} else {
+			assert.NotEqual(t, *color.NewColor(0, 0, 0), corner)
+		}
+	}
+}
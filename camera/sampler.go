@@ -0,0 +1,88 @@
+package camera
+
+import "math"
+
+// SamplingStrategy describes how the samples taken for a pixel are distributed over its area.
+type SamplingStrategy int
+
+const (
+	// RegularGridSampling places samples at the centers of the cells of an evenly spaced grid.
+	RegularGridSampling SamplingStrategy = iota
+
+	// JitteredSampling places one sample at a random location within
+	// each cell of an evenly spaced grid. It's also known as stratified sampling.
+	JitteredSampling
+
+	// RandomSampling places samples at random locations within the pixel.
+	RandomSampling
+)
+
+// sampleCount returns the number of samples that the passed strategy takes for
+// a pixel when asked to take n samples. Grid based strategies round n up to the
+// next perfect square so that the grid has the same number of rows and columns.
+func sampleCount(strategy SamplingStrategy, n int) int {
+	if strategy == RandomSampling {
+		return n
+	}
+
+	k := gridSize(n)
+	return k * k
+}
+
+// gridSize returns the number of rows and columns in the grid used to take n samples.
+func gridSize(n int) int {
+	return int(math.Ceil(math.Sqrt(float64(n))))
+}
+
+// samplePosition returns the location, in the range [0, 1) along each axis of a pixel,
+// of the i-th of n samples taken for the pixel using the passed strategy.
+func samplePosition(strategy SamplingStrategy, i, n int, rnd *sampleRand) (float64, float64) {
+	if strategy == RandomSampling {
+		return rnd.Float64(), rnd.Float64()
+	}
+
+	// Locate the grid cell that the sample belongs to
+	k := gridSize(n)
+	cellX := float64(i % k)
+	cellY := float64(i / k)
+
+	if strategy == JitteredSampling {
+		return (cellX + rnd.Float64()) / float64(k), (cellY + rnd.Float64()) / float64(k)
+	}
+
+	return (cellX + 0.5) / float64(k), (cellY + 0.5) / float64(k)
+}
+
+// sampleRand is a small, deterministic pseudo-random number generator.
+//
+// A new generator is seeded for each pixel from its location, which makes the samples
+// taken for a pixel independent of the order in which the pixels of an image are rendered.
+type sampleRand struct {
+	state uint64
+}
+
+// newSampleRand returns a new sampleRand seeded from the passed values. Each value is mixed
+// with the output of the generator, rather than its state, so that nearby seeds, such as
+// the locations of neighboring pixels, don't produce the same or correlated streams.
+func newSampleRand(seeds ...int) *sampleRand {
+	r := &sampleRand{}
+	for _, seed := range seeds {
+		r.state = r.next() ^ uint64(seed)
+	}
+	return r
+}
+
+// next advances the generator and returns the next pseudo-random 64 bit value.
+// It uses the SplitMix64 algorithm.
+func (r *sampleRand) next() uint64 {
+	r.state += 0x9e3779b97f4a7c15
+	z := r.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Float64 returns a pseudo-random number in the range [0, 1).
+func (r *sampleRand) Float64() float64 {
+	return float64(r.next()>>11) / (1 << 53)
+}
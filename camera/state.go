@@ -0,0 +1,182 @@
+package camera
+
+import (
+	"time"
+)
+
+// renderMode describes how a render distributes samples over passes.
+type renderMode int
+
+const (
+	// uniformRender takes every sample of every pixel in a single pass.
+	uniformRender renderMode = iota
+	// progressiveRender takes one sample of every pixel in each pass.
+	progressiveRender
+	// adaptiveRender takes samples of the pixels that need refinement in each pass.
+	adaptiveRender
+)
+
+// renderState holds the state of a render that is shared by its passes and tiles.
+type renderState struct {
+	mode renderMode
+	opts *RenderOptions
+	// The film that the samples of committed tiles are merged into
+	film *film
+	// The statistics of the samples of each pixel of committed tiles
+	stats   []pixelStats
+	tracker *progressTracker
+	// The pass being rendered
+	pass int
+	// The pixels to sample for each tile of the pass being rendered,
+	// or nil if every pixel of a tile is sampled
+	pending map[int][]int
+	// The indices of the tiles of the pass being rendered that have been committed
+	completed []int
+	// The checkpoint that the render resumes from, until the pass that it resumes has started
+	resume *Checkpoint
+	// The time at which the last checkpoint was taken
+	lastCheckpoint time.Time
+}
+
+// newRenderState returns the state of a new render by the passed camera using the passed options.
+func newRenderState(c *Camera, opts *RenderOptions) (*renderState, error) {
+	mode := uniformRender
+	if c.adaptive != nil {
+		mode = adaptiveRender
+	} else if opts.Progressive {
+		mode = progressiveRender
+	}
+
+	rs := &renderState{
+		mode:           mode,
+		opts:           opts,
+		film:           newFilm(c.horizontalSizeInPixels, c.verticalSizeInPixels, c.filter),
+		stats:          make([]pixelStats, c.horizontalSizeInPixels*c.verticalSizeInPixels),
+		tracker:        newProgressTracker(opts.Progress),
+		lastCheckpoint: time.Now(),
+	}
+	rs.film.aov = newAOVFilm(c.aovs, len(rs.stats))
+	rs.film.crypto = newCryptoFilm(c.cryptomatteRank, len(rs.stats))
+
+	if opts.Resume != nil {
+		err := rs.restore(c, opts.Resume)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return rs, nil
+}
+
+// resumePass returns the pass that the render starts with,
+// which is 0 unless the render is resumed from a checkpoint.
+func (rs *renderState) resumePass() int {
+	if rs.resume == nil {
+		return 0
+	}
+
+	return rs.resume.Pass
+}
+
+// resumePending returns the set of pixels to sample in the passed pass of an adaptive render
+// that is resumed from a checkpoint taken during that pass, or nil if the pass isn't resumed.
+func (rs *renderState) resumePending(pass int) map[int]bool {
+	if rs.resume == nil || rs.resume.Pass != pass || rs.mode != adaptiveRender {
+		return nil
+	}
+
+	pending := make(map[int]bool, len(rs.resume.PendingPixels))
+	for _, idx := range rs.resume.PendingPixels {
+		pending[idx] = true
+	}
+	return pending
+}
+
+// startPass starts the passed pass of the render, which samples the passed
+// pixels of each tile, or every pixel of each tile if pending is nil.
+func (rs *renderState) startPass(pass int, pending map[int][]int) {
+	rs.pass = pass
+	rs.pending = pending
+	rs.completed = nil
+
+	// A resumed pass starts with the tiles that were completed before it was interrupted
+	if rs.resume != nil && rs.resume.Pass == pass {
+		rs.completed = append(rs.completed, rs.resume.CompletedTiles...)
+		rs.resume = nil
+	}
+}
+
+// skipCompleted returns the passed tiles other than those that have been committed
+// in the current pass, and counts the committed tiles as done in the progress.
+func (rs *renderState) skipCompleted(ts []tile) []tile {
+	if len(rs.completed) == 0 {
+		return ts
+	}
+
+	completed := make(map[int]bool, len(rs.completed))
+	for _, idx := range rs.completed {
+		completed[idx] = true
+	}
+
+	var remaining []tile
+	for _, t := range ts {
+		if completed[t.index] {
+			rs.tracker.skipTile(rs.tilePixels(t))
+		} else {
+			remaining = append(remaining, t)
+		}
+	}
+
+	return remaining
+}
+
+// tilePixels returns the number of pixels of the passed tile that are sampled in the current pass.
+func (rs *renderState) tilePixels(t tile) int {
+	if rs.pending == nil {
+		return (t.x1 - t.x0) * (t.y1 - t.y0)
+	}
+
+	return len(rs.pending[t.index])
+}
+
+// tileStats returns a copy of the statistics of the pixels of the passed tile.
+func (rs *renderState) tileStats(c *Camera, t tile) []pixelStats {
+	stats := make([]pixelStats, (t.x1-t.x0)*(t.y1-t.y0))
+	for y := t.y0; y < t.y1; y++ {
+		for x := t.x0; x < t.x1; x++ {
+			stats[t.pixelIndex(x, y)] = rs.stats[y*c.horizontalSizeInPixels+x]
+		}
+	}
+
+	return stats
+}
+
+// commitTile merges the film and statistics of the passed rendered tile
+// into the render, and takes a checkpoint if one is due.
+func (rs *renderState) commitTile(c *Camera, result tileResult) error {
+	t := result.tile
+	rs.film.merge(result.film)
+	for y := t.y0; y < t.y1; y++ {
+		for x := t.x0; x < t.x1; x++ {
+			rs.stats[y*c.horizontalSizeInPixels+x] = result.stats[t.pixelIndex(x, y)]
+		}
+	}
+	rs.completed = append(rs.completed, t.index)
+
+	if rs.opts.Checkpoint == nil || time.Since(rs.lastCheckpoint) < rs.opts.CheckpointInterval {
+		return nil
+	}
+
+	rs.lastCheckpoint = time.Now()
+	return rs.opts.Checkpoint(rs.checkpoint(c))
+}
+
+// takeSnapshot passes a snapshot of the image rendered so far by the passed camera to the
+// options' snapshot function, if there is one, after the passed pass has been rendered.
+func (rs *renderState) takeSnapshot(c *Camera, pass int) error {
+	if rs.opts.Snapshot == nil {
+		return nil
+	}
+
+	return rs.opts.Snapshot(pass, rs.film.toCanvas(c.outputTransform))
+}
@@ -0,0 +1,212 @@
+package camera
+
+import (
+	"errors"
+	"github.com/austingebauer/go-ray-tracer/canvas"
+	"github.com/austingebauer/go-ray-tracer/matrix"
+	"github.com/austingebauer/go-ray-tracer/point"
+	"github.com/austingebauer/go-ray-tracer/vector"
+	"github.com/austingebauer/go-ray-tracer/world"
+)
+
+const (
+	// DefaultInterpupillaryDistance is the interpupillary distance of a new StereoRig,
+	// which is the average distance between a person's eyes in meters.
+	DefaultInterpupillaryDistance = 0.064
+)
+
+// StereoLayout describes how the left and right eye views of a stereo pair
+// are arranged when they are combined into a single canvas.
+type StereoLayout int
+
+const (
+	// SideBySideLayout places the left eye view on the left half of the canvas
+	// and the right eye view on the right half.
+	SideBySideLayout StereoLayout = iota
+
+	// TopBottomLayout places the left eye view on the top half of the canvas
+	// and the right eye view on the bottom half.
+	TopBottomLayout
+)
+
+// StereoRig is a pair of cameras that view a scene from the positions of a viewer's
+// left and right eyes, which produces a stereo pair of images for virtual reality.
+type StereoRig struct {
+	// The horizontal size in pixels of each eye's view
+	horizontalSizeInPixels int
+	// The vertical size in pixels of each eye's view
+	verticalSizeInPixels int
+	// An angle that describes how much each eye can see
+	fieldOfView float64
+	// The point midway between the eyes
+	from point.Point
+	// The point in the scene that the rig looks at
+	to point.Point
+	// The direction that is up for the rig
+	up vector.Vector
+	// The distance between the eyes
+	interpupillaryDistance float64
+	// The distance from the eyes at which their views converge
+	convergenceDistance float64
+	// The projection used by both eyes
+	projection Projection
+}
+
+// NewStereoRig returns a new stereo rig with eyes of the passed horizontal and vertical
+// size in pixels and field of view angle. The eyes are placed on either side of the from
+// point, and look toward the to point with the up vector pointing up, as they would with
+// matrix.ViewTransform.
+//
+// The eyes are DefaultInterpupillaryDistance apart, and their views
+// converge at the distance between the from and to points.
+func NewStereoRig(horizontalSize int, verticalSize int, fieldOfView float64,
+	from, to point.Point, up vector.Vector) *StereoRig {
+	return &StereoRig{
+		horizontalSizeInPixels: horizontalSize,
+		verticalSizeInPixels:   verticalSize,
+		fieldOfView:            fieldOfView,
+		from:                   from,
+		to:                     to,
+		up:                     up,
+		interpupillaryDistance: DefaultInterpupillaryDistance,
+		convergenceDistance:    point.Subtract(to, from).Magnitude(),
+		projection:             PerspectiveProjection,
+	}
+}
+
+// SetInterpupillaryDistance sets the distance between the eyes of the rig.
+func (r *StereoRig) SetInterpupillaryDistance(distance float64) error {
+	if distance < 0 {
+		return errors.New("interpupillary distance must not be negative")
+	}
+
+	r.interpupillaryDistance = distance
+	return nil
+}
+
+// SetConvergenceDistance sets the distance from the rig at which the views of its eyes
+// converge. Objects at the convergence distance appear at the depth of the screen, nearer
+// objects appear in front of it, and farther objects appear behind it.
+//
+// The eyes look in parallel and their canvases are shifted toward each other, which avoids
+// the vertical misalignment caused by turning the eyes inward. The convergence distance
+// isn't used by an equirectangular rig, whose views converge at infinity.
+func (r *StereoRig) SetConvergenceDistance(distance float64) error {
+	if distance <= 0 {
+		return errors.New("convergence distance must be greater than zero")
+	}
+
+	r.convergenceDistance = distance
+	return nil
+}
+
+// SetProjection sets the projection used by both eyes of the rig. A rig using the
+// equirectangular projection produces an omni-directional stereo panorama.
+func (r *StereoRig) SetProjection(p Projection) error {
+	if p != PerspectiveProjection && p != EquirectangularProjection {
+		return errors.New("a stereo rig supports perspective and equirectangular projections")
+	}
+
+	r.projection = p
+	return nil
+}
+
+// Cameras returns new cameras for the left and right eyes of the rig.
+// The cameras may be configured further before rendering with them.
+func (r *StereoRig) Cameras() (*Camera, *Camera, error) {
+	left, err := r.eyeCamera(1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	right, err := r.eyeCamera(-1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return left, right, nil
+}
+
+// eyeCamera returns a new camera for one of the eyes of the rig.
+// The side is 1 for the left eye and -1 for the right eye.
+func (r *StereoRig) eyeCamera(side float64) (*Camera, error) {
+	halfDistance := side * r.interpupillaryDistance / 2
+
+	// An omni-directional stereo eye is centered on the rig and offsets each of its rays
+	if r.projection == EquirectangularProjection {
+		c, err := NewCameraWithTransform(r.horizontalSizeInPixels, r.verticalSizeInPixels,
+			r.fieldOfView, matrix.ViewTransformMatrix4(r.from, r.to, r.up))
+		if err != nil {
+			return nil, err
+		}
+		c.stereoEyeOffset = halfDistance
+		return c, c.SetProjection(EquirectangularProjection)
+	}
+
+	// Compute the forward and left vectors of the rig in the same way as the
+	// view transform, and then move the eye to the side along the left vector.
+	forwardVec := point.Subtract(r.to, r.from).Normalize()
+	leftVec := vector.CrossProduct(*forwardVec, *vector.Normalize(r.up))
+	eye := point.Add(&r.from, vector.Scale(leftVec, halfDistance))
+
+	// The eye looks parallel to the rig, so its canvas is shifted toward the other eye
+	// in order to center the point straight ahead of the rig at the convergence distance.
+	c, err := NewCameraWithTransform(r.horizontalSizeInPixels, r.verticalSizeInPixels,
+		r.fieldOfView, matrix.ViewTransformMatrix4(*eye, *point.Add(eye, forwardVec), r.up))
+	if err != nil {
+		return nil, err
+	}
+	c.lensShiftX = -1 * halfDistance / r.convergenceDistance
+	return c, nil
+}
+
+// RenderStereo uses the passed left and right eye cameras to render
+// the passed world into a separate canvas for each eye.
+func RenderStereo(left, right *Camera, w *world.World) (*canvas.Canvas, *canvas.Canvas, error) {
+	leftImage, err := Render(left, w)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rightImage, err := Render(right, w)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return leftImage, rightImage, nil
+}
+
+// CombineStereo returns a new canvas that contains the passed left
+// and right eye views arranged using the passed layout. The combined
+// canvas has the output transform of the left eye view, and has alpha
+// if either view has alpha.
+func CombineStereo(left, right *canvas.Canvas, layout StereoLayout) (*canvas.Canvas, error) {
+	if left.Width != right.Width || left.Height != right.Height {
+		return nil, errors.New("left and right eye views must have the same size")
+	}
+
+	// The offset of the right eye view within the combined canvas
+	rightX, rightY := left.Width, 0
+	combined := canvas.NewCanvas(left.Width*2, left.Height)
+	if layout == TopBottomLayout {
+		rightX, rightY = 0, left.Height
+		combined = canvas.NewCanvas(left.Width, left.Height*2)
+	}
+	combined.OutputTransform = left.OutputTransform
+
+	for y := 0; y < left.Height; y++ {
+		for x := 0; x < left.Width; x++ {
+			combined.Pixels[y][x] = left.Pixels[y][x]
+			combined.Pixels[y+rightY][x+rightX] = right.Pixels[y][x]
+
+			if left.Alpha != nil || right.Alpha != nil {
+				leftAlpha, _ := left.AlphaAt(x, y)
+				rightAlpha, _ := right.AlphaAt(x, y)
+				_ = combined.WriteAlpha(x, y, leftAlpha)
+				_ = combined.WriteAlpha(x+rightX, y+rightY, rightAlpha)
+			}
+		}
+	}
+
+	return combined, nil
+}
@@ -0,0 +1,162 @@
+package camera
+
+import (
+	"github.com/austingebauer/go-ray-tracer/canvas"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/austingebauer/go-ray-tracer/point"
+	"github.com/austingebauer/go-ray-tracer/vector"
+	"github.com/austingebauer/go-ray-tracer/world"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestNewStereoRig(t *testing.T) {
+	r := NewStereoRig(160, 120, math.Pi/2,
+		*point.NewPoint(0, 0, -5), *point.NewPoint(0, 0, 0), *vector.NewVector(0, 1, 0))
+	assert.Equal(t, DefaultInterpupillaryDistance, r.interpupillaryDistance)
+	assert.Equal(t, 5.0, r.convergenceDistance)
+	assert.Equal(t, PerspectiveProjection, r.projection)
+
+	assert.NoError(t, r.SetInterpupillaryDistance(0.5))
+	assert.Equal(t, 0.5, r.interpupillaryDistance)
+	assert.Error(t, r.SetInterpupillaryDistance(-1))
+
+	assert.NoError(t, r.SetConvergenceDistance(10))
+	assert.Equal(t, 10.0, r.convergenceDistance)
+	assert.Error(t, r.SetConvergenceDistance(0))
+
+	assert.NoError(t, r.SetProjection(EquirectangularProjection))
+	assert.Equal(t, EquirectangularProjection, r.projection)
+	assert.Error(t, r.SetProjection(FisheyeEquidistantProjection))
+}
+
+func TestStereoRig_Cameras(t *testing.T) {
+	r := NewStereoRig(11, 11, math.Pi/2,
+		*point.NewPoint(0, 0, -5), *point.NewPoint(0, 0, 0), *vector.NewVector(0, 1, 0))
+	assert.NoError(t, r.SetInterpupillaryDistance(0.5))
+
+	left, right, err := r.Cameras()
+	assert.NoError(t, err)
+
+	// Each eye is offset to its side of the rig, and the rays through the
+	// centers of their canvases converge at the point the rig looks at.
+	tests := []struct {
+		name   string
+		c      *Camera
+		origin *point.Point
+	}{
+		{
+			name:   "left eye camera",
+			c:      left,
+			origin: point.NewPoint(-0.25, 0, -5),
+		},
+		{
+			name:   "right eye camera",
+			c:      right,
+			origin: point.NewPoint(0.25, 0, -5),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ray, err := RayForPixel(tt.c, 5, 5)
+			assert.NoError(t, err)
+			assert.True(t, tt.origin.Equals(ray.Origin))
+
+			towardTarget := vector.Normalize(*point.Subtract(*point.NewPoint(0, 0, 0), *ray.Origin))
+			assert.True(t, towardTarget.Equals(ray.Direction))
+		})
+	}
+}
+
+func TestStereoRig_CamerasOmniDirectional(t *testing.T) {
+	r := NewStereoRig(40, 20, math.Pi/2,
+		*point.NewPoint(0, 0, 0), *point.NewPoint(0, 0, 1), *vector.NewVector(0, 1, 0))
+	assert.NoError(t, r.SetInterpupillaryDistance(0.5))
+	assert.NoError(t, r.SetProjection(EquirectangularProjection))
+
+	left, right, err := r.Cameras()
+	assert.NoError(t, err)
+
+	// Looking forward, the eyes are offset to either side
+	leftRay, err := rayForSample(left, cameraSample{filmX: 20, filmY: 10})
+	assert.NoError(t, err)
+	rightRay, err := rayForSample(right, cameraSample{filmX: 20, filmY: 10})
+	assert.NoError(t, err)
+	assert.True(t, point.NewPoint(-0.25, 0, 0).Equals(leftRay.Origin))
+	assert.True(t, point.NewPoint(0.25, 0, 0).Equals(rightRay.Origin))
+	assert.True(t, leftRay.Direction.Equals(rightRay.Direction))
+
+	// Looking behind, the eyes have swapped sides
+	leftRay, err = rayForSample(left, cameraSample{filmX: 0, filmY: 10})
+	assert.NoError(t, err)
+	assert.True(t, point.NewPoint(0.25, 0, 0).Equals(leftRay.Origin))
+	assert.True(t, vector.NewVector(0, 0, -1).Equals(leftRay.Direction))
+}
+
+func TestRenderStereo(t *testing.T) {
+	r := NewStereoRig(11, 11, math.Pi/2,
+		*point.NewPoint(0, 0, -5), *point.NewPoint(0, 0, 0), *vector.NewVector(0, 1, 0))
+	assert.NoError(t, r.SetInterpupillaryDistance(1))
+	left, right, err := r.Cameras()
+	assert.NoError(t, err)
+
+	leftImage, rightImage, err := RenderStereo(left, right, world.NewDefaultWorld())
+	assert.NoError(t, err)
+	assert.NotEqual(t, leftImage.Pixels, rightImage.Pixels)
+
+	// The sphere is at the convergence distance, so it's centered in both views
+	for _, image := range []*canvas.Canvas{leftImage, rightImage} {
+		clr, err := image.PixelAt(5, 5)
+		assert.NoError(t, err)
+		assert.NotEqual(t, *color.NewColor(0, 0, 0), clr)
+	}
+}
+
+func TestCombineStereo(t *testing.T) {
+	red := *color.NewColor(1, 0, 0)
+	blue := *color.NewColor(0, 0, 1)
+	left := canvas.NewCanvas(3, 2)
+	right := canvas.NewCanvas(3, 2)
+	assert.NoError(t, left.WritePixel(2, 1, red))
+	assert.NoError(t, right.WritePixel(2, 1, blue))
+
+	tests := []struct {
+		name       string
+		layout     StereoLayout
+		wantWidth  int
+		wantHeight int
+		rightX     int
+		rightY     int
+	}{
+		{
+			name:       "side by side stereo layout",
+			layout:     SideBySideLayout,
+			wantWidth:  6,
+			wantHeight: 2,
+			rightX:     5,
+			rightY:     1,
+		},
+		{
+			name:       "top bottom stereo layout",
+			layout:     TopBottomLayout,
+			wantWidth:  3,
+			wantHeight: 4,
+			rightX:     2,
+			rightY:     3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			combined, err := CombineStereo(left, right, tt.layout)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantWidth, combined.Width)
+			assert.Equal(t, tt.wantHeight, combined.Height)
+			assert.Equal(t, red, combined.Pixels[1][2])
+			assert.Equal(t, blue, combined.Pixels[tt.rightY][tt.rightX])
+		})
+	}
+
+	_, err := CombineStereo(left, canvas.NewCanvas(2, 2), SideBySideLayout)
+	assert.Error(t, err)
+}
@@ -0,0 +1,340 @@
+package camera
+
+import (
+	"context"
+	"errors"
+	"github.com/austingebauer/go-ray-tracer/world"
+	"math"
+	"runtime"
+	"sort"
+)
+
+const (
+	// DefaultTileSize is the width and height, in pixels, of the tiles rendered by a new camera.
+	DefaultTileSize = 16
+)
+
+// TileOrder describes the order in which the tiles of an image are rendered.
+type TileOrder int
+
+const (
+	// ScanlineTileOrder renders tiles from left to right, and then from top to bottom.
+	ScanlineTileOrder TileOrder = iota
+
+	// SpiralTileOrder renders the tile at the center of the image first, and then
+	// spirals outward, so that the center of the image is rendered before its edges.
+	SpiralTileOrder
+
+	// HilbertTileOrder renders tiles along a Hilbert curve, which keeps consecutive
+	// tiles close together and so improves the coherence of the scene data they use.
+	HilbertTileOrder
+)
+
+// tile is a rectangular region of pixels of an image that is rendered as a unit.
+type tile struct {
+	// The position of the tile when the tiles are in scanline order
+	index int
+	// The top left pixel of the tile
+	x0, y0 int
+	// The pixel past the bottom right pixel of the tile
+	x1, y1 int
+}
+
+// SetTiles sets the width and height, in pixels, of the tiles that the camera divides its
+// image into, and the order in which the tiles are rendered. The order in which tiles are
+// rendered doesn't change the rendered image.
+//
+// By default, a camera renders tiles of DefaultTileSize pixels in scanline order.
+func (c *Camera) SetTiles(size int, order TileOrder) error {
+	if size < 1 {
+		return errors.New("tile size must be at least 1")
+	}
+	if order < ScanlineTileOrder || order > HilbertTileOrder {
+		return errors.New("unknown tile order")
+	}
+
+	c.tileSize = size
+	c.tileOrder = order
+	return nil
+}
+
+// SetWorkers sets the number of goroutines that render tiles in parallel. A value of 0
+// uses runtime.GOMAXPROCS, which is the default. A value of 1 renders serially. The
+// number of workers doesn't change the rendered image.
+func (c *Camera) SetWorkers(workers int) error {
+	if workers < 0 {
+		return errors.New("workers must not be negative")
+	}
+
+	c.workers = workers
+	return nil
+}
+
+// workerCount returns the number of goroutines that the camera renders tiles with.
+func (c *Camera) workerCount() int {
+	if c.workers == 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+
+	return c.workers
+}
+
+// tiles returns the tiles of the passed camera's image in the camera's tile order.
+func tiles(c *Camera) []tile {
+	cols := (c.horizontalSizeInPixels + c.tileSize - 1) / c.tileSize
+	rows := (c.verticalSizeInPixels + c.tileSize - 1) / c.tileSize
+
+	var positions [][2]int
+	switch c.tileOrder {
+	case SpiralTileOrder:
+		positions = spiralPositions(cols, rows)
+	case HilbertTileOrder:
+		positions = hilbertPositions(cols, rows)
+	default:
+		positions = scanlinePositions(cols, rows)
+	}
+
+	ts := make([]tile, len(positions))
+	for i, pos := range positions {
+		col, row := pos[0], pos[1]
+		ts[i] = tile{
+			index: row*cols + col,
+			x0:    col * c.tileSize,
+			y0:    row * c.tileSize,
+			x1:    minInt((col+1)*c.tileSize, c.horizontalSizeInPixels),
+			y1:    minInt((row+1)*c.tileSize, c.verticalSizeInPixels),
+		}
+	}
+
+	return ts
+}
+
+// scanlinePositions returns the column and row of every tile of a
+// grid of the passed size, from left to right and top to bottom.
+func scanlinePositions(cols, rows int) [][2]int {
+	positions := make([][2]int, 0, cols*rows)
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			positions = append(positions, [2]int{col, row})
+		}
+	}
+
+	return positions
+}
+
+// spiralPositions returns the column and row of every tile of a grid of the passed
+// size, spiraling outward from the center tile. Positions of the spiral that fall
+// outside of the grid are skipped.
+func spiralPositions(cols, rows int) [][2]int {
+	positions := make([][2]int, 0, cols*rows)
+	col, row := (cols-1)/2, (rows-1)/2
+
+	// Walk right, down, left, and up, increasing the length of the
+	// walk after every second turn, until every tile has been visited.
+	directions := [][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+	for length, turn := 1, 0; len(positions) < cols*rows; turn++ {
+		dir := directions[turn%4]
+		for step := 0; step < length && len(positions) < cols*rows; step++ {
+			if col >= 0 && col < cols && row >= 0 && row < rows {
+				positions = append(positions, [2]int{col, row})
+			}
+			col += dir[0]
+			row += dir[1]
+		}
+
+		if turn%2 == 1 {
+			length++
+		}
+	}
+
+	return positions
+}
+
+// hilbertPositions returns the column and row of every tile of a grid of the passed size,
+// ordered by their distance along a Hilbert curve that covers the grid.
+func hilbertPositions(cols, rows int) [][2]int {
+	// The curve covers a square grid whose side is a power of two
+	n := 1
+	for n < cols || n < rows {
+		n *= 2
+	}
+
+	positions := scanlinePositions(cols, rows)
+	sort.SliceStable(positions, func(i, j int) bool {
+		return hilbertDistance(n, positions[i][0], positions[i][1]) <
+			hilbertDistance(n, positions[j][0], positions[j][1])
+	})
+
+	return positions
+}
+
+// hilbertDistance returns the distance along the Hilbert curve that covers
+// a grid with a side of n, which is a power of two, to the cell at (x, y).
+func hilbertDistance(n, x, y int) int {
+	d := 0
+	for s := n / 2; s > 0; s /= 2 {
+		rx, ry := 0, 0
+		if x&s > 0 {
+			rx = 1
+		}
+		if y&s > 0 {
+			ry = 1
+		}
+		d += s * s * ((3 * rx) ^ ry)
+
+		// Rotate the quadrant so that the curve within it has the standard orientation
+		if ry == 0 {
+			if rx == 1 {
+				x = n - 1 - x
+				y = n - 1 - y
+			}
+			x, y = y, x
+		}
+	}
+
+	return d
+}
+
+// tileFilm returns a new film for the passed tile of the passed camera's image,
+// which covers the tile and the pixels around it that its samples contribute to.
+func tileFilm(c *Camera, t tile) *film {
+	// A sample contributes to pixels whose centers are within the radius of the filter
+	margin := int(math.Ceil(c.filter.Radius() + 0.5))
+
+	x0 := maxInt(t.x0-margin, 0)
+	y0 := maxInt(t.y0-margin, 0)
+	x1 := minInt(t.x1+margin, c.horizontalSizeInPixels)
+	y1 := minInt(t.y1+margin, c.verticalSizeInPixels)
+
+	f := newRegionFilm(x0, y0, x1-x0, y1-y0, c.filter)
+	f.aov = newAOVFilm(c.aovs, f.width*f.height)
+	f.crypto = newCryptoFilm(c.cryptomatteRank, f.width*f.height)
+	return f
+}
+
+// pixelIndex returns the index of the pixel at (x, y) among the pixels of the tile.
+func (t tile) pixelIndex(x, y int) int {
+	return (y-t.y0)*(t.x1-t.x0) + (x - t.x0)
+}
+
+// tileResult is the film and pixel statistics that a worker rendered
+// for a tile, or the error that the worker encountered.
+type tileResult struct {
+	tile  tile
+	film  *film
+	stats []pixelStats
+	err   error
+}
+
+// renderTiles renders the passed tiles of the current pass of the passed render using a
+// pool of worker goroutines. Tiles that were completed before the render was resumed are
+// skipped. Each worker calls the passed render function to render a tile into a film and
+// pixel statistics of its own, which start as a copy of the render's statistics for the
+// pixels of the tile. The films and statistics are committed to the render in the order
+// of the tiles' indices, so the rendered image is the same regardless of the number of
+// workers and the order of the tiles. Each worker passes the render function a world
+// Buffer of its own, which is reused for the rays cast for each of the worker's tiles.
+//
+// The render function should return the context's error promptly when it is cancelled.
+func renderTiles(ctx context.Context, c *Camera, rs *renderState, ts []tile,
+	render func(ctx context.Context, t tile, tf *film, stats []pixelStats,
+		buf *world.Buffer) error) error {
+	ts = rs.skipCompleted(ts)
+	if len(ts) == 0 {
+		return ctx.Err()
+	}
+
+	workers := minInt(c.workerCount(), len(ts))
+
+	// Stop the workers early if a tile fails to render
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan tile)
+	go func() {
+		defer close(jobs)
+		for _, t := range ts {
+			select {
+			case jobs <- t:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	results := make(chan tileResult)
+	for i := 0; i < workers; i++ {
+		go func() {
+			buf := world.NewBuffer()
+			for t := range jobs {
+				tf := tileFilm(c, t)
+				stats := rs.tileStats(c, t)
+				err := render(ctx, t, tf, stats, buf)
+				select {
+				case results <- tileResult{tile: t, film: tf, stats: stats, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// Commit the tiles in the order of their indices, holding on
+	// to tiles that finish before the tiles preceding them.
+	indices := make([]int, len(ts))
+	for i, t := range ts {
+		indices[i] = t.index
+	}
+	sort.Ints(indices)
+
+	finished := make(map[int]tileResult)
+	next := 0
+	for range ts {
+		var result tileResult
+		select {
+		case result = <-results:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if result.err != nil {
+			return result.err
+		}
+
+		rs.tracker.tileDone(rs.tilePixels(result.tile), result.film.rays)
+		finished[result.tile.index] = result
+		for next < len(indices) {
+			committed, ok := finished[indices[next]]
+			if !ok {
+				break
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			err := rs.commitTile(c, committed)
+			if err != nil {
+				return err
+			}
+			delete(finished, indices[next])
+			next++
+		}
+	}
+
+	return nil
+}
+
+// minInt returns the smaller of the passed integers.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxInt returns the larger of the passed integers.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
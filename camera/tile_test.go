@@ -0,0 +1,186 @@
+package camera
+
+import (
+	"github.com/austingebauer/go-ray-tracer/matrix"
+	"github.com/austingebauer/go-ray-tracer/point"
+	"github.com/austingebauer/go-ray-tracer/vector"
+	"github.com/austingebauer/go-ray-tracer/world"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestCamera_SetTiles(t *testing.T) {
+	type args struct {
+		size  int
+		order TileOrder
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "tiles of 8 pixels in hilbert order",
+			args: args{
+				size:  8,
+				order: HilbertTileOrder,
+			},
+			wantErr: false,
+		},
+		{
+			name: "tiles of 0 pixels",
+			args: args{
+				size:  0,
+				order: ScanlineTileOrder,
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown tile order",
+			args: args{
+				size:  8,
+				order: TileOrder(42),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCamera(10, 10, math.Pi/2)
+			err := c.SetTiles(tt.args.size, tt.args.order)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Equal(t, DefaultTileSize, c.tileSize)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.args.size, c.tileSize)
+				assert.Equal(t, tt.args.order, c.tileOrder)
+			}
+		})
+	}
+}
+
+func TestCamera_SetWorkers(t *testing.T) {
+	c := NewCamera(10, 10, math.Pi/2)
+	assert.NoError(t, c.SetWorkers(4))
+	assert.Equal(t, 4, c.workerCount())
+	assert.NoError(t, c.SetWorkers(0))
+	assert.True(t, c.workerCount() >= 1)
+	assert.Error(t, c.SetWorkers(-1))
+}
+
+func TestTilePositions(t *testing.T) {
+	tests := []struct {
+		name      string
+		positions [][2]int
+		want      [][2]int
+	}{
+		{
+			name:      "scanline positions",
+			positions: scanlinePositions(3, 2),
+			want:      [][2]int{{0, 0}, {1, 0}, {2, 0}, {0, 1}, {1, 1}, {2, 1}},
+		},
+		{
+			name:      "spiral positions",
+			positions: spiralPositions(3, 3),
+			want: [][2]int{{1, 1}, {2, 1}, {2, 2}, {1, 2}, {0, 2},
+				{0, 1}, {0, 0}, {1, 0}, {2, 0}},
+		},
+		{
+			name:      "spiral positions skip positions outside of the grid",
+			positions: spiralPositions(4, 1),
+			want:      [][2]int{{1, 0}, {2, 0}, {0, 0}, {3, 0}},
+		},
+		{
+			name:      "hilbert positions",
+			positions: hilbertPositions(2, 2),
+			want:      [][2]int{{0, 0}, {0, 1}, {1, 1}, {1, 0}},
+		},
+		{
+			name:      "hilbert positions of a grid that isn't a power of two",
+			positions: hilbertPositions(3, 2),
+			want:      [][2]int{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {2, 1}, {2, 0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.positions)
+		})
+	}
+}
+
+func TestTiles(t *testing.T) {
+	c := NewCamera(10, 7, math.Pi/2)
+	assert.NoError(t, c.SetTiles(4, SpiralTileOrder))
+
+	// Every pixel is covered by exactly one tile
+	covered := make([]int, 10*7)
+	for _, tile := range tiles(c) {
+		for y := tile.y0; y < tile.y1; y++ {
+			for x := tile.x0; x < tile.x1; x++ {
+				covered[y*10+x]++
+			}
+		}
+	}
+	for _, count := range covered {
+		assert.Equal(t, 1, count)
+	}
+}
+
+func TestRenderTiled(t *testing.T) {
+	newTestCamera := func() *Camera {
+		c, err := NewCameraWithTransform(23, 17, math.Pi/2,
+			matrix.ViewTransformMatrix4(
+				*point.NewPoint(0, 0, -5),
+				*point.NewPoint(0, 0, 0),
+				*vector.NewVector(0, 1, 0)))
+		assert.NoError(t, err)
+		assert.NoError(t, c.SetAntiAliasing(4, JitteredSampling, NewMitchellFilter(2, 1.0/3, 1.0/3)))
+		return c
+	}
+
+	configure := []struct {
+		name      string
+		configure func(c *Camera)
+	}{
+		{
+			name: "uniform sampling",
+			configure: func(c *Camera) {
+			},
+		},
+		{
+			name: "adaptive sampling",
+			configure: func(c *Camera) {
+				assert.NoError(t, c.SetAdaptiveSampling(4, 16, 0.01))
+			},
+		},
+	}
+	for _, cfg := range configure {
+		t.Run(cfg.name, func(t *testing.T) {
+			w := world.NewDefaultWorld()
+
+			// Render serially
+			serial := newTestCamera()
+			cfg.configure(serial)
+			assert.NoError(t, serial.SetWorkers(1))
+			assert.NoError(t, serial.SetTiles(5, ScanlineTileOrder))
+			want, wantHeatmap, err := RenderWithSampleHeatmap(serial, w)
+			assert.NoError(t, err)
+
+			// The image is the same regardless of the number of workers and the tile order
+			for _, order := range []TileOrder{ScanlineTileOrder, SpiralTileOrder, HilbertTileOrder} {
+				for _, workers := range []int{2, 8} {
+					c := newTestCamera()
+					cfg.configure(c)
+					assert.NoError(t, c.SetWorkers(workers))
+					assert.NoError(t, c.SetTiles(5, order))
+					got, gotHeatmap, err := RenderWithSampleHeatmap(c, w)
+					assert.NoError(t, err)
+					assert.Equal(t, want, got)
+					assert.Equal(t, wantHeatmap, gotHeatmap)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package canvas
+
+import (
+	"fmt"
+)
+
+// NewCanvasWithAlpha returns a new Canvas with the passed width and height,
+// whose pixels are transparent.
+func NewCanvasWithAlpha(width, height int) *Canvas {
+	c := NewCanvas(width, height)
+	c.Alpha = newAlphaPlane(width, height, 0)
+	return c
+}
+
+// newAlphaPlane returns the alpha of a canvas with the passed
+// width and height, with every pixel set to the passed alpha.
+func newAlphaPlane(width, height int, alpha float64) [][]float64 {
+	plane := make([][]float64, height)
+	values := make([]float64, width*height)
+	for i := range values {
+		values[i] = alpha
+	}
+	for y := range plane {
+		plane[y] = values[y*width : (y+1)*width]
+	}
+
+	return plane
+}
+
+// WriteAlpha writes the passed alpha, which must be in the range [0, 1], to the pixel
+// located at the passed x and y values. The color of the pixel must be premultiplied by
+// it. If the Canvas has no alpha, then every other pixel is made opaque.
+func (c *Canvas) WriteAlpha(x, y int, alpha float64) error {
+	err := c.ValidateInCanvasBounds(x, y)
+	if err != nil {
+		return err
+	}
+
+	if !(alpha >= 0 && alpha <= 1) {
+		return fmt.Errorf("alpha value '%v' must be between 0 and 1", alpha)
+	}
+
+	if c.Alpha == nil {
+		c.Alpha = newAlphaPlane(c.Width, c.Height, 1)
+	}
+	c.Alpha[y][x] = alpha
+	return nil
+}
+
+// AlphaAt returns the alpha of the pixel located at the passed x and y values,
+// which is 1 if the Canvas has no alpha.
+func (c *Canvas) AlphaAt(x, y int) (float64, error) {
+	err := c.ValidateInCanvasBounds(x, y)
+	if err != nil {
+		return 0, err
+	}
+
+	return c.alphaAt(x, y), nil
+}
+
+// alphaAt returns the alpha of the pixel located at the passed x and y values,
+// which is 1 if the Canvas has no alpha, without validating that it's in bounds.
+func (c *Canvas) alphaAt(x, y int) float64 {
+	if c.Alpha == nil {
+		return 1
+	}
+
+	return c.Alpha[y][x]
+}
@@ -0,0 +1,164 @@
+package canvas
+
+import (
+	"bytes"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/stretchr/testify/assert"
+	"image"
+	imagecolor "image/color"
+	"image/png"
+	"testing"
+)
+
+func TestNewCanvasWithAlpha(t *testing.T) {
+	c := NewCanvasWithAlpha(3, 2)
+	assert.Equal(t, 2, len(c.Alpha))
+	for _, row := range c.Alpha {
+		assert.Equal(t, []float64{0, 0, 0}, row)
+	}
+
+	alpha, err := c.AlphaAt(2, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, alpha)
+}
+
+func TestCanvas_WriteAlpha(t *testing.T) {
+	tests := []struct {
+		name    string
+		x       int
+		y       int
+		alpha   float64
+		wantErr bool
+	}{
+		{name: "translucent", x: 1, y: 0, alpha: 0.5},
+		{name: "transparent", x: 0, y: 1, alpha: 0},
+		{name: "out of bounds", x: 3, y: 0, alpha: 0.5, wantErr: true},
+		{name: "alpha too large", x: 0, y: 0, alpha: 1.5, wantErr: true},
+		{name: "negative alpha", x: 0, y: 0, alpha: -0.5, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCanvas(3, 2)
+			err := c.WriteAlpha(tt.x, tt.y, tt.alpha)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, c.Alpha)
+				return
+			}
+			assert.NoError(t, err)
+
+			// The other pixels of a canvas without alpha are made opaque
+			for y := 0; y < c.Height; y++ {
+				for x := 0; x < c.Width; x++ {
+					want := 1.0
+					if x == tt.x && y == tt.y {
+						want = tt.alpha
+					}
+					alpha, err := c.AlphaAt(x, y)
+					assert.NoError(t, err)
+					assert.Equal(t, want, alpha)
+				}
+			}
+		})
+	}
+
+	// A canvas without alpha is opaque
+	alpha, err := NewCanvas(1, 1).AlphaAt(0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, alpha)
+	_, err = NewCanvas(1, 1).AlphaAt(1, 0)
+	assert.Error(t, err)
+}
+
+// newTranslucentCanvas returns a canvas with an opaque red pixel, a half transparent
+// pixel whose premultiplied color is half green, and a transparent pixel.
+func newTranslucentCanvas(t *testing.T) *Canvas {
+	c := NewCanvasWithAlpha(3, 1)
+	assert.NoError(t, c.WritePixel(0, 0, *color.NewColor(1, 0, 0)))
+	assert.NoError(t, c.WriteAlpha(0, 0, 1))
+	assert.NoError(t, c.WritePixel(1, 0, *color.NewColor(0, 0.5, 0)))
+	assert.NoError(t, c.WriteAlpha(1, 0, 0.5))
+	return c
+}
+
+func TestCanvas_AtSetAlpha(t *testing.T) {
+	c := newTranslucentCanvas(t)
+	assert.Equal(t, imagecolor.RGBA64{R: 0xffff, A: 0xffff}, c.At(0, 0))
+	assert.Equal(t, imagecolor.RGBA64{G: 0x8000, A: 0x8000}, c.At(1, 0))
+	assert.Equal(t, imagecolor.RGBA64{}, c.At(2, 0))
+
+	// Setting a translucent color gives a canvas alpha
+	opaque := NewCanvas(2, 1)
+	opaque.Set(1, 0, imagecolor.NRGBA{G: 255, A: 51})
+	assert.True(t, color.Equals(*color.NewColor(0, 0.2, 0), opaque.Pixels[0][1]))
+	assert.Equal(t, [][]float64{{1, 0.2}}, opaque.Alpha)
+
+	// An image with alpha is read into a canvas with alpha
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, imagecolor.NRGBA{R: 255, A: 255})
+	img.Set(1, 0, imagecolor.NRGBA{B: 255, A: 102})
+	read := FromImage(img)
+	assert.Equal(t, [][]float64{{1, 0.4}}, read.Alpha)
+	assert.True(t, color.Equals(*color.NewColor(0, 0, 0.4), read.Pixels[0][1]))
+}
+
+func TestCanvas_WritePNGAlpha(t *testing.T) {
+	c := newTranslucentCanvas(t)
+	c.OutputTransform = &OutputTransform{SRGB: true}
+
+	writer := &bytes.Buffer{}
+	assert.NoError(t, c.WritePNG(writer))
+	img, err := png.Decode(writer)
+	assert.NoError(t, err)
+
+	// The colors are divided by their alpha before they're encoded
+	nrgba, ok := img.(*image.NRGBA)
+	assert.True(t, ok)
+	assert.Equal(t, imagecolor.NRGBA{R: 255, A: 255}, nrgba.NRGBAAt(0, 0))
+	assert.Equal(t, imagecolor.NRGBA{G: 255, A: 128}, nrgba.NRGBAAt(1, 0))
+	assert.Equal(t, imagecolor.NRGBA{}, nrgba.NRGBAAt(2, 0))
+
+	// An opaque canvas is written without alpha
+	writer.Reset()
+	assert.NoError(t, NewCanvas(2, 2).WritePNG(writer))
+	img, err = png.Decode(writer)
+	assert.NoError(t, err)
+	_, ok = img.(*image.RGBA)
+	assert.True(t, ok)
+}
+
+func TestCanvas_FlatWritersAlpha(t *testing.T) {
+	c := newTranslucentCanvas(t)
+	c.OutputTransform = &OutputTransform{SRGB: true}
+
+	// Writers without alpha composite the transformed colors over black
+	wantHalf := byte(127)
+	writer := &bytes.Buffer{}
+	assert.NoError(t, c.WritePPMBinary(writer))
+	assert.Equal(t, []byte{255, 0, 0, 0, wantHalf, 0, 0, 0, 0}, writer.Bytes()[len(writer.Bytes())-9:])
+
+	rgba := c.ToRGBA()
+	assert.Equal(t, []byte{255, 0, 0, 255, 0, wantHalf, 0, 128, 0, 0, 0, 0}, rgba.Pix)
+}
+
+func TestCanvas_WriteEXRAlpha(t *testing.T) {
+	c := newTranslucentCanvas(t)
+
+	for _, pixelType := range []EXRPixelType{EXRHalf, EXRFloat} {
+		writer := &bytes.Buffer{}
+		assert.NoError(t, c.WriteEXR(writer, pixelType, EXRZIPCompression))
+		assert.True(t, bytes.Contains(writer.Bytes(), []byte("A\x00")))
+
+		read, err := ReadEXR(writer)
+		assert.NoError(t, err)
+		assert.Equal(t, c.Alpha, read.Alpha)
+		assert.Equal(t, c.Pixels, read.Pixels)
+	}
+
+	// An opaque canvas is written without alpha
+	writer := &bytes.Buffer{}
+	assert.NoError(t, NewCanvas(1, 1).WriteEXR(writer, EXRHalf, EXRNoCompression))
+	read, err := ReadEXR(writer)
+	assert.NoError(t, err)
+	assert.Nil(t, read.Alpha)
+}
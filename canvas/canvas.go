@@ -6,9 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"github.com/austingebauer/go-ray-tracer/color"
-	"html/template"
 	"io"
 	"math"
+	"text/template"
 )
 
 // PixelMapTemplate is a template used for rendering a Canvas to a portable pixmap (PPM) file.
@@ -32,6 +32,16 @@ type Canvas struct {
 	PPMIdentifier string
 	MaxColorValue uint8
 	MinColorValue uint8
+
+	// Alpha, if not nil, holds the alpha of each pixel in the range [0, 1], where 0 is
+	// transparent and 1 is opaque. The colors of the Pixels are premultiplied by their
+	// alpha, so they are the colors of the pixels composited over black. If Alpha is nil,
+	// then every pixel is opaque.
+	Alpha [][]float64
+
+	// OutputTransform, if not nil, transforms the colors of the canvas when it's written
+	// to files with 8 bits per color component. The colors are otherwise clamped.
+	OutputTransform *OutputTransform
 }
 
 // NewCanvas returns a new Canvas with the passed Width and Height.
@@ -102,14 +112,17 @@ func (c *Canvas) ValidateInCanvasBounds(x, y int) error {
 	return nil
 }
 
-// ToPPM writes the current canvas to a file in the portable pixmap (PPM) format.
+// ToPPM writes the current canvas to a file in the portable pixmap (PPM) format using the
+// passed template, such as PixelMapTemplate. WritePPM and WritePPMBinary write the canvas
+// faster, and WritePPM also limits the lines of the file to 70 characters. ToPPM truncates
+// color values to integers, while the other writers round them to the nearest integer.
 func (c *Canvas) ToPPM(writer io.Writer, goTemplate string) error {
 	if writer == nil {
 		return errors.New("writer must not be nil")
 	}
 
 	funcMap := template.FuncMap{
-		"pixels": writePPMPixels,
+		"pixels": c.writePPMPixels,
 	}
 
 	tmpl, err := template.New(ppmID).Funcs(funcMap).Parse(goTemplate)
@@ -125,13 +138,16 @@ func (c *Canvas) ToPPM(writer io.Writer, goTemplate string) error {
 	return nil
 }
 
-// writePPMPixels returns a string containing rows of pixels with rgb values.
-func writePPMPixels(pixels [][]color.Color) string {
+// writePPMPixels returns a string containing rows of pixels with rgb values,
+// which are transformed by the OutputTransform of the Canvas.
+func (c *Canvas) writePPMPixels(pixels [][]color.Color) string {
 	pixelBytes := bytes.Buffer{}
-	for _, row := range pixels {
-		for _, colorVal := range row {
+	for y, row := range pixels {
+		for x, colorVal := range row {
+			colorVal = c.flatDisplayColor(colorVal, c.alphaAt(x, y))
 
-			// Convert RGB color values to 8 bit integer values [0-255]
+			// Convert RGB color values to 8 bit integer values [0-255], which truncates
+			// them, unlike the rounding of WritePPM and the other writers
 			redEightBit := int(math.Min(math.Max(minColorValue, colorVal.Red*maxColorValue),
 				maxColorValue))
 			greenEightBit := int(math.Min(math.Max(minColorValue, colorVal.Green*maxColorValue),
@@ -149,3 +165,10 @@ func writePPMPixels(pixels [][]color.Color) string {
 
 	return pixelBytes.String()
 }
+
+// scaleColorValue returns the passed color component, which is in the range [0, 1],
+// scaled to an integer in the range [0, maxValue]. Values outside of [0, 1] are clamped.
+func scaleColorValue(value float64, maxValue int) int {
+	scaled := math.Round(value * float64(maxValue))
+	return int(math.Min(math.Max(minColorValue, scaled), float64(maxValue)))
+}
@@ -291,6 +291,39 @@ func TestCanvas_ToPPM(t *testing.T) {
 	}
 }
 
+func TestCanvas_ToPPMTruncates(t *testing.T) {
+	c := NewCanvas(1, 1)
+	assert.NoError(t, c.WritePixel(0, 0, *color.NewColor(0.5, 0.999, 0.002)))
+
+	tests := []struct {
+		name  string
+		write func(w *bytes.Buffer) error
+		want  string
+	}{
+		{
+			name: "ToPPM truncates color values",
+			write: func(w *bytes.Buffer) error {
+				return c.ToPPM(w, PixelMapTemplate)
+			},
+			want: "P3\n1 1\n255\n127 254 0\n",
+		},
+		{
+			name: "WritePPM rounds color values",
+			write: func(w *bytes.Buffer) error {
+				return c.WritePPM(w)
+			},
+			want: "P3\n1 1\n255\n128 255 1\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &bytes.Buffer{}
+			assert.NoError(t, tt.write(writer))
+			assert.Equal(t, tt.want, writer.String())
+		})
+	}
+}
+
 func TestCanvas_ToPPMError(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -0,0 +1,367 @@
+package canvas
+
+import (
+	"errors"
+	"fmt"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"math"
+	"runtime"
+	"sync"
+)
+
+const (
+	// DefaultDenoiseRadius is the radius, in pixels, of the window of neighboring pixels
+	// that Denoise averages by default.
+	DefaultDenoiseRadius = 3
+	// minDenoiseAlbedo is the smallest albedo that colors are divided by before they're
+	// filtered, which keeps dark albedos from amplifying noise.
+	minDenoiseAlbedo = 0.01
+	// minDenoiseDepth is the smallest depth that differences in depth are relative to.
+	minDenoiseDepth = 1e-6
+	// minDenoiseVariance is added to the variance that differences in color are relative
+	// to, so that colors of pixels without noise are averaged only if they're nearly equal.
+	minDenoiseVariance = 1e-4
+)
+
+// DenoiseFeatures holds the feature buffers of a render that guide Denoise, such as the AOVs
+// of the render. Features are far less noisy than the colors of a render with few samples per
+// pixel, so they tell the edges of objects and materials apart from noise. Each feature may be
+// nil, and each must have the same size as the canvas that is denoised.
+type DenoiseFeatures struct {
+	// Albedo holds the colors of the materials of the surfaces, which may be premultiplied by
+	// alpha. The colors are divided by the albedo before they're filtered and multiplied by it
+	// afterwards, so that the textures and edges of materials stay sharp.
+	Albedo *Canvas
+	// Normal holds the shading normals of the surfaces, with their x, y, and z components in
+	// the red, green, and blue components of the canvas.
+	Normal *Canvas
+	// Depth holds the distance from the camera to the surfaces in the red component of the
+	// canvas, where 0 is no surface.
+	Depth *Canvas
+	// Variance holds the variance of the mean of the samples of each color component of each
+	// pixel, which estimates its noise. With it, neighbors are averaged when their colors
+	// differ by about as much as the noise, so pixels without noise are kept as they are.
+	Variance *Canvas
+}
+
+// DenoiseOptions holds the settings of Denoise. Each sigma is the difference between a pixel
+// and its neighbor at which the neighbor's weight falls to about 61% of its greatest weight,
+// so smaller sigmas preserve more detail and remove less noise.
+type DenoiseOptions struct {
+	// Radius is the radius, in pixels, of the square window of neighboring pixels that are
+	// averaged. Larger windows remove more noise, but take longer.
+	Radius int
+	// SpatialSigma is the sigma of the distance, in pixels, to the neighbor.
+	SpatialSigma float64
+	// ColorSigma is the sigma of the difference in color, which is measured between the
+	// colors blurred over 3x3 pixels and compressed into the range [0, 1). It's only used
+	// if there's no Variance feature.
+	ColorSigma float64
+	// NoiseSigma is the sigma of the difference in color in units of the standard deviation
+	// of the noise of the pixels. It's only used if there's a Variance feature.
+	NoiseSigma float64
+	// AlbedoSigma is the sigma of the difference in albedo.
+	AlbedoSigma float64
+	// NormalSigma is the sigma of the distance between the normals.
+	NormalSigma float64
+	// DepthSigma is the sigma of the difference in depth relative to the depth of the pixel.
+	DepthSigma float64
+}
+
+// NewDenoiseOptions returns new DenoiseOptions with settings that suit renders
+// with 4 to 16 samples per pixel, whose features include their variance.
+func NewDenoiseOptions() *DenoiseOptions {
+	return &DenoiseOptions{
+		Radius:       DefaultDenoiseRadius,
+		SpatialSigma: DefaultDenoiseRadius / 2.0,
+		ColorSigma:   0.1,
+		NoiseSigma:   4,
+		AlbedoSigma:  0.05,
+		NormalSigma:  0.25,
+		DepthSigma:   0.05,
+	}
+}
+
+// Denoise returns a new canvas with the noise of the canvas removed, which is meant for renders
+// with few samples per pixel. Each pixel is replaced by a weighted average of its neighbors,
+// where neighbors with different features or colors have less weight. This is known as
+// a joint (or cross) bilateral filter. With a Variance feature, colors are compared relative
+// to their noise, which keeps the filter from blurring pixels that have no noise. The colors
+// and alpha of the canvas are averaged together, and the returned canvas has the output
+// transform of the canvas. The options may be nil to use NewDenoiseOptions.
+func (c *Canvas) Denoise(features DenoiseFeatures, opts *DenoiseOptions) (*Canvas, error) {
+	if opts == nil {
+		opts = NewDenoiseOptions()
+	}
+	if opts.Radius < 0 {
+		return nil, fmt.Errorf("denoise radius '%v' must not be negative", opts.Radius)
+	}
+	for _, sigma := range []float64{opts.SpatialSigma, opts.ColorSigma, opts.NoiseSigma,
+		opts.AlbedoSigma, opts.NormalSigma, opts.DepthSigma} {
+		if !(sigma > 0) {
+			return nil, errors.New("denoise sigmas must be greater than zero")
+		}
+	}
+	for _, feature := range []*Canvas{features.Albedo, features.Normal, features.Depth,
+		features.Variance} {
+		if feature != nil && (feature.Width != c.Width || feature.Height != c.Height) {
+			return nil, fmt.Errorf("feature of %dx%d pixels must be the size of the canvas, %dx%d",
+				feature.Width, feature.Height, c.Width, c.Height)
+		}
+	}
+
+	d := newDenoiser(c, features, opts)
+	denoised := NewCanvas(c.Width, c.Height)
+	if c.Alpha != nil {
+		denoised.Alpha = newAlphaPlane(c.Width, c.Height, 1)
+	}
+	denoised.OutputTransform = c.OutputTransform
+
+	// Denoise the rows of the canvas in parallel
+	rows := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for y := range rows {
+				for x := 0; x < c.Width; x++ {
+					clr, alpha := d.denoisePixel(x, y)
+					denoised.Pixels[y][x] = clr
+					if denoised.Alpha != nil {
+						denoised.Alpha[y][x] = alpha
+					}
+				}
+			}
+		}()
+	}
+	for y := 0; y < c.Height; y++ {
+		rows <- y
+	}
+	close(rows)
+	wg.Wait()
+
+	return denoised, nil
+}
+
+// denoiser holds the buffers that a canvas is denoised with, which are indexed
+// row by row from the top left pixel.
+type denoiser struct {
+	width, height int
+	opts          *DenoiseOptions
+	// The alpha of each pixel of the canvas
+	alpha []float64
+	// The color of each pixel divided by the albedo, which is premultiplied by alpha
+	irradiance []color.Color
+	// The irradiance, not premultiplied by alpha, blurred over 3x3 pixels
+	// and compressed into the range [0, 1)
+	guide []color.Color
+	// The albedo that the irradiance of each pixel is divided by, or nil if there's no albedo
+	albedo []color.Color
+	// The normal and depth of each pixel, or nil if there are no such features
+	normal []color.Color
+	depth  []float64
+	// The color and variance of each pixel, or nil if there's no variance
+	color    []color.Color
+	variance []color.Color
+}
+
+// newDenoiser returns a new denoiser of the passed canvas with the passed features and options.
+func newDenoiser(c *Canvas, features DenoiseFeatures, opts *DenoiseOptions) *denoiser {
+	pixels := c.Width * c.Height
+	d := &denoiser{
+		width:      c.Width,
+		height:     c.Height,
+		opts:       opts,
+		alpha:      make([]float64, pixels),
+		irradiance: make([]color.Color, pixels),
+		guide:      make([]color.Color, pixels),
+	}
+	if features.Albedo != nil {
+		d.albedo = make([]color.Color, pixels)
+	}
+	if features.Normal != nil {
+		d.normal = make([]color.Color, pixels)
+	}
+	if features.Depth != nil {
+		d.depth = make([]float64, pixels)
+	}
+	if features.Variance != nil {
+		d.color = make([]color.Color, pixels)
+		d.variance = make([]color.Color, pixels)
+	}
+
+	for y := 0; y < c.Height; y++ {
+		for x := 0; x < c.Width; x++ {
+			idx := y*c.Width + x
+			d.alpha[idx] = c.alphaAt(x, y)
+			d.irradiance[idx] = c.Pixels[y][x]
+
+			if d.albedo != nil {
+				albedo := unpremultiply(features.Albedo.Pixels[y][x], features.Albedo.alphaAt(x, y))
+				albedo.Red = math.Max(albedo.Red, minDenoiseAlbedo)
+				albedo.Green = math.Max(albedo.Green, minDenoiseAlbedo)
+				albedo.Blue = math.Max(albedo.Blue, minDenoiseAlbedo)
+				d.albedo[idx] = albedo
+				d.irradiance[idx].Red /= albedo.Red
+				d.irradiance[idx].Green /= albedo.Green
+				d.irradiance[idx].Blue /= albedo.Blue
+			}
+			if d.normal != nil {
+				d.normal[idx] = features.Normal.Pixels[y][x]
+			}
+			if d.depth != nil {
+				d.depth[idx] = features.Depth.Pixels[y][x].Red
+			}
+			if d.variance != nil {
+				d.color[idx] = c.Pixels[y][x]
+				d.variance[idx] = features.Variance.Pixels[y][x]
+			}
+		}
+	}
+
+	// Blur the guide of the color weights, so that the noise of a pixel's own
+	// color doesn't keep it from being averaged with its neighbors
+	for y := 0; y < c.Height; y++ {
+		for x := 0; x < c.Width; x++ {
+			var sum color.Color
+			count := 0.0
+			for ny := y - 1; ny <= y+1; ny++ {
+				for nx := x - 1; nx <= x+1; nx++ {
+					if nx < 0 || nx >= c.Width || ny < 0 || ny >= c.Height {
+						continue
+					}
+					n := unpremultiply(d.irradiance[ny*c.Width+nx], d.alpha[ny*c.Width+nx])
+					sum.Red += n.Red / (1 + math.Max(n.Red, 0))
+					sum.Green += n.Green / (1 + math.Max(n.Green, 0))
+					sum.Blue += n.Blue / (1 + math.Max(n.Blue, 0))
+					count++
+				}
+			}
+			d.guide[y*c.Width+x] = color.Color{
+				Red:   sum.Red / count,
+				Green: sum.Green / count,
+				Blue:  sum.Blue / count,
+			}
+		}
+	}
+
+	return d
+}
+
+// denoisePixel returns the denoised color of the pixel at (x, y), premultiplied by its
+// denoised alpha, and its denoised alpha.
+func (d *denoiser) denoisePixel(x, y int) (color.Color, float64) {
+	idx := y*d.width + x
+
+	spatial := 1 / (2 * d.opts.SpatialSigma * d.opts.SpatialSigma)
+	colorScale := 1 / (2 * d.opts.ColorSigma * d.opts.ColorSigma)
+	albedoScale := 1 / (2 * d.opts.AlbedoSigma * d.opts.AlbedoSigma)
+	normalScale := 1 / (2 * d.opts.NormalSigma * d.opts.NormalSigma)
+	depthScale := 1 / (2 * d.opts.DepthSigma * d.opts.DepthSigma)
+
+	var sum color.Color
+	alphaSum, weightSum := 0.0, 0.0
+	radius := d.opts.Radius
+	for ny := maxInt(y-radius, 0); ny <= minInt(y+radius, d.height-1); ny++ {
+		for nx := maxInt(x-radius, 0); nx <= minInt(x+radius, d.width-1); nx++ {
+			n := ny*d.width + nx
+
+			// Sum the exponents of the weights, so that each neighbor takes one exponential
+			dx, dy := float64(nx-x), float64(ny-y)
+			exponent := (dx*dx + dy*dy) * spatial
+			if d.variance != nil {
+				exponent += d.noiseDistance(idx, n)
+			} else {
+				exponent += colorDistance(d.guide[idx], d.guide[n]) * colorScale
+			}
+			if d.albedo != nil {
+				exponent += colorDistance(d.albedo[idx], d.albedo[n]) * albedoScale
+			}
+			if d.normal != nil {
+				exponent += colorDistance(d.normal[idx], d.normal[n]) * normalScale
+			}
+			if d.depth != nil {
+				relative := (d.depth[idx] - d.depth[n]) / math.Max(d.depth[idx], minDenoiseDepth)
+				exponent += relative * relative * depthScale
+			}
+
+			weight := math.Exp(-exponent)
+			sum.Red += d.irradiance[n].Red * weight
+			sum.Green += d.irradiance[n].Green * weight
+			sum.Blue += d.irradiance[n].Blue * weight
+			alphaSum += d.alpha[n] * weight
+			weightSum += weight
+		}
+	}
+
+	// The pixel itself always has a weight of 1, so the weight sum is never 0
+	clr := color.Color{
+		Red:   sum.Red / weightSum,
+		Green: sum.Green / weightSum,
+		Blue:  sum.Blue / weightSum,
+	}
+	if d.albedo != nil {
+		clr.Red *= d.albedo[idx].Red
+		clr.Green *= d.albedo[idx].Green
+		clr.Blue *= d.albedo[idx].Blue
+	}
+
+	return clr, alphaSum / weightSum
+}
+
+// noiseDistance returns the exponent of the weight of the color of the neighbor with
+// index n of the pixel with index idx, which is the squared difference of their colors
+// relative to their variance. It follows the non-local means filter of Rousselle et al.,
+// "Adaptive Rendering with Non-Local Means Filtering" (2012), without patches.
+func (d *denoiser) noiseDistance(idx, n int) float64 {
+	scale := d.opts.NoiseSigma * d.opts.NoiseSigma
+	p, q := d.color[idx], d.color[n]
+	vp, vq := d.variance[idx], d.variance[n]
+
+	distance := 0.0
+	for _, c := range [...][4]float64{
+		{p.Red, q.Red, vp.Red, vq.Red},
+		{p.Green, q.Green, vp.Green, vq.Green},
+		{p.Blue, q.Blue, vp.Blue, vq.Blue},
+	} {
+		// Subtract the difference that the noise of the pixels is expected to cause
+		difference := c[0] - c[1]
+		distance += math.Max(difference*difference-(c[2]+math.Min(c[2], c[3])), 0) /
+			(minDenoiseVariance + scale*(c[2]+c[3]))
+	}
+
+	return distance / 3
+}
+
+// unpremultiply returns the passed color, which is premultiplied by the passed alpha,
+// divided by its alpha.
+func unpremultiply(clr color.Color, alpha float64) color.Color {
+	if alpha <= 0 || alpha >= 1 {
+		return clr
+	}
+
+	return color.Color{Red: clr.Red / alpha, Green: clr.Green / alpha, Blue: clr.Blue / alpha}
+}
+
+// colorDistance returns the squared distance between the passed colors.
+func colorDistance(a, b color.Color) float64 {
+	dr, dg, db := a.Red-b.Red, a.Green-b.Green, a.Blue-b.Blue
+	return dr*dr + dg*dg + db*db
+}
+
+// minInt returns the smaller of the passed integers.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxInt returns the larger of the passed integers.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,184 @@
+package canvas
+
+import (
+	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// newDenoiseTestCanvases returns a clean canvas whose left half has a red material and right
+// half a blue one, the canvas with noise added to its bottom half, and its features. The top
+// half has stripes of light without noise.
+func newDenoiseTestCanvases() (*Canvas, *Canvas, DenoiseFeatures) {
+	const (
+		width  = 32
+		height = 32
+		noise  = 0.1
+	)
+	rnd := rand.New(rand.NewSource(1))
+
+	clean := NewCanvas(width, height)
+	noisy := NewCanvas(width, height)
+	features := DenoiseFeatures{
+		Albedo:   NewCanvas(width, height),
+		Normal:   NewCanvas(width, height),
+		Depth:    NewCanvas(width, height),
+		Variance: NewCanvas(width, height),
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			albedo := *color.NewColor(0.8, 0.2, 0.2)
+			if x >= width/2 {
+				albedo = *color.NewColor(0.2, 0.2, 0.8)
+			}
+
+			// The light varies smoothly in the bottom half and in stripes in the top half
+			light := 0.5 + 0.01*float64(x)
+			if y < height/2 {
+				light = 0.5 + float64(x%2)
+			}
+			pixel := *color.Scale(albedo, light)
+			clean.Pixels[y][x] = pixel
+
+			if y >= height/2 {
+				pixel.Red += noise * rnd.NormFloat64()
+				pixel.Green += noise * rnd.NormFloat64()
+				pixel.Blue += noise * rnd.NormFloat64()
+				features.Variance.Pixels[y][x] = *color.NewColor(noise*noise, noise*noise, noise*noise)
+			}
+			noisy.Pixels[y][x] = pixel
+
+			features.Albedo.Pixels[y][x] = albedo
+			features.Normal.Pixels[y][x] = *color.NewColor(0, 0, -1)
+			features.Depth.Pixels[y][x] = *color.NewColor(5, 5, 5)
+		}
+	}
+
+	return clean, noisy, features
+}
+
+// denoiseError returns the root mean squared error of the passed rows of the passed
+// canvas against the passed clean canvas.
+func denoiseError(c, clean *Canvas, y0, y1 int) float64 {
+	sum := 0.0
+	for y := y0; y < y1; y++ {
+		for x := 0; x < c.Width; x++ {
+			sum += colorDistance(c.Pixels[y][x], clean.Pixels[y][x])
+		}
+	}
+
+	return math.Sqrt(sum / float64(3*c.Width*(y1-y0)))
+}
+
+func TestCanvas_Denoise(t *testing.T) {
+	clean, noisy, features := newDenoiseTestCanvases()
+	noisyError := denoiseError(noisy, clean, 16, 32)
+
+	tests := []struct {
+		name     string
+		features DenoiseFeatures
+		// The largest error in the noisy half relative to the error of the noisy canvas
+		maxRelativeError float64
+	}{
+		{
+			name:             "every feature",
+			features:         features,
+			maxRelativeError: 0.5,
+		},
+		{
+			name: "without variance",
+			features: DenoiseFeatures{
+				Albedo: features.Albedo,
+				Normal: features.Normal,
+				Depth:  features.Depth,
+			},
+			maxRelativeError: 0.8,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			denoised, err := noisy.Denoise(tt.features, nil)
+			assert.NoError(t, err)
+			assert.Nil(t, denoised.Alpha)
+			assert.Less(t, denoiseError(denoised, clean, 16, 32), noisyError*tt.maxRelativeError)
+
+			// The materials don't bleed into each other across their edge
+			for y := 16; y < 32; y++ {
+				assert.Less(t, denoised.Pixels[y][15].Blue, denoised.Pixels[y][15].Red)
+				assert.Less(t, denoised.Pixels[y][16].Red, denoised.Pixels[y][16].Blue)
+			}
+		})
+	}
+
+	// With variance, the stripes without noise are kept as they are,
+	// away from the noisy pixels within the radius of the filter
+	denoised, err := noisy.Denoise(features, nil)
+	assert.NoError(t, err)
+	assert.InDelta(t, 0, denoiseError(denoised, clean, 0, 16-DefaultDenoiseRadius), 1e-6)
+}
+
+func TestCanvas_DenoiseAlpha(t *testing.T) {
+	c := NewCanvasWithAlpha(8, 1)
+	for x := 0; x < 4; x++ {
+		assert.NoError(t, c.WritePixel(x, 0, *color.NewColor(0.5, 0.5, 0.5)))
+		assert.NoError(t, c.WriteAlpha(x, 0, 1))
+	}
+	c.OutputTransform = NewOutputTransform()
+
+	// The colors and alpha are averaged together, so colors stay premultiplied
+	denoised, err := c.Denoise(DenoiseFeatures{}, &DenoiseOptions{
+		Radius:       1,
+		SpatialSigma: 1,
+		ColorSigma:   10,
+		NoiseSigma:   1,
+		AlbedoSigma:  1,
+		NormalSigma:  1,
+		DepthSigma:   1,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, c.OutputTransform, denoised.OutputTransform)
+	assert.Equal(t, 1.0, denoised.Alpha[0][1])
+	assert.Equal(t, 0.0, denoised.Alpha[0][6])
+	assert.True(t, denoised.Alpha[0][3] > 0 && denoised.Alpha[0][3] < 1)
+	assert.InDelta(t, 0.5*denoised.Alpha[0][3], denoised.Pixels[0][3].Red, 1e-9)
+	assert.InDelta(t, 0.5*denoised.Alpha[0][4], denoised.Pixels[0][4].Red, 1e-9)
+}
+
+func TestCanvas_DenoiseErrors(t *testing.T) {
+	c := NewCanvas(4, 4)
+	tests := []struct {
+		name     string
+		features DenoiseFeatures
+		opts     func(o *DenoiseOptions)
+	}{
+		{
+			name: "negative radius",
+			opts: func(o *DenoiseOptions) { o.Radius = -1 },
+		},
+		{
+			name: "zero sigma",
+			opts: func(o *DenoiseOptions) { o.NormalSigma = 0 },
+		},
+		{
+			name: "NaN sigma",
+			opts: func(o *DenoiseOptions) { o.SpatialSigma = math.NaN() },
+		},
+		{
+			name:     "feature of a different size",
+			features: DenoiseFeatures{Depth: NewCanvas(4, 3)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := NewDenoiseOptions()
+			if tt.opts != nil {
+				tt.opts(opts)
+			}
+			denoised, err := c.Denoise(tt.features, opts)
+			assert.Error(t, err)
+			assert.Nil(t, denoised)
+		})
+	}
+}
@@ -0,0 +1,677 @@
+package canvas
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+)
+
+// EXRPixelType is the type of the color values of an OpenEXR file.
+type EXRPixelType int32
+
+const (
+	// EXRHalf stores each color value as a 16-bit half-precision float.
+	EXRHalf EXRPixelType = 1
+	// EXRFloat stores each color value as a 32-bit float.
+	EXRFloat EXRPixelType = 2
+)
+
+// EXRCompression is the compression of the pixels of an OpenEXR file.
+type EXRCompression uint8
+
+const (
+	// EXRNoCompression stores the pixels uncompressed.
+	EXRNoCompression EXRCompression = 0
+	// EXRZIPSCompression compresses each scanline of pixels with zlib.
+	EXRZIPSCompression EXRCompression = 2
+	// EXRZIPCompression compresses each block of 16 scanlines of pixels with zlib.
+	EXRZIPCompression EXRCompression = 3
+)
+
+const (
+	// exrMagic is the number at the start of an OpenEXR file.
+	exrMagic = 20000630
+	// exrVersion is the version of the OpenEXR file format that is written.
+	exrVersion = 2
+	// exrUnsupportedFlags are the version flags of tiled, deep, and multi-part
+	// OpenEXR files, which aren't supported.
+	exrUnsupportedFlags = 0x200 | 0x800 | 0x1000
+	// maxEXRAttributeSize is the largest size of a header attribute that is read.
+	maxEXRAttributeSize = 1 << 20
+)
+
+// exrChannels are the names of the color channels of an OpenEXR file,
+// which are sorted by name as the format requires.
+var exrChannels = []string{"B", "G", "R"}
+
+// exrAlphaChannels are the names of the channels of an OpenEXR file that has alpha.
+var exrAlphaChannels = []string{"A", "B", "G", "R"}
+
+// WriteEXR writes the canvas to the passed writer as a scanline OpenEXR file with the passed
+// pixel type and compression. Colors are written as they are, without being clamped, although
+// values beyond the range of half-precision floats become infinity when written as halves.
+// If the canvas has alpha, then the file has an alpha channel, and its colors are
+// premultiplied by their alpha as the format expects.
+func (c *Canvas) WriteEXR(writer io.Writer, pixelType EXRPixelType, compression EXRCompression) error {
+	channels := exrChannels
+	if c.Alpha != nil {
+		channels = exrAlphaChannels
+	}
+
+	return writeEXR(writer, c.Width, c.Height, channels, nil, pixelType, compression,
+		func(x, y, channel int) float64 {
+			return c.exrChannelValue(x, y, channels[channel])
+		})
+}
+
+// EXRImage is an image of an OpenEXR file with any channels, such as the passes of a render.
+type EXRImage struct {
+	Width  int
+	Height int
+	// Channels holds the channels of the image
+	Channels []EXRChannel
+	// Attributes holds the string attributes of the header of the file, such as metadata
+	Attributes map[string]string
+}
+
+// EXRChannel is a channel of the pixels of an EXRImage.
+type EXRChannel struct {
+	// Name is the name of the channel, such as "R" or "CryptoObject00.R"
+	Name string
+	// Values holds the value of each pixel, row by row from the top left pixel
+	Values []float32
+}
+
+// Channel returns the channel of the image with the passed name, or nil if it has none.
+func (img *EXRImage) Channel(name string) *EXRChannel {
+	for i := range img.Channels {
+		if img.Channels[i].Name == name {
+			return &img.Channels[i]
+		}
+	}
+
+	return nil
+}
+
+// WriteEXR writes the image to the passed writer as a scanline OpenEXR file with the passed
+// pixel type and compression. The channels are written in the order of their names, as the
+// format requires, and the header of the file has the attributes of the image.
+func (img *EXRImage) WriteEXR(writer io.Writer, pixelType EXRPixelType,
+	compression EXRCompression) error {
+	if len(img.Channels) == 0 {
+		return errors.New("channels must not be empty")
+	}
+
+	sorted := append([]EXRChannel(nil), img.Channels...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	names := make([]string, len(sorted))
+	for i, channel := range sorted {
+		if channel.Name == "" {
+			return errors.New("channel names must not be empty")
+		}
+		if i > 0 && channel.Name == names[i-1] {
+			return fmt.Errorf("channel %q is repeated", channel.Name)
+		}
+		if len(channel.Values) != img.Width*img.Height {
+			return fmt.Errorf("channel %q has %d values, expected %d",
+				channel.Name, len(channel.Values), img.Width*img.Height)
+		}
+		names[i] = channel.Name
+	}
+
+	return writeEXR(writer, img.Width, img.Height, names, img.Attributes, pixelType, compression,
+		func(x, y, channel int) float64 {
+			return float64(sorted[channel].Values[y*img.Width+x])
+		})
+}
+
+// writeEXR writes an image with the passed width, height, and channels, which must be sorted
+// by name, to the passed writer as a scanline OpenEXR file with the passed string attributes,
+// pixel type, and compression. The value of each channel of each pixel is returned by the
+// passed function, which is passed the index of the channel.
+func writeEXR(writer io.Writer, width, height int, channels []string, attributes map[string]string,
+	pixelType EXRPixelType, compression EXRCompression, value func(x, y, channel int) float64) error {
+	if writer == nil {
+		return errors.New("writer must not be nil")
+	}
+
+	valueSize, err := exrPixelTypeSize(pixelType)
+	if err != nil {
+		return err
+	}
+	linesPerChunk, err := exrLinesPerChunk(compression)
+	if err != nil {
+		return err
+	}
+
+	// Encode each chunk of scanlines, so that the offsets of the chunks are known
+	lineSize := width * valueSize
+	chunkCount := (height + linesPerChunk - 1) / linesPerChunk
+	chunks := make([][]byte, chunkCount)
+	for chunk := range chunks {
+		startY := chunk * linesPerChunk
+		endY := int(math.Min(float64(startY+linesPerChunk), float64(height)))
+
+		// The values of each scanline are grouped by channel
+		data := make([]byte, 0, (endY-startY)*lineSize*len(channels))
+		for y := startY; y < endY; y++ {
+			for channel := range channels {
+				for x := 0; x < width; x++ {
+					v := value(x, y, channel)
+					if pixelType == EXRHalf {
+						data = appendUint16(data, float32ToHalf(float32(v)))
+					} else {
+						data = appendUint32(data, math.Float32bits(float32(v)))
+					}
+				}
+			}
+		}
+
+		if compression != EXRNoCompression {
+			data, err = compressEXRZIP(data)
+			if err != nil {
+				return err
+			}
+		}
+
+		chunks[chunk] = data
+	}
+
+	header := exrHeader(width, height, channels, attributes, pixelType, compression)
+	buf := make([]byte, 0, len(header)+chunkCount*8)
+	buf = append(buf, header...)
+
+	// The offset table gives the position of each chunk in the file
+	offset := uint64(len(header) + chunkCount*8)
+	for _, data := range chunks {
+		buf = appendUint64(buf, offset)
+		offset += uint64(8 + len(data))
+	}
+	if _, err := writer.Write(buf); err != nil {
+		return err
+	}
+
+	for chunk, data := range chunks {
+		prefix := appendUint32(nil, uint32(chunk*linesPerChunk))
+		prefix = appendUint32(prefix, uint32(len(data)))
+		if _, err := writer.Write(prefix); err != nil {
+			return err
+		}
+		if _, err := writer.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exrHeader returns the magic number, version, and header of an OpenEXR file of an image
+// with the passed width, height, and channels, and the passed string attributes.
+func exrHeader(width, height int, channels []string, attributes map[string]string,
+	pixelType EXRPixelType, compression EXRCompression) []byte {
+	header := appendUint32(nil, exrMagic)
+	header = appendUint32(header, exrVersion)
+
+	var channelList []byte
+	for _, channel := range channels {
+		channelList = append(channelList, channel...)
+		channelList = append(channelList, 0)
+		channelList = appendUint32(channelList, uint32(pixelType))
+		// The linear flag, three reserved bytes, and the x and y sampling
+		channelList = append(channelList, 0, 0, 0, 0)
+		channelList = appendUint32(channelList, 1)
+		channelList = appendUint32(channelList, 1)
+	}
+	channelList = append(channelList, 0)
+
+	// The data and display windows are inclusive
+	window := appendUint32(nil, 0)
+	window = appendUint32(window, 0)
+	window = appendUint32(window, uint32(int32(width-1)))
+	window = appendUint32(window, uint32(int32(height-1)))
+
+	header = appendEXRAttribute(header, "channels", "chlist", channelList)
+	header = appendEXRAttribute(header, "compression", "compression", []byte{byte(compression)})
+	header = appendEXRAttribute(header, "dataWindow", "box2i", window)
+	header = appendEXRAttribute(header, "displayWindow", "box2i", window)
+	header = appendEXRAttribute(header, "lineOrder", "lineOrder", []byte{0})
+	header = appendEXRAttribute(header, "pixelAspectRatio", "float",
+		appendUint32(nil, math.Float32bits(1)))
+	header = appendEXRAttribute(header, "screenWindowCenter", "v2f", make([]byte, 8))
+	header = appendEXRAttribute(header, "screenWindowWidth", "float",
+		appendUint32(nil, math.Float32bits(1)))
+
+	// String attributes are written in the order of their names, so files are reproducible
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		header = appendEXRAttribute(header, name, "string", []byte(attributes[name]))
+	}
+
+	return append(header, 0)
+}
+
+// ReadEXR reads a canvas from the passed reader, which holds a single-part scanline OpenEXR
+// file with R, G, and B channels of half or float values that are uncompressed or compressed
+// with ZIP or ZIPS. If the file has an A channel, then the canvas has alpha.
+// Other channels are ignored.
+func ReadEXR(reader io.Reader) (*Canvas, error) {
+	img, err := ReadEXRImage(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, channel := range exrChannels {
+		if img.Channel(channel) == nil {
+			return nil, fmt.Errorf("missing OpenEXR channel %q", channel)
+		}
+	}
+	red, green, blue := img.Channel("R").Values, img.Channel("G").Values, img.Channel("B").Values
+	alpha := img.Channel("A")
+
+	c := NewCanvas(img.Width, img.Height)
+	if alpha != nil {
+		c.Alpha = newAlphaPlane(img.Width, img.Height, 1)
+	}
+	for y := 0; y < img.Height; y++ {
+		for x := 0; x < img.Width; x++ {
+			idx := y*img.Width + x
+			c.Pixels[y][x] = *color.NewColor(
+				float64(red[idx]), float64(green[idx]), float64(blue[idx]))
+			if alpha != nil {
+				c.Alpha[y][x] = math.Min(math.Max(float64(alpha.Values[idx]), 0), 1)
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// ReadEXRImage reads an image from the passed reader, which holds a single-part scanline
+// OpenEXR file with channels of half or float values that are uncompressed or compressed
+// with ZIP or ZIPS. The channels are in the order that they're listed in the file, and
+// the string attributes of its header are kept.
+func ReadEXRImage(reader io.Reader) (*EXRImage, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	r := &exrReader{data: data}
+
+	if r.uint32() != exrMagic {
+		return nil, errors.New("missing OpenEXR magic number")
+	}
+	version := r.uint32()
+	if version&0xff != exrVersion {
+		return nil, fmt.Errorf("unsupported OpenEXR version %d", version&0xff)
+	}
+	if version&exrUnsupportedFlags != 0 {
+		return nil, errors.New("tiled, deep, and multi-part OpenEXR files are not supported")
+	}
+
+	header, err := r.header()
+	if err != nil {
+		return nil, err
+	}
+
+	width := int(header.maxX) - int(header.minX) + 1
+	height := int(header.maxY) - int(header.minY) + 1
+	if width < 0 || height < 0 || (width > 0 && height > maxPPMPixels/width) {
+		return nil, fmt.Errorf("invalid OpenEXR image size of %dx%d pixels", width, height)
+	}
+
+	linesPerChunk, err := exrLinesPerChunk(header.compression)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find where the values of each channel are within a scanline
+	lineSize := 0
+	channelOffsets := make([]int, len(header.channels))
+	img := &EXRImage{
+		Width:      width,
+		Height:     height,
+		Channels:   make([]EXRChannel, len(header.channels)),
+		Attributes: header.attributes,
+	}
+	for i, channel := range header.channels {
+		if channel.xSampling != 1 || channel.ySampling != 1 {
+			return nil, fmt.Errorf("subsampled OpenEXR channel %q is not supported", channel.name)
+		}
+		valueSize, err := exrPixelTypeSize(channel.pixelType)
+		if err != nil {
+			return nil, fmt.Errorf("OpenEXR channel %q: %v", channel.name, err)
+		}
+
+		channelOffsets[i] = lineSize
+		lineSize += width * valueSize
+		img.Channels[i] = EXRChannel{Name: channel.name, Values: make([]float32, width*height)}
+	}
+
+	// readValue returns the value of the passed channel for pixel x of the passed scanline
+	readValue := func(scanline []byte, channel int, x int) float32 {
+		values := scanline[channelOffsets[channel]:]
+		if header.channels[channel].pixelType == EXRHalf {
+			return halfToFloat32(binary.LittleEndian.Uint16(values[x*2:]))
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(values[x*4:]))
+	}
+
+	chunkCount := (height + linesPerChunk - 1) / linesPerChunk
+	offsets := make([]uint64, chunkCount)
+	for chunk := range offsets {
+		offsets[chunk] = r.uint64()
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to read OpenEXR offset table: %v", r.err)
+	}
+
+	for chunk, offset := range offsets {
+		if offset > uint64(len(data)) {
+			return nil, fmt.Errorf("invalid offset of OpenEXR chunk %d", chunk)
+		}
+		r.pos = int(offset)
+		chunkY := int(int32(r.uint32())) - int(header.minY)
+		dataSize := r.uint32()
+		if r.err != nil || uint64(dataSize) > uint64(len(data)-r.pos) {
+			return nil, fmt.Errorf("failed to read OpenEXR chunk %d", chunk)
+		}
+		if chunkY < 0 || chunkY >= height || chunkY%linesPerChunk != 0 {
+			return nil, fmt.Errorf("invalid scanline %d of OpenEXR chunk %d", chunkY, chunk)
+		}
+
+		lines := int(math.Min(float64(linesPerChunk), float64(height-chunkY)))
+		pixels := data[r.pos : r.pos+int(dataSize)]
+		if len(pixels) < lines*lineSize {
+			// The chunk is only stored uncompressed if compressing it doesn't make it smaller
+			if header.compression == EXRNoCompression {
+				return nil, fmt.Errorf("OpenEXR chunk %d is too short", chunk)
+			}
+			pixels, err = decompressEXRZIP(pixels, lines*lineSize)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decompress OpenEXR chunk %d: %v", chunk, err)
+			}
+		}
+
+		for line := 0; line < lines; line++ {
+			scanline := pixels[line*lineSize:]
+			row := (chunkY + line) * width
+			for channel := range img.Channels {
+				values := img.Channels[channel].Values[row : row+width]
+				for x := range values {
+					values[x] = readValue(scanline, channel, x)
+				}
+			}
+		}
+	}
+
+	return img, nil
+}
+
+// exrHeaderValues are the values of the header of an OpenEXR file that are used to read it.
+type exrHeaderValues struct {
+	channels    []exrChannel
+	compression EXRCompression
+	hasWindow   bool
+	// The string attributes of the header
+	attributes map[string]string
+
+	// The inclusive bounds of the data window
+	minX, minY, maxX, maxY int32
+}
+
+// exrChannel is a channel of an OpenEXR file.
+type exrChannel struct {
+	name      string
+	pixelType EXRPixelType
+	xSampling int32
+	ySampling int32
+}
+
+// exrReader reads the little-endian values of an OpenEXR file. Reading past the end of
+// the file sets err, and returns zero values, so that it only has to be checked once.
+type exrReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// bytes returns the next n bytes.
+func (r *exrReader) bytes(n int) []byte {
+	if r.err != nil || n > len(r.data)-r.pos {
+		r.err = io.ErrUnexpectedEOF
+		return make([]byte, n)
+	}
+
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+// uint32 returns the next 32-bit integer.
+func (r *exrReader) uint32() uint32 {
+	return binary.LittleEndian.Uint32(r.bytes(4))
+}
+
+// uint64 returns the next 64-bit integer.
+func (r *exrReader) uint64() uint64 {
+	return binary.LittleEndian.Uint64(r.bytes(8))
+}
+
+// string returns the next null-terminated string.
+func (r *exrReader) string() string {
+	end := bytes.IndexByte(r.data[r.pos:], 0)
+	if r.err != nil || end == -1 {
+		r.err = io.ErrUnexpectedEOF
+		return ""
+	}
+
+	s := string(r.data[r.pos : r.pos+end])
+	r.pos += end + 1
+	return s
+}
+
+// header reads the attributes of the header of an OpenEXR file.
+func (r *exrReader) header() (*exrHeaderValues, error) {
+	header := &exrHeaderValues{}
+	hasCompression := false
+	for {
+		name := r.string()
+		if r.err != nil {
+			return nil, fmt.Errorf("failed to read OpenEXR header: %v", r.err)
+		}
+		if name == "" {
+			break
+		}
+
+		typeName := r.string()
+		size := r.uint32()
+		if size > maxEXRAttributeSize {
+			return nil, fmt.Errorf("OpenEXR attribute %q is too large", name)
+		}
+		value := &exrReader{data: r.bytes(int(size))}
+		if r.err != nil {
+			return nil, fmt.Errorf("failed to read OpenEXR attribute %q: %v", name, r.err)
+		}
+
+		switch {
+		case name == "channels" && typeName == "chlist":
+			for {
+				channelName := value.string()
+				if channelName == "" {
+					break
+				}
+				channel := exrChannel{name: channelName}
+				channel.pixelType = EXRPixelType(value.uint32())
+				value.bytes(4)
+				channel.xSampling = int32(value.uint32())
+				channel.ySampling = int32(value.uint32())
+				header.channels = append(header.channels, channel)
+			}
+		case name == "compression" && typeName == "compression":
+			header.compression = EXRCompression(value.bytes(1)[0])
+			hasCompression = true
+		case name == "dataWindow" && typeName == "box2i":
+			header.minX = int32(value.uint32())
+			header.minY = int32(value.uint32())
+			header.maxX = int32(value.uint32())
+			header.maxY = int32(value.uint32())
+			header.hasWindow = true
+		case typeName == "string":
+			if header.attributes == nil {
+				header.attributes = map[string]string{}
+			}
+			header.attributes[name] = string(value.data)
+		}
+		if value.err != nil {
+			return nil, fmt.Errorf("failed to read OpenEXR attribute %q: %v", name, value.err)
+		}
+	}
+
+	if len(header.channels) == 0 || !hasCompression || !header.hasWindow {
+		return nil, errors.New("OpenEXR header is missing required attributes")
+	}
+
+	return header, nil
+}
+
+// exrPixelTypeSize returns the size in bytes of a value of the passed pixel type.
+func exrPixelTypeSize(pixelType EXRPixelType) (int, error) {
+	switch pixelType {
+	case EXRHalf:
+		return 2, nil
+	case EXRFloat:
+		return 4, nil
+	default:
+		return 0, fmt.Errorf("unsupported OpenEXR pixel type %d", pixelType)
+	}
+}
+
+// exrLinesPerChunk returns the number of scanlines in each chunk of
+// an OpenEXR file that is compressed with the passed compression.
+func exrLinesPerChunk(compression EXRCompression) (int, error) {
+	switch compression {
+	case EXRNoCompression, EXRZIPSCompression:
+		return 1, nil
+	case EXRZIPCompression:
+		return 16, nil
+	default:
+		return 0, fmt.Errorf("unsupported OpenEXR compression %d", compression)
+	}
+}
+
+// exrChannelValue returns the value of the passed channel for the pixel
+// located at the passed x and y values of the Canvas.
+func (c *Canvas) exrChannelValue(x, y int, channel string) float64 {
+	pixel := c.Pixels[y][x]
+	switch channel {
+	case "A":
+		return c.alphaAt(x, y)
+	case "R":
+		return pixel.Red
+	case "G":
+		return pixel.Green
+	default:
+		return pixel.Blue
+	}
+}
+
+// compressEXRZIP compresses the passed pixels in the way of the ZIP and ZIPS compressions.
+// The bytes are split into the even and odd bytes, and each byte is replaced by its
+// difference from the byte before it, which makes them compress better with zlib.
+// If compressing the pixels doesn't make them smaller, they are returned uncompressed.
+func compressEXRZIP(pixels []byte) ([]byte, error) {
+	reordered := make([]byte, len(pixels))
+	half := (len(pixels) + 1) / 2
+	for i := range pixels {
+		if i%2 == 0 {
+			reordered[i/2] = pixels[i]
+		} else {
+			reordered[half+i/2] = pixels[i]
+		}
+	}
+
+	for i := len(reordered) - 1; i > 0; i-- {
+		reordered[i] = reordered[i] - reordered[i-1] + 128
+	}
+
+	compressed := &bytes.Buffer{}
+	w := zlib.NewWriter(compressed)
+	if _, err := w.Write(reordered); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	if compressed.Len() >= len(pixels) {
+		return pixels, nil
+	}
+	return compressed.Bytes(), nil
+}
+
+// decompressEXRZIP decompresses the passed pixels, which were compressed in the way of
+// compressEXRZIP and have the passed size when they're uncompressed.
+func decompressEXRZIP(compressed []byte, size int) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+
+	reordered := make([]byte, size)
+	if _, err := io.ReadFull(r, reordered); err != nil {
+		return nil, err
+	}
+
+	for i := 1; i < len(reordered); i++ {
+		reordered[i] = reordered[i] + reordered[i-1] - 128
+	}
+
+	pixels := make([]byte, size)
+	half := (size + 1) / 2
+	for i := range pixels {
+		if i%2 == 0 {
+			pixels[i] = reordered[i/2]
+		} else {
+			pixels[i] = reordered[half+i/2]
+		}
+	}
+
+	return pixels, nil
+}
+
+// appendEXRAttribute appends an attribute of an OpenEXR header to the passed bytes.
+func appendEXRAttribute(b []byte, name, typeName string, value []byte) []byte {
+	b = append(b, name...)
+	b = append(b, 0)
+	b = append(b, typeName...)
+	b = append(b, 0)
+	b = appendUint32(b, uint32(len(value)))
+	return append(b, value...)
+}
+
+// appendUint16 appends the passed integer to the passed bytes in little-endian order.
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+// appendUint32 appends the passed integer to the passed bytes in little-endian order.
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+// appendUint64 appends the passed integer to the passed bytes in little-endian order.
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v)), uint32(v>>32))
+}
@@ -0,0 +1,204 @@
+package canvas
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestCanvas_WriteEXR(t *testing.T) {
+	c := NewCanvas(1, 1)
+	assert.NoError(t, c.WritePixel(0, 0, *color.NewColor(2, 0.5, -1)))
+
+	writer := &bytes.Buffer{}
+	assert.NoError(t, c.WriteEXR(writer, EXRFloat, EXRNoCompression))
+	b := writer.Bytes()
+
+	// The magic number and version
+	assert.Equal(t, []byte{0x76, 0x2f, 0x31, 0x01, 0x02, 0x00, 0x00, 0x00}, b[:8])
+
+	// The header starts with the channels, and the pixels come last
+	assert.Equal(t, "channels\x00chlist\x00", string(b[8:24]))
+	pixels := b[len(b)-12:]
+	assert.Equal(t, float32(-1), math.Float32frombits(binary.LittleEndian.Uint32(pixels)))
+	assert.Equal(t, float32(0.5), math.Float32frombits(binary.LittleEndian.Uint32(pixels[4:])))
+	assert.Equal(t, float32(2), math.Float32frombits(binary.LittleEndian.Uint32(pixels[8:])))
+
+	// The offset table points at the only chunk, which is for scanline 0 and holds 12 bytes
+	offset := binary.LittleEndian.Uint64(b[len(b)-12-8-8:])
+	assert.Equal(t, uint64(len(b)-12-8), offset)
+	assert.Equal(t, []byte{0, 0, 0, 0, 12, 0, 0, 0}, b[offset:offset+8])
+
+	assert.Error(t, c.WriteEXR(nil, EXRHalf, EXRZIPCompression))
+	assert.Error(t, c.WriteEXR(writer, EXRPixelType(0), EXRZIPCompression))
+	assert.Error(t, c.WriteEXR(writer, EXRHalf, EXRCompression(4)))
+}
+
+func TestReadEXR_RoundTrip(t *testing.T) {
+	c := NewCanvas(37, 21)
+	for y := 0; y < c.Height; y++ {
+		for x := 0; x < c.Width; x++ {
+			clr := *color.NewColor(float64(x)*0.25, -float64(y)*2, 1000+float64(x*y))
+			assert.NoError(t, c.WritePixel(x, y, clr))
+		}
+	}
+
+	tests := []struct {
+		name        string
+		pixelType   EXRPixelType
+		compression EXRCompression
+	}{
+		{name: "uncompressed halves", pixelType: EXRHalf, compression: EXRNoCompression},
+		{name: "uncompressed floats", pixelType: EXRFloat, compression: EXRNoCompression},
+		{name: "ZIPS compressed halves", pixelType: EXRHalf, compression: EXRZIPSCompression},
+		{name: "ZIP compressed halves", pixelType: EXRHalf, compression: EXRZIPCompression},
+		{name: "ZIP compressed floats", pixelType: EXRFloat, compression: EXRZIPCompression},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &bytes.Buffer{}
+			assert.NoError(t, c.WriteEXR(writer, tt.pixelType, tt.compression))
+			if tt.compression != EXRNoCompression {
+				uncompressed := &bytes.Buffer{}
+				assert.NoError(t, c.WriteEXR(uncompressed, tt.pixelType, EXRNoCompression))
+				assert.True(t, writer.Len() < uncompressed.Len())
+			}
+
+			read, err := ReadEXR(writer)
+			assert.NoError(t, err)
+			assert.Equal(t, c.Width, read.Width)
+			assert.Equal(t, c.Height, read.Height)
+			for y := 0; y < c.Height; y++ {
+				for x := 0; x < c.Width; x++ {
+					want := c.Pixels[y][x]
+					if tt.pixelType == EXRHalf {
+						want = *color.NewColor(
+							float64(halfToFloat32(float32ToHalf(float32(want.Red)))),
+							float64(halfToFloat32(float32ToHalf(float32(want.Green)))),
+							float64(halfToFloat32(float32ToHalf(float32(want.Blue)))))
+					}
+					assert.Equal(t, want, read.Pixels[y][x])
+				}
+			}
+		})
+	}
+}
+
+func TestReadEXR_Errors(t *testing.T) {
+	valid := &bytes.Buffer{}
+	assert.NoError(t, NewCanvas(2, 2).WriteEXR(valid, EXRHalf, EXRZIPCompression))
+
+	tests := []struct {
+		name   string
+		modify func(b []byte) []byte
+	}{
+		{
+			name: "missing magic number",
+			modify: func(b []byte) []byte {
+				b[0] = 0
+				return b
+			},
+		},
+		{
+			name: "tiled file",
+			modify: func(b []byte) []byte {
+				b[5] |= 0x02
+				return b
+			},
+		},
+		{
+			name: "unsupported pixel type",
+			modify: func(b []byte) []byte {
+				// The pixel type of the first channel follows its name
+				b[8+len("channels\x00chlist\x00")+4+len("B\x00")] = 0
+				return b
+			},
+		},
+		{
+			name: "truncated header",
+			modify: func(b []byte) []byte {
+				return b[:40]
+			},
+		},
+		{
+			name: "truncated pixels",
+			modify: func(b []byte) []byte {
+				return b[:len(b)-1]
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := append([]byte(nil), valid.Bytes()...)
+			_, err := ReadEXR(bytes.NewReader(tt.modify(b)))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEXRImage_WriteEXR(t *testing.T) {
+	img := &EXRImage{
+		Width:  3,
+		Height: 2,
+		Channels: []EXRChannel{
+			{Name: "Z", Values: []float32{1, 2, 3, 4, 5, 6}},
+			{Name: "ID.R", Values: []float32{math.Float32frombits(0x12345678), 0, 0, 0, 0, 1e-3}},
+		},
+		Attributes: map[string]string{"note": "passes", "empty": ""},
+	}
+
+	for _, compression := range []EXRCompression{EXRNoCompression, EXRZIPCompression} {
+		writer := &bytes.Buffer{}
+		assert.NoError(t, img.WriteEXR(writer, EXRFloat, compression))
+
+		// The channels are read in the order of their names, with their exact values
+		read, err := ReadEXRImage(bytes.NewReader(writer.Bytes()))
+		assert.NoError(t, err)
+		assert.Equal(t, 3, read.Width)
+		assert.Equal(t, 2, read.Height)
+		assert.Equal(t, []EXRChannel{img.Channels[1], img.Channels[0]}, read.Channels)
+		assert.Equal(t, img.Attributes, read.Attributes)
+		assert.Equal(t, &img.Channels[0], img.Channel("Z"))
+		assert.Nil(t, img.Channel("R"))
+
+		// An image without color channels isn't a canvas
+		_, err = ReadEXR(bytes.NewReader(writer.Bytes()))
+		assert.Error(t, err)
+	}
+
+	// Channels must be named once and have a value for each pixel
+	invalid := []EXRImage{
+		{Width: 1, Height: 1},
+		{Width: 1, Height: 1, Channels: []EXRChannel{{Values: []float32{0}}}},
+		{Width: 1, Height: 1, Channels: []EXRChannel{{Name: "Z", Values: []float32{0, 1}}}},
+		{Width: 1, Height: 1, Channels: []EXRChannel{
+			{Name: "Z", Values: []float32{0}}, {Name: "Z", Values: []float32{1}}}},
+	}
+	for _, img := range invalid {
+		assert.Error(t, img.WriteEXR(&bytes.Buffer{}, EXRFloat, EXRNoCompression))
+	}
+}
+
+func TestCompressEXRZIP(t *testing.T) {
+	pixels := make([]byte, 1001)
+	for i := range pixels {
+		pixels[i] = byte(i / 50)
+	}
+
+	compressed, err := compressEXRZIP(pixels)
+	assert.NoError(t, err)
+	assert.True(t, len(compressed) < len(pixels))
+
+	decompressed, err := decompressEXRZIP(compressed, len(pixels))
+	assert.NoError(t, err)
+	assert.Equal(t, pixels, decompressed)
+
+	// Pixels that don't compress are returned as they are
+	incompressible := []byte{1, 2, 3}
+	compressed, err = compressEXRZIP(incompressible)
+	assert.NoError(t, err)
+	assert.Equal(t, incompressible, compressed)
+}
@@ -0,0 +1,83 @@
+package canvas
+
+import (
+	"math"
+)
+
+// float32ToHalf returns the passed float as a 16-bit half-precision float, rounded to the
+// nearest half. Floats too large for a half become infinity, and floats too small become 0.
+func float32ToHalf(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exponent := int(bits>>23) & 0xff
+	mantissa := bits & 0x7fffff
+
+	// Infinity and NaN, which keeps a bit of its mantissa so that it stays NaN
+	if exponent == 0xff {
+		if mantissa == 0 {
+			return sign | 0x7c00
+		}
+		return sign | 0x7c00 | 0x200 | uint16(mantissa>>13)
+	}
+
+	// Rebias the exponent from a float to a half
+	exponent = exponent - 127 + 15
+	if exponent >= 0x1f {
+		return sign | 0x7c00
+	}
+
+	if exponent <= 0 {
+		// The half is subnormal, or too small to represent
+		if exponent < -10 {
+			return sign
+		}
+
+		// Shift the mantissa, with its implicit leading bit, into place and round it
+		mantissa |= 0x800000
+		shift := uint(14 - exponent)
+		half := mantissa >> shift
+		remainder := mantissa & (1<<shift - 1)
+		halfway := uint32(1) << (shift - 1)
+		if remainder > halfway || (remainder == halfway && half&1 == 1) {
+			half++
+		}
+		return sign | uint16(half)
+	}
+
+	// Round the mantissa to 10 bits, carrying into the exponent if it overflows
+	half := uint32(exponent)<<10 | mantissa>>13
+	remainder := mantissa & 0x1fff
+	if remainder > 0x1000 || (remainder == 0x1000 && half&1 == 1) {
+		half++
+	}
+	if half >= 0x7c00 {
+		return sign | 0x7c00
+	}
+	return sign | uint16(half)
+}
+
+// halfToFloat32 returns the passed 16-bit half-precision float as a float.
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exponent := uint32(h>>10) & 0x1f
+	mantissa := uint32(h & 0x3ff)
+
+	switch {
+	case exponent == 0x1f:
+		// Infinity and NaN
+		return math.Float32frombits(sign | 0x7f800000 | mantissa<<13)
+	case exponent == 0 && mantissa == 0:
+		return math.Float32frombits(sign)
+	case exponent == 0:
+		// Normalize the subnormal half, which is a normal float
+		exponent = 127 - 15 + 1
+		for mantissa&0x400 == 0 {
+			mantissa <<= 1
+			exponent--
+		}
+		mantissa &= 0x3ff
+		return math.Float32frombits(sign | exponent<<23 | mantissa<<13)
+	default:
+		return math.Float32frombits(sign | (exponent+127-15)<<23 | mantissa<<13)
+	}
+}
@@ -0,0 +1,55 @@
+package canvas
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestFloat32ToHalf(t *testing.T) {
+	tests := []struct {
+		name string
+		f    float32
+		want uint16
+	}{
+		{name: "zero", f: 0, want: 0x0000},
+		{name: "negative zero", f: float32(math.Copysign(0, -1)), want: 0x8000},
+		{name: "one", f: 1, want: 0x3c00},
+		{name: "negative two", f: -2, want: 0xc000},
+		{name: "one third is rounded", f: 1.0 / 3, want: 0x3555},
+		{name: "largest half", f: 65504, want: 0x7bff},
+		{name: "rounds up to infinity", f: 65520, want: 0x7c00},
+		{name: "too large", f: 1e10, want: 0x7c00},
+		{name: "smallest normal half", f: 6.103515625e-05, want: 0x0400},
+		{name: "smallest subnormal half", f: 5.960464477539063e-08, want: 0x0001},
+		{name: "too small", f: 1e-10, want: 0x0000},
+		{name: "infinity", f: float32(math.Inf(1)), want: 0x7c00},
+		{name: "negative infinity", f: float32(math.Inf(-1)), want: 0xfc00},
+		{name: "halfway rounds to even", f: 1 + 1.0/2048, want: 0x3c00},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, float32ToHalf(tt.f))
+		})
+	}
+
+	nan := float32ToHalf(float32(math.NaN()))
+	assert.True(t, math.IsNaN(float64(halfToFloat32(nan))))
+}
+
+func TestHalfToFloat32(t *testing.T) {
+	// Every half that isn't NaN converts to a float that converts back to the same half
+	for h := 0; h <= 0xffff; h++ {
+		f := halfToFloat32(uint16(h))
+		if math.IsNaN(float64(f)) {
+			assert.True(t, uint16(h)&0x7c00 == 0x7c00 && uint16(h)&0x3ff != 0)
+			continue
+		}
+		assert.Equal(t, uint16(h), float32ToHalf(f))
+	}
+
+	assert.Equal(t, float32(1), halfToFloat32(0x3c00))
+	assert.Equal(t, float32(-2), halfToFloat32(0xc000))
+	assert.Equal(t, float32(65504), halfToFloat32(0x7bff))
+	assert.Equal(t, float32(5.960464477539063e-08), halfToFloat32(0x0001))
+}
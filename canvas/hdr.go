@@ -0,0 +1,327 @@
+package canvas
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"io"
+	"math"
+	"strings"
+)
+
+const (
+	// hdrFormat is the pixel format of a Radiance HDR file, which has
+	// a shared exponent for the red, green, and blue components.
+	hdrFormat = "32-bit_rle_rgbe"
+	// minHDRRunLength is the shortest run that is run-length encoded in a Radiance HDR file.
+	minHDRRunLength = 4
+	// maxHDRRunLength is the longest run that run-length encoding can represent.
+	maxHDRRunLength = 127
+	// minHDRRLEWidth and maxHDRRLEWidth are the widths of the scanlines
+	// that can be run-length encoded in a Radiance HDR file.
+	minHDRRLEWidth = 8
+	maxHDRRLEWidth = 0x7fff
+	// maxHDRHeaderLength is the longest header of a Radiance HDR file that is read.
+	maxHDRHeaderLength = 1 << 16
+)
+
+// WriteHDR writes the canvas to the passed writer in the Radiance HDR (RGBE) format,
+// which stores each pixel as an 8-bit mantissa for each color component and a shared
+// 8-bit exponent. Colors above 1 are preserved, but negative components are written as 0.
+func (c *Canvas) WriteHDR(writer io.Writer) error {
+	if writer == nil {
+		return errors.New("writer must not be nil")
+	}
+
+	w := bufio.NewWriter(writer)
+	_, err := fmt.Fprintf(w, "#?RADIANCE\nFORMAT=%s\n\n-Y %d +X %d\n", hdrFormat, c.Height, c.Width)
+	if err != nil {
+		return err
+	}
+
+	rgbe := make([]byte, c.Width*4)
+	for _, row := range c.Pixels {
+		for x, pixel := range row {
+			putRGBE(rgbe[x*4:], pixel)
+		}
+
+		if c.Width < minHDRRLEWidth || c.Width > maxHDRRLEWidth {
+			_, err = w.Write(rgbe)
+		} else {
+			err = writeHDRScanline(w, rgbe)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+// writeHDRScanline writes the passed RGBE scanline using run-length encoding,
+// which encodes each of the four components of the pixels separately.
+func writeHDRScanline(w *bufio.Writer, rgbe []byte) error {
+	width := len(rgbe) / 4
+	if _, err := w.Write([]byte{2, 2, byte(width >> 8), byte(width)}); err != nil {
+		return err
+	}
+
+	values := make([]byte, width)
+	for component := 0; component < 4; component++ {
+		for x := range values {
+			values[x] = rgbe[x*4+component]
+		}
+
+		for x := 0; x < width; {
+			// Find the next run that is long enough to encode
+			runStart := x
+			runLength := 0
+			for runStart < width {
+				runLength = 1
+				for runStart+runLength < width && runLength < maxHDRRunLength &&
+					values[runStart+runLength] == values[runStart] {
+					runLength++
+				}
+				if runLength >= minHDRRunLength {
+					break
+				}
+				runStart += runLength
+			}
+			if runStart >= width {
+				runLength = 0
+				runStart = width
+			}
+
+			// Write the values before the run as literal values
+			for x < runStart {
+				count := runStart - x
+				if count > maxHDRRunLength+1 {
+					count = maxHDRRunLength + 1
+				}
+				if err := w.WriteByte(byte(count)); err != nil {
+					return err
+				}
+				if _, err := w.Write(values[x : x+count]); err != nil {
+					return err
+				}
+				x += count
+			}
+
+			if runLength > 0 {
+				if _, err := w.Write([]byte{byte(128 + runLength), values[runStart]}); err != nil {
+					return err
+				}
+				x += runLength
+			}
+		}
+	}
+
+	return nil
+}
+
+// putRGBE puts the passed color into the first four bytes of the passed slice as
+// a mantissa for each of its components and an exponent that they share.
+func putRGBE(rgbe []byte, c color.Color) {
+	r := math.Max(c.Red, 0)
+	g := math.Max(c.Green, 0)
+	b := math.Max(c.Blue, 0)
+	v := math.Max(r, math.Max(g, b))
+	if v < 1e-32 || math.IsNaN(v) {
+		rgbe[0], rgbe[1], rgbe[2], rgbe[3] = 0, 0, 0, 0
+		return
+	}
+
+	// Colors too bright for the exponent are clamped to the brightest color
+	mantissa, exponent := math.Frexp(v)
+	if exponent > 127 || math.IsInf(v, 1) {
+		rgbe[0], rgbe[1], rgbe[2], rgbe[3] = 255, 255, 255, 255
+		return
+	}
+
+	scale := mantissa * 256 / v
+	rgbe[0] = byte(math.Min(r*scale, 255))
+	rgbe[1] = byte(math.Min(g*scale, 255))
+	rgbe[2] = byte(math.Min(b*scale, 255))
+	rgbe[3] = byte(exponent + 128)
+}
+
+// rgbeColor returns the color of the passed RGBE bytes. Each mantissa is read as the
+// middle of the range of values that it was rounded down from.
+func rgbeColor(rgbe []byte) color.Color {
+	if rgbe[3] == 0 {
+		return *color.NewColor(0, 0, 0)
+	}
+
+	f := math.Ldexp(1, int(rgbe[3])-(128+8))
+	return *color.NewColor(
+		(float64(rgbe[0])+0.5)*f,
+		(float64(rgbe[1])+0.5)*f,
+		(float64(rgbe[2])+0.5)*f)
+}
+
+// ReadHDR reads a canvas from the passed reader, which holds an image in the Radiance HDR
+// (RGBE) format with its rows from top to bottom and columns from left to right. Both flat
+// and run-length encoded scanlines are read. The exposure of the image is not applied.
+func ReadHDR(reader io.Reader) (*Canvas, error) {
+	r := bufio.NewReader(reader)
+
+	// The header is a list of variables, followed by a blank line and the resolution
+	headerLength := 0
+	format := ""
+	for first := true; ; first = false {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("failed to read HDR header: %v", err)
+		}
+		headerLength += len(line)
+		if headerLength > maxHDRHeaderLength {
+			return nil, errors.New("HDR header is too long")
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if first {
+			if !strings.HasPrefix(line, "#?") {
+				return nil, errors.New("missing HDR identifier")
+			}
+			continue
+		}
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "FORMAT=") {
+			format = strings.TrimPrefix(line, "FORMAT=")
+		}
+	}
+	if format != "" && format != hdrFormat {
+		return nil, fmt.Errorf("unsupported HDR format %q", format)
+	}
+
+	resolution, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HDR resolution: %v", err)
+	}
+	var width, height int
+	_, err = fmt.Sscanf(resolution, "-Y %d +X %d", &height, &width)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported HDR resolution %q", strings.TrimSpace(resolution))
+	}
+	if width < 0 || height < 0 || (width > 0 && height > maxPPMPixels/width) {
+		return nil, fmt.Errorf("invalid HDR image size of %dx%d pixels", width, height)
+	}
+
+	c := NewCanvas(width, height)
+	rgbe := make([]byte, width*4)
+	for y := 0; y < height; y++ {
+		if err := readHDRScanline(r, rgbe); err != nil {
+			return nil, fmt.Errorf("failed to read HDR scanline %d: %v", y, err)
+		}
+
+		for x := 0; x < width; x++ {
+			c.Pixels[y][x] = rgbeColor(rgbe[x*4:])
+		}
+	}
+
+	return c, nil
+}
+
+// readHDRScanline reads a scanline of RGBE pixels into the passed slice.
+func readHDRScanline(r *bufio.Reader, rgbe []byte) error {
+	width := len(rgbe) / 4
+	if width == 0 {
+		return nil
+	}
+
+	// Scanlines that are run-length encoded start with two 2s and their width
+	start, err := r.Peek(4)
+	if err != nil {
+		return err
+	}
+	if width < minHDRRLEWidth || width > maxHDRRLEWidth ||
+		start[0] != 2 || start[1] != 2 || start[2]&0x80 != 0 {
+		return readFlatHDRScanline(r, rgbe)
+	}
+	if int(start[2])<<8|int(start[3]) != width {
+		return errors.New("scanline width doesn't match the image width")
+	}
+	if _, err := r.Discard(4); err != nil {
+		return err
+	}
+
+	for component := 0; component < 4; component++ {
+		for x := 0; x < width; {
+			count, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+
+			if count > 128 {
+				// A run of a single value
+				runLength := int(count) - 128
+				if x+runLength > width {
+					return errors.New("run overruns the scanline")
+				}
+				value, err := r.ReadByte()
+				if err != nil {
+					return err
+				}
+				for ; runLength > 0; runLength-- {
+					rgbe[x*4+component] = value
+					x++
+				}
+				continue
+			}
+
+			// A count of literal values
+			if count == 0 || x+int(count) > width {
+				return errors.New("invalid count of literal values in scanline")
+			}
+			for i := 0; i < int(count); i++ {
+				value, err := r.ReadByte()
+				if err != nil {
+					return err
+				}
+				rgbe[x*4+component] = value
+				x++
+			}
+		}
+	}
+
+	return nil
+}
+
+// readFlatHDRScanline reads a scanline of RGBE pixels that isn't run-length encoded into
+// the passed slice. The scanline may use the original encoding of runs, in which a pixel
+// of three 1s repeats the previous pixel a number of times given by its exponent.
+func readFlatHDRScanline(r *bufio.Reader, rgbe []byte) error {
+	width := len(rgbe) / 4
+	shift := uint(0)
+	for x := 0; x < width; {
+		pixel := rgbe[x*4 : x*4+4]
+		if _, err := io.ReadFull(r, pixel); err != nil {
+			return err
+		}
+
+		if pixel[0] != 1 || pixel[1] != 1 || pixel[2] != 1 || x == 0 {
+			shift = 0
+			x++
+			continue
+		}
+
+		// Repeat the previous pixel, with the count shifted by the runs before it
+		count := int(pixel[3]) << shift
+		if x+count > width {
+			return errors.New("run overruns the scanline")
+		}
+		for ; count > 0; count-- {
+			copy(rgbe[x*4:x*4+4], rgbe[(x-1)*4:x*4])
+			x++
+		}
+		shift += 8
+		if shift > 16 {
+			return errors.New("run is too long")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package canvas
+
+import (
+	"bytes"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPutRGBE(t *testing.T) {
+	tests := []struct {
+		name string
+		c    color.Color
+		want []byte
+	}{
+		{
+			name: "black",
+			c:    *color.NewColor(0, 0, 0),
+			want: []byte{0, 0, 0, 0},
+		},
+		{
+			name: "white",
+			c:    *color.NewColor(1, 1, 1),
+			want: []byte{128, 128, 128, 129},
+		},
+		{
+			name: "bright color shares the exponent of its brightest component",
+			c:    *color.NewColor(10, 5, 0.5),
+			want: []byte{160, 80, 8, 132},
+		},
+		{
+			name: "negative components are written as 0",
+			c:    *color.NewColor(-1, 0.5, 0),
+			want: []byte{0, 128, 0, 128},
+		},
+		{
+			name: "infinity is clamped",
+			c:    *color.NewColor(math.Inf(1), 0, 0),
+			want: []byte{255, 255, 255, 255},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rgbe := make([]byte, 4)
+			putRGBE(rgbe, tt.c)
+			assert.Equal(t, tt.want, rgbe)
+		})
+	}
+}
+
+func TestCanvas_WriteHDR(t *testing.T) {
+	tests := []struct {
+		name       string
+		c          *Canvas
+		wantPixels string
+	}{
+		{
+			name:       "narrow scanlines are flat",
+			c:          NewCanvas(2, 1),
+			wantPixels: "\x80\x80\x80\x81\x80\x80\x80\x81",
+		},
+		{
+			name: "wide scanlines are run-length encoded",
+			c:    NewCanvas(8, 1),
+			wantPixels: "\x02\x02\x00\x08" +
+				"\x88\x80" + "\x88\x80" + "\x88\x80" + "\x88\x81",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for x := 0; x < tt.c.Width; x++ {
+				assert.NoError(t, tt.c.WritePixel(x, 0, *color.NewColor(1, 1, 1)))
+			}
+
+			writer := &bytes.Buffer{}
+			assert.NoError(t, tt.c.WriteHDR(writer))
+			assert.Equal(t, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 1 +X "+
+				string('0'+rune(tt.c.Width))+"\n"+tt.wantPixels, writer.String())
+		})
+	}
+
+	assert.Error(t, NewCanvas(1, 1).WriteHDR(nil))
+}
+
+func TestReadHDR(t *testing.T) {
+	header := "#?RADIANCE\n# a comment\nFORMAT=32-bit_rle_rgbe\nEXPOSURE=1.0\n\n"
+	tests := []struct {
+		name    string
+		hdr     string
+		want    []color.Color
+		wantErr bool
+	}{
+		{
+			name: "flat scanline",
+			hdr:  header + "-Y 1 +X 2\n\x80\x40\x00\x81\x00\x00\x00\x00",
+			want: []color.Color{
+				*color.NewColor(1.00390625, 0.50390625, 0.00390625),
+				*color.NewColor(0, 0, 0),
+			},
+		},
+		{
+			name: "flat scanline with an original run",
+			hdr:  header + "-Y 1 +X 3\n\x80\x40\x00\x81\x01\x01\x01\x02",
+			want: []color.Color{
+				*color.NewColor(1.00390625, 0.50390625, 0.00390625),
+				*color.NewColor(1.00390625, 0.50390625, 0.00390625),
+				*color.NewColor(1.00390625, 0.50390625, 0.00390625),
+			},
+		},
+		{
+			name: "run-length encoded scanline",
+			hdr: header + "-Y 1 +X 8\n\x02\x02\x00\x08" +
+				"\x88\x80" + "\x02\x40\x40\x86\x00" + "\x88\x00" + "\x88\x81",
+			want: []color.Color{
+				*color.NewColor(1.00390625, 0.50390625, 0.00390625),
+				*color.NewColor(1.00390625, 0.50390625, 0.00390625),
+				*color.NewColor(1.00390625, 0.00390625, 0.00390625),
+				*color.NewColor(1.00390625, 0.00390625, 0.00390625),
+				*color.NewColor(1.00390625, 0.00390625, 0.00390625),
+				*color.NewColor(1.00390625, 0.00390625, 0.00390625),
+				*color.NewColor(1.00390625, 0.00390625, 0.00390625),
+				*color.NewColor(1.00390625, 0.00390625, 0.00390625),
+			},
+		},
+		{
+			name:    "missing identifier",
+			hdr:     "FORMAT=32-bit_rle_rgbe\n\n-Y 1 +X 1\n\x00\x00\x00\x00",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported format",
+			hdr:     "#?RADIANCE\nFORMAT=32-bit_rle_xyze\n\n-Y 1 +X 1\n\x00\x00\x00\x00",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported orientation",
+			hdr:     header + "+Y 1 +X 1\n\x00\x00\x00\x00",
+			wantErr: true,
+		},
+		{
+			name:    "run overruns the scanline",
+			hdr:     header + "-Y 1 +X 8\n\x02\x02\x00\x08\x89\x80",
+			wantErr: true,
+		},
+		{
+			name:    "too few pixels",
+			hdr:     header + "-Y 2 +X 1\n\x00\x00\x00\x00",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ReadHDR(strings.NewReader(tt.hdr))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, 1, c.Height)
+			assert.Equal(t, tt.want, c.Pixels[0])
+		})
+	}
+}
+
+func TestReadHDR_RoundTrip(t *testing.T) {
+	// A canvas wide enough for run-length encoding, with runs and literal values
+	c := NewCanvas(300, 4)
+	for y := 0; y < c.Height; y++ {
+		for x := 0; x < c.Width; x++ {
+			clr := *color.NewColor(float64(x/10)*0.75, float64(y)*20, 0.01*float64(x%7))
+			assert.NoError(t, c.WritePixel(x, y, clr))
+		}
+	}
+
+	writer := &bytes.Buffer{}
+	assert.NoError(t, c.WriteHDR(writer))
+	assert.True(t, writer.Len() < c.Width*c.Height*4)
+
+	read, err := ReadHDR(writer)
+	assert.NoError(t, err)
+	assert.Equal(t, c.Width, read.Width)
+	assert.Equal(t, c.Height, read.Height)
+	for y := 0; y < c.Height; y++ {
+		for x := 0; x < c.Width; x++ {
+			want := c.Pixels[y][x]
+			got := read.Pixels[y][x]
+
+			// Each component is within the precision of the shared exponent
+			tolerance := math.Max(want.Red, math.Max(want.Green, want.Blue)) / 128
+			assert.InDelta(t, want.Red, got.Red, tolerance)
+			assert.InDelta(t, want.Green, got.Green, tolerance)
+			assert.InDelta(t, want.Blue, got.Blue, tolerance)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package canvas
+
+import (
+	"errors"
+	"fmt"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"image"
+	imagecolor "image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+)
+
+const (
+	// maxImageColorValue is the maximum value of a color component of an image.Image.
+	maxImageColorValue = 0xffff
+	// DefaultJPEGQuality is the quality that JPEG files are written with by default.
+	DefaultJPEGQuality = jpeg.DefaultQuality
+)
+
+// Canvas implements draw.Image, so that it can be used with the image packages of the
+// standard library. The colors of the canvas are clamped to the range [0, 1] and have
+// 16 bits per component when they're read as an image.
+var _ draw.Image = (*Canvas)(nil)
+
+// ColorModel returns the color model of the Canvas as an image.Image.
+func (c *Canvas) ColorModel() imagecolor.Model {
+	return imagecolor.RGBA64Model
+}
+
+// Bounds returns the bounds of the Canvas as an image.Image,
+// which start at (0, 0) and have the width and height of the canvas.
+func (c *Canvas) Bounds() image.Rectangle {
+	return image.Rect(0, 0, c.Width, c.Height)
+}
+
+// At returns the color of the pixel located at the passed x and y values as an image
+// color, which is alpha-premultiplied like the colors of the Canvas. Pixels outside of
+// the bounds of the Canvas are transparent black.
+func (c *Canvas) At(x, y int) imagecolor.Color {
+	if c.ValidateInCanvasBounds(x, y) != nil {
+		return imagecolor.RGBA64{}
+	}
+
+	// An alpha-premultiplied component can't be greater than the alpha
+	pixel := c.Pixels[y][x]
+	alpha := scaleColorValue(c.alphaAt(x, y), maxImageColorValue)
+	return imagecolor.RGBA64{
+		R: uint16(math.Min(float64(scaleColorValue(pixel.Red, maxImageColorValue)), float64(alpha))),
+		G: uint16(math.Min(float64(scaleColorValue(pixel.Green, maxImageColorValue)), float64(alpha))),
+		B: uint16(math.Min(float64(scaleColorValue(pixel.Blue, maxImageColorValue)), float64(alpha))),
+		A: uint16(alpha),
+	}
+}
+
+// Set sets the pixel located at the passed x and y values to the passed image color.
+// Setting a translucent color on a Canvas without alpha gives it alpha, with every
+// other pixel opaque. Pixels outside of the bounds of the Canvas are ignored.
+func (c *Canvas) Set(x, y int, clr imagecolor.Color) {
+	if c.ValidateInCanvasBounds(x, y) != nil {
+		return
+	}
+
+	// The components are alpha-premultiplied, like the colors of the canvas
+	r, g, b, a := clr.RGBA()
+	c.Pixels[y][x] = *color.NewColor(
+		float64(r)/maxImageColorValue,
+		float64(g)/maxImageColorValue,
+		float64(b)/maxImageColorValue)
+
+	if a != maxImageColorValue || c.Alpha != nil {
+		_ = c.WriteAlpha(x, y, float64(a)/maxImageColorValue)
+	}
+}
+
+// FromImage returns a new Canvas with the pixels of the passed image. The top-left
+// pixel of the bounds of the image becomes the pixel at (0, 0) of the canvas.
+func FromImage(img image.Image) *Canvas {
+	bounds := img.Bounds()
+	c := NewCanvas(bounds.Dx(), bounds.Dy())
+	draw.Draw(c, c.Bounds(), img, bounds.Min, draw.Src)
+	return c
+}
+
+// ToRGBA returns the Canvas as an image with 8 bits per color component, which is how it's
+// written to 8-bit image formats without alpha, such as JPEG files. The colors of the image
+// are transformed by the OutputTransform of the canvas and premultiplied by their alpha,
+// which composites them over black. Unlike At, which returns the colors of the canvas
+// as they are, ToRGBA transforms them.
+func (c *Canvas) ToRGBA() *image.RGBA {
+	img := image.NewRGBA(c.Bounds())
+	for y, row := range c.Pixels {
+		pix := img.Pix[y*img.Stride:]
+		for x, pixel := range row {
+			alpha := c.alphaAt(x, y)
+			pixel = c.flatDisplayColor(pixel, alpha)
+			pix[x*4] = uint8(scaleColorValue(pixel.Red, maxColorValue))
+			pix[x*4+1] = uint8(scaleColorValue(pixel.Green, maxColorValue))
+			pix[x*4+2] = uint8(scaleColorValue(pixel.Blue, maxColorValue))
+			pix[x*4+3] = uint8(scaleColorValue(alpha, maxColorValue))
+		}
+	}
+
+	return img
+}
+
+// ToNRGBA returns the Canvas as an image with 8 bits per color component whose colors aren't
+// premultiplied by their alpha, which is how it's written to PNG files. The colors of the
+// image are transformed by the OutputTransform of the canvas.
+func (c *Canvas) ToNRGBA() *image.NRGBA {
+	img := image.NewNRGBA(c.Bounds())
+	for y, row := range c.Pixels {
+		pix := img.Pix[y*img.Stride:]
+		for x, pixel := range row {
+			alpha := c.alphaAt(x, y)
+			pixel = c.displayColor(pixel, alpha)
+			pix[x*4] = uint8(scaleColorValue(pixel.Red, maxColorValue))
+			pix[x*4+1] = uint8(scaleColorValue(pixel.Green, maxColorValue))
+			pix[x*4+2] = uint8(scaleColorValue(pixel.Blue, maxColorValue))
+			pix[x*4+3] = uint8(scaleColorValue(alpha, maxColorValue))
+		}
+	}
+
+	return img
+}
+
+// WritePNG writes the canvas to the passed writer as a PNG file with 8 bits per color
+// component. If the canvas has alpha, then the PNG file has an alpha channel.
+func (c *Canvas) WritePNG(writer io.Writer) error {
+	if writer == nil {
+		return errors.New("writer must not be nil")
+	}
+
+	return png.Encode(writer, c.ToNRGBA())
+}
+
+// WriteJPEG writes the canvas to the passed writer as a JPEG file with the passed
+// quality, which must be between 1 and 100. Higher qualities produce larger files
+// with fewer compression artifacts. DefaultJPEGQuality is a good default.
+// JPEG files have no alpha, so transparent pixels are composited over black.
+func (c *Canvas) WriteJPEG(writer io.Writer, quality int) error {
+	if writer == nil {
+		return errors.New("writer must not be nil")
+	}
+
+	if quality < 1 || quality > 100 {
+		return fmt.Errorf("JPEG quality '%v' must be between 1 and 100", quality)
+	}
+
+	return jpeg.Encode(writer, c.ToRGBA(), &jpeg.Options{Quality: quality})
+}
@@ -0,0 +1,178 @@
+package canvas
+
+import (
+	"bytes"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/stretchr/testify/assert"
+	"image"
+	imagecolor "image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestCanvas_At(t *testing.T) {
+	c := NewCanvas(2, 1)
+	assert.NoError(t, c.WritePixel(1, 0, *color.NewColor(1.5, 0.5, -1)))
+
+	tests := []struct {
+		name string
+		x    int
+		y    int
+		want imagecolor.Color
+	}{
+		{
+			name: "pixel with clamped color values",
+			x:    1,
+			y:    0,
+			want: imagecolor.RGBA64{R: 0xffff, G: 0x8000, B: 0, A: 0xffff},
+		},
+		{
+			name: "black pixel",
+			x:    0,
+			y:    0,
+			want: imagecolor.RGBA64{A: 0xffff},
+		},
+		{
+			name: "pixel outside of the canvas",
+			x:    2,
+			y:    0,
+			want: imagecolor.RGBA64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, c.At(tt.x, tt.y))
+		})
+	}
+
+	assert.Equal(t, image.Rect(0, 0, 2, 1), c.Bounds())
+	assert.Equal(t, imagecolor.RGBA64Model, c.ColorModel())
+}
+
+func TestCanvas_Set(t *testing.T) {
+	tests := []struct {
+		name string
+		clr  imagecolor.Color
+		want color.Color
+	}{
+		{
+			name: "opaque color",
+			clr:  imagecolor.RGBA{R: 255, G: 51, B: 0, A: 255},
+			want: *color.NewColor(1, 0.2, 0),
+		},
+		{
+			name: "translucent color is drawn over black",
+			clr:  imagecolor.NRGBA{R: 255, G: 255, B: 0, A: 51},
+			want: *color.NewColor(0.2, 0.2, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCanvas(1, 1)
+			c.Set(0, 0, tt.clr)
+			assert.True(t, color.Equals(tt.want, c.Pixels[0][0]))
+
+			// Pixels outside of the canvas are ignored
+			c.Set(1, 1, tt.clr)
+		})
+	}
+}
+
+func TestFromImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(10, 20, 12, 23))
+	img.Set(10, 20, imagecolor.RGBA{R: 255, A: 255})
+	img.Set(11, 22, imagecolor.RGBA{G: 102, B: 255, A: 255})
+
+	c := FromImage(img)
+	assert.Equal(t, 2, c.Width)
+	assert.Equal(t, 3, c.Height)
+	assert.True(t, color.Equals(*color.NewColor(1, 0, 0), c.Pixels[0][0]))
+	assert.True(t, color.Equals(*color.NewColor(0, 0.4, 1), c.Pixels[2][1]))
+	assert.True(t, color.Equals(*color.NewColor(0, 0, 0), c.Pixels[1][0]))
+}
+
+func TestCanvas_WritePNG(t *testing.T) {
+	c := NewCanvas(3, 2)
+	assert.NoError(t, c.WritePixel(0, 0, *color.NewColor(1, 0.5, 0)))
+	assert.NoError(t, c.WritePixel(2, 1, *color.NewColor(0.2, 0.4, 2)))
+
+	writer := &bytes.Buffer{}
+	assert.NoError(t, c.WritePNG(writer))
+
+	img, err := png.Decode(writer)
+	assert.NoError(t, err)
+	assert.Equal(t, c.Bounds(), img.Bounds())
+	for y := 0; y < c.Height; y++ {
+		for x := 0; x < c.Width; x++ {
+			assert.Equal(t, imagecolor.RGBAModel.Convert(c.At(x, y)),
+				imagecolor.RGBAModel.Convert(img.At(x, y)))
+		}
+	}
+
+	// Reading the PNG file into a canvas and writing it again produces the same file
+	rewritten := &bytes.Buffer{}
+	assert.NoError(t, FromImage(img).WritePNG(rewritten))
+	written := &bytes.Buffer{}
+	assert.NoError(t, c.WritePNG(written))
+	assert.Equal(t, written.Bytes(), rewritten.Bytes())
+
+	assert.Error(t, c.WritePNG(nil))
+}
+
+func TestCanvas_WriteJPEG(t *testing.T) {
+	c := NewCanvas(16, 16)
+	for y := 0; y < c.Height; y++ {
+		for x := 0; x < c.Width; x++ {
+			assert.NoError(t, c.WritePixel(x, y, *color.NewColor(0.8, 0.4, 0.2)))
+		}
+	}
+
+	tests := []struct {
+		name    string
+		quality int
+		wantErr bool
+	}{
+		{
+			name:    "default quality",
+			quality: DefaultJPEGQuality,
+		},
+		{
+			name:    "highest quality",
+			quality: 100,
+		},
+		{
+			name:    "quality too low",
+			quality: 0,
+			wantErr: true,
+		},
+		{
+			name:    "quality too high",
+			quality: 101,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &bytes.Buffer{}
+			err := c.WriteJPEG(writer, tt.quality)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			img, err := jpeg.Decode(writer)
+			assert.NoError(t, err)
+			assert.Equal(t, c.Bounds(), img.Bounds())
+
+			// A flat color survives compression to within a few values
+			r, g, b, _ := img.At(8, 8).RGBA()
+			assert.InDelta(t, 0.8, float64(r)/0xffff, 0.02)
+			assert.InDelta(t, 0.4, float64(g)/0xffff, 0.02)
+			assert.InDelta(t, 0.2, float64(b)/0xffff, 0.02)
+		})
+	}
+
+	assert.Error(t, c.WriteJPEG(nil, DefaultJPEGQuality))
+}
@@ -0,0 +1,185 @@
+package canvas
+
+import (
+	"github.com/austingebauer/go-ray-tracer/color"
+	"math"
+)
+
+// ToneMapOperator maps linear color values, which may be brighter than 1, into the range [0, 1].
+type ToneMapOperator int
+
+const (
+	// ToneMapClamp clamps color values to the range [0, 1], which blows out highlights.
+	ToneMapClamp ToneMapOperator = iota
+	// ToneMapReinhard maps each color value v to v / (1 + v), which compresses all highlights
+	// but never reaches white.
+	ToneMapReinhard
+	// ToneMapExtendedReinhard is the Reinhard operator extended with a white point,
+	// so that values at the white point map to white.
+	ToneMapExtendedReinhard
+	// ToneMapACES is a fit of the ACES filmic curve, which has a toe in the shadows and a
+	// soft shoulder in the highlights.
+	ToneMapACES
+	// ToneMapHable is the filmic curve of John Hable, which was used in Uncharted 2.
+	// Values at the white point map to white.
+	ToneMapHable
+)
+
+const (
+	// DefaultReinhardWhitePoint is the white point of the extended Reinhard operator by default.
+	DefaultReinhardWhitePoint = 4.0
+	// DefaultHableWhitePoint is the white point of the Hable operator by default.
+	DefaultHableWhitePoint = 11.2
+	// hableExposureBias is the exposure applied to colors before the Hable curve.
+	hableExposureBias = 2.0
+)
+
+// OutputTransform transforms the linear colors of a canvas into the colors that are written
+// to files with 8 bits per color component, such as PNG, JPEG, and PPM files. The colors are
+// exposed, tone mapped, and then encoded for display. The colors of the canvas, and the high
+// dynamic range files that are written from it, are left linear.
+type OutputTransform struct {
+	// Exposure is the exposure adjustment in stops (EV). Each stop doubles the brightness.
+	Exposure float64
+
+	// ToneMap is the operator that maps the exposed colors into the range [0, 1].
+	ToneMap ToneMapOperator
+
+	// WhitePoint is the exposed color value that maps to white by the extended Reinhard and
+	// Hable operators. If it's 0, then the default white point of the operator is used.
+	WhitePoint float64
+
+	// SRGB encodes the tone mapped colors using the sRGB transfer function,
+	// which most displays and image viewers expect.
+	SRGB bool
+}
+
+// NewOutputTransform returns a new OutputTransform that maps colors with the ACES filmic
+// curve and encodes them as sRGB, which suits most renders.
+func NewOutputTransform() *OutputTransform {
+	return &OutputTransform{
+		ToneMap: ToneMapACES,
+		SRGB:    true,
+	}
+}
+
+// Apply returns the passed linear color transformed for display,
+// with each of its components in the range [0, 1].
+func (o *OutputTransform) Apply(c color.Color) color.Color {
+	return color.Color{
+		Red:   o.applyValue(c.Red),
+		Green: o.applyValue(c.Green),
+		Blue:  o.applyValue(c.Blue),
+	}
+}
+
+// applyValue returns the passed linear color component transformed for display.
+func (o *OutputTransform) applyValue(v float64) float64 {
+	// Negative and NaN values are black
+	if !(v > 0) {
+		return 0
+	}
+
+	v = ToneMap(v*math.Exp2(o.Exposure), o.ToneMap, o.WhitePoint)
+	if o.SRGB {
+		v = SRGBEncode(v)
+	}
+
+	return v
+}
+
+// ToneMap returns the passed linear color value mapped into the range [0, 1] by the passed
+// operator. The white point is used by the extended Reinhard and Hable operators, and if it's
+// 0, then the default white point of the operator is used.
+func ToneMap(v float64, operator ToneMapOperator, whitePoint float64) float64 {
+	if !(v > 0) {
+		return 0
+	}
+
+	switch operator {
+	case ToneMapReinhard:
+		v = v / (1 + v)
+	case ToneMapExtendedReinhard:
+		if whitePoint == 0 {
+			whitePoint = DefaultReinhardWhitePoint
+		}
+		v = v * (1 + v/(whitePoint*whitePoint)) / (1 + v)
+	case ToneMapACES:
+		// The fit of the ACES curve by Krzysztof Narkowicz
+		v = (v * (2.51*v + 0.03)) / (v*(2.43*v+0.59) + 0.14)
+	case ToneMapHable:
+		if whitePoint == 0 {
+			whitePoint = DefaultHableWhitePoint
+		}
+		v = hable(v*hableExposureBias) / hable(whitePoint)
+	}
+
+	return math.Min(math.Max(v, 0), 1)
+}
+
+// hable returns the passed value mapped by the filmic curve of John Hable.
+func hable(v float64) float64 {
+	const (
+		shoulderStrength = 0.15
+		linearStrength   = 0.50
+		linearAngle      = 0.10
+		toeStrength      = 0.20
+		toeNumerator     = 0.02
+		toeDenominator   = 0.30
+	)
+
+	return (v*(shoulderStrength*v+linearAngle*linearStrength)+toeStrength*toeNumerator)/
+		(v*(shoulderStrength*v+linearStrength)+toeStrength*toeDenominator) -
+		toeNumerator/toeDenominator
+}
+
+// SRGBEncode returns the passed linear value in the range [0, 1]
+// encoded by the sRGB transfer function (OETF).
+func SRGBEncode(v float64) float64 {
+	if v <= 0.0031308 {
+		return 12.92 * v
+	}
+
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// SRGBDecode returns the passed sRGB encoded value in the range [0, 1] as a linear value.
+// It's the inverse of SRGBEncode.
+func SRGBDecode(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// displayColor returns the passed color of a pixel of the Canvas, which is premultiplied by
+// the passed alpha, as it's written to files with 8 bits per color component. The color is
+// divided by its alpha before it's transformed by the OutputTransform, so the returned
+// color isn't premultiplied. Fully transparent pixels are black.
+func (c *Canvas) displayColor(clr color.Color, alpha float64) color.Color {
+	if alpha <= 0 {
+		return color.Color{}
+	}
+	if alpha < 1 {
+		clr = color.Color{Red: clr.Red / alpha, Green: clr.Green / alpha, Blue: clr.Blue / alpha}
+	}
+	if c.OutputTransform == nil {
+		return clr
+	}
+
+	return c.OutputTransform.Apply(clr)
+}
+
+// flatDisplayColor returns the passed color of a pixel of the Canvas, which is premultiplied
+// by the passed alpha, as it's written to files with 8 bits per color component that don't
+// have alpha. The transformed color is composited over black.
+func (c *Canvas) flatDisplayColor(clr color.Color, alpha float64) color.Color {
+	if alpha >= 1 && c.OutputTransform == nil {
+		return clr
+	}
+
+	clr = c.displayColor(clr, alpha)
+	alpha = math.Min(alpha, 1)
+	return color.Color{Red: clr.Red * alpha, Green: clr.Green * alpha, Blue: clr.Blue * alpha}
+}
@@ -0,0 +1,178 @@
+package canvas
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/austingebauer/go-ray-tracer/maths"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestToneMap(t *testing.T) {
+	tests := []struct {
+		name       string
+		v          float64
+		operator   ToneMapOperator
+		whitePoint float64
+		want       float64
+	}{
+		{name: "clamp in range", v: 0.5, operator: ToneMapClamp, want: 0.5},
+		{name: "clamp highlight", v: 4, operator: ToneMapClamp, want: 1},
+		{name: "negative values are black", v: -1, operator: ToneMapReinhard, want: 0},
+		{name: "NaN is black", v: math.NaN(), operator: ToneMapACES, want: 0},
+		{name: "Reinhard", v: 1, operator: ToneMapReinhard, want: 0.5},
+		{name: "Reinhard highlight", v: 3, operator: ToneMapReinhard, want: 0.75},
+		{name: "extended Reinhard white point", v: 2, operator: ToneMapExtendedReinhard,
+			whitePoint: 2, want: 1},
+		{name: "extended Reinhard default white point", v: 4,
+			operator: ToneMapExtendedReinhard, want: 1},
+		{name: "extended Reinhard", v: 1, operator: ToneMapExtendedReinhard,
+			whitePoint: 2, want: 0.625},
+		{name: "ACES", v: 1, operator: ToneMapACES, want: 2.54 / 3.16},
+		{name: "ACES highlight", v: 100, operator: ToneMapACES, want: 1},
+		{name: "Hable white point", v: DefaultHableWhitePoint / hableExposureBias,
+			operator: ToneMapHable, want: 1},
+		{name: "Hable custom white point", v: 4 / hableExposureBias,
+			operator: ToneMapHable, whitePoint: 4, want: 1},
+		{name: "Hable", v: 0.18, operator: ToneMapHable, want: 0.12834},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, ToneMap(tt.v, tt.operator, tt.whitePoint), maths.Epsilon)
+		})
+	}
+}
+
+func TestToneMap_Monotonic(t *testing.T) {
+	operators := []ToneMapOperator{
+		ToneMapClamp, ToneMapReinhard, ToneMapExtendedReinhard, ToneMapACES, ToneMapHable,
+	}
+	for _, operator := range operators {
+		previous := ToneMap(0, operator, 0)
+		for v := 0.01; v < 20; v += 0.01 {
+			mapped := ToneMap(v, operator, 0)
+			assert.True(t, mapped >= previous)
+			assert.True(t, mapped >= 0 && mapped <= 1)
+			previous = mapped
+		}
+	}
+}
+
+func TestSRGBEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		v    float64
+		want float64
+	}{
+		{name: "black", v: 0, want: 0},
+		{name: "white", v: 1, want: 1},
+		{name: "linear segment", v: 0.002, want: 0.02584},
+		{name: "middle gray", v: 0.18, want: 0.46135},
+		{name: "half", v: 0.5, want: 0.73536},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := SRGBEncode(tt.v)
+			assert.InDelta(t, tt.want, encoded, maths.Epsilon)
+			assert.InDelta(t, tt.v, SRGBDecode(encoded), maths.Epsilon)
+		})
+	}
+}
+
+func TestOutputTransform_Apply(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform *OutputTransform
+		c         color.Color
+		want      color.Color
+	}{
+		{
+			name:      "identity",
+			transform: &OutputTransform{},
+			c:         *color.NewColor(0.25, 1.5, -0.5),
+			want:      *color.NewColor(0.25, 1, 0),
+		},
+		{
+			name:      "exposure in stops",
+			transform: &OutputTransform{Exposure: 1},
+			c:         *color.NewColor(0.25, 0.125, 0),
+			want:      *color.NewColor(0.5, 0.25, 0),
+		},
+		{
+			name:      "negative exposure",
+			transform: &OutputTransform{Exposure: -2},
+			c:         *color.NewColor(2, 1, 0),
+			want:      *color.NewColor(0.5, 0.25, 0),
+		},
+		{
+			name:      "exposure then tone mapping",
+			transform: &OutputTransform{Exposure: 1, ToneMap: ToneMapReinhard},
+			c:         *color.NewColor(0.5, 1.5, 0),
+			want:      *color.NewColor(0.5, 0.75, 0),
+		},
+		{
+			name:      "tone mapping then sRGB encoding",
+			transform: &OutputTransform{ToneMap: ToneMapReinhard, SRGB: true},
+			c:         *color.NewColor(1, 0, 0),
+			want:      *color.NewColor(0.73536, 0, 0),
+		},
+		{
+			name:      "default transform",
+			transform: NewOutputTransform(),
+			c:         *color.NewColor(1, 0.18, 100),
+			want:      *color.NewColor(SRGBEncode(2.54/3.16), SRGBEncode(ToneMap(0.18, ToneMapACES, 0)), 1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.transform.Apply(tt.c)
+			assert.InDelta(t, tt.want.Red, got.Red, maths.Epsilon)
+			assert.InDelta(t, tt.want.Green, got.Green, maths.Epsilon)
+			assert.InDelta(t, tt.want.Blue, got.Blue, maths.Epsilon)
+		})
+	}
+}
+
+func TestCanvas_OutputTransform(t *testing.T) {
+	c := NewCanvas(1, 1)
+	assert.NoError(t, c.WritePixel(0, 0, *color.NewColor(0.5, 2, 0.18)))
+	c.OutputTransform = &OutputTransform{ToneMap: ToneMapReinhard, SRGB: true}
+
+	// Each of the 8-bit writers transforms the colors
+	want := []byte{
+		byte(math.Round(SRGBEncode(0.5/1.5) * 255)),
+		byte(math.Round(SRGBEncode(2.0/3.0) * 255)),
+		byte(math.Round(SRGBEncode(0.18/1.18) * 255)),
+	}
+
+	binary := &bytes.Buffer{}
+	assert.NoError(t, c.WritePPMBinary(binary))
+	assert.Equal(t, want, binary.Bytes()[len(binary.Bytes())-3:])
+
+	plain := &bytes.Buffer{}
+	assert.NoError(t, c.WritePPM(plain))
+	read, err := ReadPPM(bytes.NewReader(plain.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(want[1])/255, read.Pixels[0][0].Green)
+
+	// ToPPM truncates the transformed colors instead of rounding them
+	templated := &bytes.Buffer{}
+	assert.NoError(t, c.ToPPM(templated, PixelMapTemplate))
+	assert.Equal(t, fmt.Sprintf("P3\n1 1\n255\n%d %d %d\n",
+		int(SRGBEncode(0.5/1.5)*255),
+		int(SRGBEncode(2.0/3.0)*255),
+		int(SRGBEncode(0.18/1.18)*255)), templated.String())
+
+	rgba := c.ToRGBA()
+	assert.Equal(t, want, rgba.Pix[:3])
+
+	// The high dynamic range writers and the colors of the canvas are left linear
+	pfm := &bytes.Buffer{}
+	assert.NoError(t, c.WritePFM(pfm))
+	linear, err := ReadPFM(pfm)
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, linear.Pixels[0][0].Green)
+	assert.Equal(t, 2.0, c.Pixels[0][0].Green)
+}
@@ -0,0 +1,135 @@
+package canvas
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"io"
+	"math"
+	"strconv"
+)
+
+const (
+	// pfmColorID is the identifier of a color portable float map (PFM) file.
+	pfmColorID = "PF"
+	// pfmGrayID is the identifier of a grayscale portable float map (PFM) file.
+	pfmGrayID = "Pf"
+)
+
+// WritePFM writes the canvas to the passed writer in the portable float map (PFM) format,
+// which stores each color component as a 32-bit float. Unlike the PPM format, the colors
+// aren't clamped, so values above 1 survive for exposure and compositing in post.
+func (c *Canvas) WritePFM(writer io.Writer) error {
+	if writer == nil {
+		return errors.New("writer must not be nil")
+	}
+
+	// A negative scale marks the floats as little-endian
+	w := bufio.NewWriter(writer)
+	_, err := fmt.Fprintf(w, "%s\n%d %d\n-1.0\n", pfmColorID, c.Width, c.Height)
+	if err != nil {
+		return err
+	}
+
+	// The rows of a PFM file go from the bottom of the image to the top
+	row := make([]byte, c.Width*3*4)
+	for y := c.Height - 1; y >= 0; y-- {
+		for x, pixel := range c.Pixels[y] {
+			binary.LittleEndian.PutUint32(row[x*12:], math.Float32bits(float32(pixel.Red)))
+			binary.LittleEndian.PutUint32(row[x*12+4:], math.Float32bits(float32(pixel.Green)))
+			binary.LittleEndian.PutUint32(row[x*12+8:], math.Float32bits(float32(pixel.Blue)))
+		}
+
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+// ReadPFM reads a canvas from the passed reader, which holds an image in the color (PF) or
+// grayscale (Pf) portable float map (PFM) format, in either byte order. The scale of the
+// image only gives its byte order, so the color values are read as they are.
+func ReadPFM(reader io.Reader) (*Canvas, error) {
+	r := &ppmReader{r: bufio.NewReader(reader)}
+
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(r.r, magic); err != nil {
+		return nil, fmt.Errorf("failed to read PFM identifier: %v", err)
+	}
+	id := string(magic)
+	if id != pfmColorID && id != pfmGrayID {
+		return nil, fmt.Errorf("unsupported PFM identifier %q", id)
+	}
+
+	width, err := r.readHeaderValue("width")
+	if err != nil {
+		return nil, err
+	}
+	height, err := r.readHeaderValue("height")
+	if err != nil {
+		return nil, err
+	}
+	if width > 0 && height > maxPPMPixels/width {
+		return nil, fmt.Errorf("PFM image of %dx%d pixels is too large", width, height)
+	}
+
+	// The scale is the last value of the header, and a single whitespace character follows it
+	if err := r.skipSpaceAndComments(); err != nil {
+		return nil, fmt.Errorf("failed to read PFM scale: %v", err)
+	}
+	scaleText, err := r.r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PFM scale: %v", err)
+	}
+	scale, err := strconv.ParseFloat(trimPPMSpace(scaleText), 64)
+	if err != nil || scale == 0 || math.IsNaN(scale) {
+		return nil, fmt.Errorf("invalid PFM scale %q", trimPPMSpace(scaleText))
+	}
+
+	var byteOrder binary.ByteOrder = binary.BigEndian
+	if scale < 0 {
+		byteOrder = binary.LittleEndian
+	}
+
+	channels := 3
+	if id == pfmGrayID {
+		channels = 1
+	}
+
+	c := NewCanvas(width, height)
+	row := make([]byte, width*channels*4)
+	for y := height - 1; y >= 0; y-- {
+		if _, err := io.ReadFull(r.r, row); err != nil {
+			return nil, fmt.Errorf("failed to read PFM pixel row %d: %v", y, err)
+		}
+
+		for x := 0; x < width; x++ {
+			var components [3]float64
+			for i := range components {
+				offset := (x*channels + i%channels) * 4
+				components[i] = float64(math.Float32frombits(byteOrder.Uint32(row[offset:])))
+			}
+
+			c.Pixels[y][x] = *color.NewColor(components[0], components[1], components[2])
+		}
+	}
+
+	return c, nil
+}
+
+// trimPPMSpace returns the passed string without its leading and trailing PPM whitespace.
+func trimPPMSpace(s string) string {
+	start, end := 0, len(s)
+	for start < end && isPPMSpace(s[start]) {
+		start++
+	}
+	for end > start && isPPMSpace(s[end-1]) {
+		end--
+	}
+
+	return s[start:end]
+}
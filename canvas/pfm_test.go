@@ -0,0 +1,98 @@
+package canvas
+
+import (
+	"bytes"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCanvas_WritePFM(t *testing.T) {
+	c := NewCanvas(2, 2)
+	assert.NoError(t, c.WritePixel(0, 0, *color.NewColor(1, 2.5, -1)))
+	assert.NoError(t, c.WritePixel(1, 1, *color.NewColor(0.5, 0, 0)))
+
+	writer := &bytes.Buffer{}
+	assert.NoError(t, c.WritePFM(writer))
+
+	// The bottom row is written first
+	assert.Equal(t, "PF\n2 2\n-1.0\n"+
+		"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"+
+		"\x00\x00\x00\x3f\x00\x00\x00\x00\x00\x00\x00\x00"+
+		"\x00\x00\x80\x3f\x00\x00\x20\x40\x00\x00\x80\xbf"+
+		"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00", writer.String())
+
+	assert.Error(t, c.WritePFM(nil))
+}
+
+func TestReadPFM(t *testing.T) {
+	tests := []struct {
+		name    string
+		pfm     string
+		want    [][]color.Color
+		wantErr bool
+	}{
+		{
+			name: "little-endian color",
+			pfm:  "PF\n1 2\n-1.0\n\x00\x00\x00\x3f\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x80\x3f\x00\x00\x20\x40\x00\x00\x80\xbf",
+			want: [][]color.Color{
+				{*color.NewColor(1, 2.5, -1)},
+				{*color.NewColor(0.5, 0, 0)},
+			},
+		},
+		{
+			name: "big-endian grayscale",
+			pfm:  "Pf\n2 1\n1.0\n\x40\x80\x00\x00\x3e\x80\x00\x00",
+			want: [][]color.Color{
+				{*color.NewColor(4, 4, 4), *color.NewColor(0.25, 0.25, 0.25)},
+			},
+		},
+		{
+			name:    "unsupported identifier",
+			pfm:     "P6\n1 1\n255\n\x00\x00\x00",
+			wantErr: true,
+		},
+		{
+			name:    "zero scale",
+			pfm:     "PF\n1 1\n0\n\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00",
+			wantErr: true,
+		},
+		{
+			name:    "too few pixels",
+			pfm:     "PF\n1 1\n-1.0\n\x00\x00\x00\x00",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ReadPFM(strings.NewReader(tt.pfm))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, len(tt.want), c.Height)
+			for y, row := range tt.want {
+				assert.Equal(t, row, c.Pixels[y])
+			}
+		})
+	}
+}
+
+func TestReadPFM_RoundTrip(t *testing.T) {
+	c := NewCanvas(5, 3)
+	for y := 0; y < c.Height; y++ {
+		for x := 0; x < c.Width; x++ {
+			assert.NoError(t, c.WritePixel(x, y, *color.NewColor(float64(x)*10.5, -float64(y), 0.125)))
+		}
+	}
+
+	writer := &bytes.Buffer{}
+	assert.NoError(t, c.WritePFM(writer))
+
+	read, err := ReadPFM(writer)
+	assert.NoError(t, err)
+	assert.Equal(t, c.Pixels, read.Pixels)
+}
@@ -0,0 +1,307 @@
+package canvas
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"io"
+	"strconv"
+)
+
+const (
+	// ppmBinaryID is the identifier of a binary portable pixmap (PPM) file.
+	ppmBinaryID = "P6"
+	// maxPPMLineLength is the maximum number of characters in a line of a plain PPM file.
+	maxPPMLineLength = 70
+	// maxPPMColorValue is the largest maximum color value that a PPM file may have.
+	maxPPMColorValue = 65535
+	// maxPPMPixels is the largest number of pixels that a PPM file read into a canvas may have.
+	maxPPMPixels = 1 << 28
+)
+
+// WritePPM writes the canvas to the passed writer in the plain portable pixmap (PPM)
+// format, which has the identifier P3. Each row of pixels starts on a new line, and
+// no line is longer than 70 characters. The colors are transformed by the OutputTransform
+// of the canvas, and transparent pixels are composited over black.
+func (c *Canvas) WritePPM(writer io.Writer) error {
+	if writer == nil {
+		return errors.New("writer must not be nil")
+	}
+
+	w := bufio.NewWriter(writer)
+	_, err := fmt.Fprintf(w, "%s\n%d %d\n%d\n", ppmID, c.Width, c.Height, maxColorValue)
+	if err != nil {
+		return err
+	}
+
+	// Buffer each line, starting a new line when the next value doesn't fit
+	line := make([]byte, 0, maxPPMLineLength+1)
+	var digits [8]byte
+	for y, row := range c.Pixels {
+		for x, colorVal := range row {
+			colorVal = c.flatDisplayColor(colorVal, c.alphaAt(x, y))
+			for _, component := range [...]float64{colorVal.Red, colorVal.Green, colorVal.Blue} {
+				value := strconv.AppendInt(digits[:0],
+					int64(scaleColorValue(component, maxColorValue)), 10)
+				if len(line) > 0 && len(line)+1+len(value) > maxPPMLineLength {
+					line = append(line, '\n')
+					if _, err := w.Write(line); err != nil {
+						return err
+					}
+					line = line[:0]
+				}
+
+				if len(line) > 0 {
+					line = append(line, ' ')
+				}
+				line = append(line, value...)
+			}
+		}
+
+		if len(line) > 0 {
+			line = append(line, '\n')
+			if _, err := w.Write(line); err != nil {
+				return err
+			}
+			line = line[:0]
+		}
+	}
+
+	return w.Flush()
+}
+
+// WritePPMBinary writes the canvas to the passed writer in the binary portable pixmap (PPM)
+// format, which has the identifier P6. Each color component is written as a single byte,
+// which makes the file much smaller and faster to write than a plain PPM file.
+// The colors are transformed by the OutputTransform of the canvas, and transparent
+// pixels are composited over black.
+func (c *Canvas) WritePPMBinary(writer io.Writer) error {
+	if writer == nil {
+		return errors.New("writer must not be nil")
+	}
+
+	w := bufio.NewWriter(writer)
+	_, err := fmt.Fprintf(w, "%s\n%d %d\n%d\n", ppmBinaryID, c.Width, c.Height, maxColorValue)
+	if err != nil {
+		return err
+	}
+
+	row := make([]byte, c.Width*3)
+	for y, pixels := range c.Pixels {
+		for x, colorVal := range pixels {
+			colorVal = c.flatDisplayColor(colorVal, c.alphaAt(x, y))
+			row[x*3] = byte(scaleColorValue(colorVal.Red, maxColorValue))
+			row[x*3+1] = byte(scaleColorValue(colorVal.Green, maxColorValue))
+			row[x*3+2] = byte(scaleColorValue(colorVal.Blue, maxColorValue))
+		}
+
+		if _, err := w.Write(row[:len(pixels)*3]); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+// ReadPPM reads a canvas from the passed reader, which holds an image in either the plain
+// (P3) or binary (P6) portable pixmap (PPM) format. The image may have comments and any
+// maximum color value up to 65535, and its color values are scaled to the range [0, 1].
+func ReadPPM(reader io.Reader) (*Canvas, error) {
+	r := &ppmReader{r: bufio.NewReader(reader)}
+
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(r.r, magic); err != nil {
+		return nil, fmt.Errorf("failed to read PPM identifier: %v", err)
+	}
+	id := string(magic)
+	if id != ppmID && id != ppmBinaryID {
+		return nil, fmt.Errorf("unsupported PPM identifier %q", id)
+	}
+
+	width, err := r.readHeaderValue("width")
+	if err != nil {
+		return nil, err
+	}
+	height, err := r.readHeaderValue("height")
+	if err != nil {
+		return nil, err
+	}
+	if width > 0 && height > maxPPMPixels/width {
+		return nil, fmt.Errorf("PPM image of %dx%d pixels is too large", width, height)
+	}
+	maxValue, err := r.readHeaderValue("maximum color value")
+	if err != nil {
+		return nil, err
+	}
+	if maxValue < 1 || maxValue > maxPPMColorValue {
+		return nil, fmt.Errorf("maximum color value %d must be between 1 and %d",
+			maxValue, maxPPMColorValue)
+	}
+
+	c := NewCanvas(width, height)
+	if id == ppmBinaryID {
+		err = r.readBinaryPixels(c, maxValue)
+	} else {
+		err = r.readPlainPixels(c, maxValue)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// ppmReader reads the values of a portable pixmap (PPM) file.
+type ppmReader struct {
+	r *bufio.Reader
+}
+
+// readHeaderValue reads the passed, non-negative value of the header of a PPM file.
+func (p *ppmReader) readHeaderValue(name string) (int, error) {
+	value, err := p.readInt()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read PPM %s: %v", name, err)
+	}
+
+	return value, nil
+}
+
+// readInt reads the next decimal integer, skipping the whitespace and comments before it.
+func (p *ppmReader) readInt() (int, error) {
+	err := p.skipSpaceAndComments()
+	if err != nil {
+		return 0, err
+	}
+
+	value := 0
+	digits := 0
+	for {
+		b, err := p.r.ReadByte()
+		if err == io.EOF && digits > 0 {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if b < '0' || b > '9' {
+			if digits == 0 {
+				return 0, fmt.Errorf("unexpected character %q", b)
+			}
+			if !isPPMSpace(b) && b != '#' {
+				return 0, fmt.Errorf("unexpected character %q", b)
+			}
+			// Leave the byte after the integer to be read next
+			if err := p.r.UnreadByte(); err != nil {
+				return 0, err
+			}
+			break
+		}
+
+		value = value*10 + int(b-'0')
+		digits++
+		if value > 1<<30 {
+			return 0, errors.New("value is too large")
+		}
+	}
+
+	return value, nil
+}
+
+// skipSpaceAndComments skips whitespace and comments, which start
+// with a '#' and continue to the end of the line.
+func (p *ppmReader) skipSpaceAndComments() error {
+	for {
+		b, err := p.r.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		if b == '#' {
+			if _, err := p.r.ReadString('\n'); err != nil && err != io.EOF {
+				return err
+			}
+			continue
+		}
+
+		if !isPPMSpace(b) {
+			return p.r.UnreadByte()
+		}
+	}
+}
+
+// readPlainPixels reads the pixels of a plain PPM file into the passed canvas.
+func (p *ppmReader) readPlainPixels(c *Canvas, maxValue int) error {
+	for y := 0; y < c.Height; y++ {
+		for x := 0; x < c.Width; x++ {
+			var components [3]float64
+			for i := range components {
+				value, err := p.readInt()
+				if err != nil {
+					return fmt.Errorf("failed to read PPM pixel (%d, %d): %v", x, y, err)
+				}
+				if value > maxValue {
+					return fmt.Errorf("color value %d of PPM pixel (%d, %d) is greater than %d",
+						value, x, y, maxValue)
+				}
+				components[i] = float64(value) / float64(maxValue)
+			}
+
+			c.Pixels[y][x] = *color.NewColor(components[0], components[1], components[2])
+		}
+	}
+
+	return nil
+}
+
+// readBinaryPixels reads the pixels of a binary PPM file into the passed canvas. Each color
+// value is a single byte if the maximum color value is less than 256, and two bytes in
+// big-endian order otherwise.
+func (p *ppmReader) readBinaryPixels(c *Canvas, maxValue int) error {
+	// A single whitespace character separates the header from the pixels
+	b, err := p.r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("failed to read PPM pixels: %v", err)
+	}
+	if !isPPMSpace(b) {
+		return fmt.Errorf("unexpected character %q after PPM header", b)
+	}
+
+	bytesPerValue := 1
+	if maxValue > 255 {
+		bytesPerValue = 2
+	}
+
+	row := make([]byte, c.Width*3*bytesPerValue)
+	for y := 0; y < c.Height; y++ {
+		if _, err := io.ReadFull(p.r, row); err != nil {
+			return fmt.Errorf("failed to read PPM pixel row %d: %v", y, err)
+		}
+
+		for x := 0; x < c.Width; x++ {
+			var components [3]float64
+			for i := range components {
+				offset := (x*3 + i) * bytesPerValue
+				value := int(row[offset])
+				if bytesPerValue == 2 {
+					value = value<<8 | int(row[offset+1])
+				}
+				if value > maxValue {
+					return fmt.Errorf("color value %d of PPM pixel (%d, %d) is greater than %d",
+						value, x, y, maxValue)
+				}
+				components[i] = float64(value) / float64(maxValue)
+			}
+
+			c.Pixels[y][x] = *color.NewColor(components[0], components[1], components[2])
+		}
+	}
+
+	return nil
+}
+
+// isPPMSpace returns true if the passed byte is whitespace in a PPM file.
+func isPPMSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\v' || b == '\f'
+}
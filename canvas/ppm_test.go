@@ -0,0 +1,199 @@
+package canvas
+
+import (
+	"bytes"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCanvas_WritePPM(t *testing.T) {
+	tests := []struct {
+		name       string
+		c          *Canvas
+		clr        color.Color
+		wantWriter string
+	}{
+		{
+			name: "lines of a wide canvas are split at 70 characters",
+			c:    NewCanvas(10, 2),
+			clr:  *color.NewColor(1, 0.8, 0.6),
+			wantWriter: "P3\n" +
+				"10 2\n" +
+				"255\n" +
+				"255 204 153 255 204 153 255 204 153 255 204 153 255 204 153 255 204\n" +
+				"153 255 204 153 255 204 153 255 204 153 255 204 153\n" +
+				"255 204 153 255 204 153 255 204 153 255 204 153 255 204 153 255 204\n" +
+				"153 255 204 153 255 204 153 255 204 153 255 204 153\n",
+		},
+		{
+			name: "color values are clamped and rounded",
+			c:    NewCanvas(2, 1),
+			clr:  *color.NewColor(1.5, 0.5, -0.5),
+			wantWriter: "P3\n" +
+				"2 1\n" +
+				"255\n" +
+				"255 128 0 255 128 0\n",
+		},
+		{
+			name: "empty canvas",
+			c:    NewCanvas(0, 0),
+			wantWriter: "P3\n" +
+				"0 0\n" +
+				"255\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for y := 0; y < tt.c.Height; y++ {
+				for x := 0; x < tt.c.Width; x++ {
+					assert.NoError(t, tt.c.WritePixel(x, y, tt.clr))
+				}
+			}
+
+			writer := &bytes.Buffer{}
+			assert.NoError(t, tt.c.WritePPM(writer))
+			assert.Equal(t, tt.wantWriter, writer.String())
+			for _, line := range strings.Split(writer.String(), "\n") {
+				assert.True(t, len(line) <= 70)
+			}
+		})
+	}
+
+	assert.Error(t, NewCanvas(1, 1).WritePPM(nil))
+}
+
+func TestCanvas_WritePPMBinary(t *testing.T) {
+	c := NewCanvas(2, 2)
+	assert.NoError(t, c.WritePixel(0, 0, *color.NewColor(1, 0.5, 0)))
+	assert.NoError(t, c.WritePixel(1, 1, *color.NewColor(0.2, 0.4, 2)))
+
+	writer := &bytes.Buffer{}
+	assert.NoError(t, c.WritePPMBinary(writer))
+	assert.Equal(t, "P6\n2 2\n255\n"+
+		"\xff\x80\x00\x00\x00\x00"+
+		"\x00\x00\x00\x33\x66\xff", writer.String())
+
+	assert.Error(t, c.WritePPMBinary(nil))
+}
+
+func TestReadPPM(t *testing.T) {
+	tests := []struct {
+		name    string
+		ppm     string
+		want    [][]color.Color
+		wantErr bool
+	}{
+		{
+			name: "plain PPM with comments and irregular whitespace",
+			ppm: "P3\n# a comment\n2 1 # width and height\n255\n" +
+				"255 0\t51\n\n  102 # the second pixel\n 204 255",
+			want: [][]color.Color{
+				{*color.NewColor(1, 0, 0.2), *color.NewColor(0.4, 0.8, 1)},
+			},
+		},
+		{
+			name: "plain PPM with a maximum color value other than 255",
+			ppm:  "P3\n1 2\n100\n100 50 0\n25 75 10\n",
+			want: [][]color.Color{
+				{*color.NewColor(1, 0.5, 0)},
+				{*color.NewColor(0.25, 0.75, 0.1)},
+			},
+		},
+		{
+			name: "binary PPM",
+			ppm:  "P6\n# a comment\n2 1\n255\n\xff\x00\x33\x66\xcc\xff",
+			want: [][]color.Color{
+				{*color.NewColor(1, 0, 0.2), *color.NewColor(0.4, 0.8, 1)},
+			},
+		},
+		{
+			name: "binary PPM with two bytes per color value",
+			ppm:  "P6 1 1 1000 \x03\xe8\x01\xf4\x00\x00",
+			want: [][]color.Color{
+				{*color.NewColor(1, 0.5, 0)},
+			},
+		},
+		{
+			name:    "unsupported identifier",
+			ppm:     "P5\n1 1\n255\n\x00",
+			wantErr: true,
+		},
+		{
+			name:    "missing header value",
+			ppm:     "P3\n1\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid maximum color value",
+			ppm:     "P3\n1 1\n70000\n0 0 0\n",
+			wantErr: true,
+		},
+		{
+			name:    "color value greater than the maximum color value",
+			ppm:     "P3\n1 1\n100\n0 101 0\n",
+			wantErr: true,
+		},
+		{
+			name:    "plain PPM with too few pixels",
+			ppm:     "P3\n2 1\n255\n0 0 0\n",
+			wantErr: true,
+		},
+		{
+			name:    "binary PPM with too few pixels",
+			ppm:     "P6\n2 1\n255\n\x00\x00\x00",
+			wantErr: true,
+		},
+		{
+			name:    "plain PPM with a value that isn't a number",
+			ppm:     "P3\n1 1\n255\n0 x 0\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ReadPPM(strings.NewReader(tt.ppm))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, len(tt.want), c.Height)
+			for y, row := range tt.want {
+				assert.Equal(t, len(row), c.Width)
+				for x, want := range row {
+					assert.True(t, color.Equals(want, c.Pixels[y][x]))
+				}
+			}
+		})
+	}
+}
+
+func TestReadPPM_RoundTrip(t *testing.T) {
+	c := NewCanvas(7, 3)
+	for y := 0; y < c.Height; y++ {
+		for x := 0; x < c.Width; x++ {
+			assert.NoError(t, c.WritePixel(x, y,
+				*color.NewColor(float64(x)/6, float64(y)/2, float64(x*y)/12)))
+		}
+	}
+
+	for _, write := range []func(*Canvas, *bytes.Buffer) error{
+		func(c *Canvas, b *bytes.Buffer) error { return c.WritePPM(b) },
+		func(c *Canvas, b *bytes.Buffer) error { return c.WritePPMBinary(b) },
+		func(c *Canvas, b *bytes.Buffer) error { return c.ToPPM(b, PixelMapTemplate) },
+	} {
+		written := &bytes.Buffer{}
+		assert.NoError(t, write(c, written))
+
+		read, err := ReadPPM(bytes.NewReader(written.Bytes()))
+		assert.NoError(t, err)
+
+		// Reading the file and writing it again produces the same file
+		rewritten := &bytes.Buffer{}
+		assert.NoError(t, write(read, rewritten))
+		assert.Equal(t, written.String(), rewritten.String())
+	}
+}
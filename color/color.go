@@ -94,3 +94,10 @@ func Equals(c1, c2 Color) bool {
 		maths.Float64Equals(c1.Green, c2.Green, maths.Epsilon) &&
 		maths.Float64Equals(c1.Blue, c2.Blue, maths.Epsilon)
 }
+
+// Luminance returns the relative luminance of the passed Color, which is
+// a weighted sum of its rgb values that approximates perceived brightness.
+// The weights are those of the Rec. 709 primaries.
+func Luminance(c Color) float64 {
+	return 0.2126*c.Red + 0.7152*c.Green + 0.0722*c.Blue
+}
@@ -415,3 +415,32 @@ func TestNotEquals(t *testing.T) {
 		})
 	}
 }
+
+func TestLuminance(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Color
+		want float64
+	}{
+		{
+			name: "luminance of black",
+			c:    *NewColor(0, 0, 0),
+			want: 0,
+		},
+		{
+			name: "luminance of white",
+			c:    *NewColor(1, 1, 1),
+			want: 1,
+		},
+		{
+			name: "luminance of green",
+			c:    *NewColor(0, 1, 0),
+			want: 0.7152,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, Luminance(tt.c), 0.00001)
+		})
+	}
+}
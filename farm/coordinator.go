@@ -0,0 +1,249 @@
+package farm
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"github.com/austingebauer/go-ray-tracer/camera"
+	"github.com/austingebauer/go-ray-tracer/canvas"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTileTimeout is the time that a coordinator waits for a worker to render a tile.
+	DefaultTileTimeout = time.Minute
+
+	// DefaultMaxAttempts is the number of times that a coordinator attempts to render a tile
+	// on a worker, and the number of workers that a tile can fail on.
+	DefaultMaxAttempts = 3
+)
+
+// Coordinator renders scenes by handing their tiles to workers over HTTP.
+type Coordinator struct {
+	// The base URLs of the workers, such as http://localhost:8080
+	workers []string
+	// The client used to send requests to the workers
+	client *http.Client
+	// The number of tiles that are sent to each worker at a time
+	requestsPerWorker int
+	// The time that the coordinator waits for a worker to render a tile
+	tileTimeout time.Duration
+	// The number of times that a tile is attempted on a worker before the worker is
+	// retired, and the number of workers that a tile fails on before the render fails
+	maxAttempts int
+}
+
+// NewCoordinator returns a new coordinator that hands tiles to the workers at the
+// passed base URLs. By default, each worker is sent one tile at a time, and tiles
+// are attempted DefaultMaxAttempts times, waiting DefaultTileTimeout for each.
+func NewCoordinator(workers ...string) *Coordinator {
+	return &Coordinator{
+		workers:           workers,
+		client:            http.DefaultClient,
+		requestsPerWorker: 1,
+		tileTimeout:       DefaultTileTimeout,
+		maxAttempts:       DefaultMaxAttempts,
+	}
+}
+
+// SetRequestsPerWorker sets the number of tiles that are sent to each worker at a time,
+// which is usually the number of processors of the machines that the workers run on.
+func (co *Coordinator) SetRequestsPerWorker(requests int) error {
+	if requests < 1 {
+		return errors.New("requests per worker must be at least 1")
+	}
+
+	co.requestsPerWorker = requests
+	return nil
+}
+
+// SetTileTimeout sets the time that the coordinator waits for a worker to render a tile.
+func (co *Coordinator) SetTileTimeout(timeout time.Duration) error {
+	if timeout <= 0 {
+		return errors.New("tile timeout must be greater than zero")
+	}
+
+	co.tileTimeout = timeout
+	return nil
+}
+
+// SetMaxAttempts sets the number of times that a tile is attempted on a worker before the
+// worker is retired, which is also the number of workers that a tile can fail on before
+// the render fails.
+func (co *Coordinator) SetMaxAttempts(attempts int) error {
+	if attempts < 1 {
+		return errors.New("max attempts must be at least 1")
+	}
+
+	co.maxAttempts = attempts
+	return nil
+}
+
+// tileOutcome is the film of a tile rendered by a worker, or the error that it failed with.
+type tileOutcome struct {
+	tile camera.Tile
+	film *camera.TileFilm
+	err  error
+}
+
+// Render renders the passed scene, which the workers know by the passed name, and assembles
+// the tiles rendered by the workers into a canvas that is the same as the canvas rendered
+// by camera.Render. The coordinator loads the scene itself to divide its image into tiles.
+//
+// A tile that a worker fails to render or doesn't render before the tile timeout is attempted
+// again on the same worker, up to the maximum number of attempts. A worker that fails each
+// attempt of a tile is retired and sent no more tiles, and the tile is handed to another
+// worker. The render fails if a tile fails on the maximum number of attempts of workers, or
+// if every worker has been retired.
+func (co *Coordinator) Render(ctx context.Context, name string, scene Scene) (*canvas.Canvas, error) {
+	if len(co.workers) == 0 {
+		return nil, errors.New("coordinator has no workers")
+	}
+
+	c, _, err := scene()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Queue every tile, leaving room for every tile to be re-queued
+	tiles := camera.Tiles(c)
+	queue := make(chan camera.Tile, len(tiles))
+	for _, t := range tiles {
+		queue <- t
+	}
+
+	// Each worker takes tiles from the queue until it fails a tile on each attempt. A worker
+	// that fails is retired, which stops each of its requests from taking more tiles.
+	outcomes := make(chan tileOutcome)
+	var wg sync.WaitGroup
+	for _, url := range co.workers {
+		retired, retire := context.WithCancel(ctx)
+		defer retire()
+
+		for i := 0; i < co.requestsPerWorker; i++ {
+			wg.Add(1)
+			go func(url string) {
+				defer wg.Done()
+				co.work(ctx, retired, retire, url, name, queue, outcomes)
+			}(url)
+		}
+	}
+
+	workersDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(workersDone)
+	}()
+
+	failures := make(map[int]int)
+	var lastErr error
+	films := make([]*camera.TileFilm, 0, len(tiles))
+	for len(films) < len(tiles) {
+		select {
+		case outcome := <-outcomes:
+			if outcome.err == nil {
+				films = append(films, outcome.film)
+				continue
+			}
+
+			lastErr = outcome.err
+			failures[outcome.tile.Index]++
+			if failures[outcome.tile.Index] >= co.maxAttempts {
+				return nil, fmt.Errorf("tile %d failed on %d workers: %v",
+					outcome.tile.Index, co.maxAttempts, outcome.err)
+			}
+			queue <- outcome.tile
+		case <-workersDone:
+			return nil, fmt.Errorf("every worker failed: %v", lastErr)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return camera.AssembleTiles(c, films)
+}
+
+// work sends tiles from the passed queue to the worker at the passed URL and reports their
+// outcomes until the context is cancelled or the worker is retired. A tile that the worker
+// fails to render is attempted again, and if the worker fails each attempt of the tile, then
+// work reports the failure and calls the passed retire function.
+func (co *Coordinator) work(ctx, retired context.Context, retire context.CancelFunc,
+	url, name string, queue <-chan camera.Tile, outcomes chan<- tileOutcome) {
+	for {
+		var t camera.Tile
+		select {
+		case t = <-queue:
+		case <-retired.Done():
+			return
+		}
+
+		tf, err := co.renderTile(ctx, url, name, t)
+		for attempt := 1; err != nil && attempt < co.maxAttempts && ctx.Err() == nil; attempt++ {
+			tf, err = co.renderTile(ctx, url, name, t)
+		}
+
+		select {
+		case outcomes <- tileOutcome{tile: t, film: tf, err: err}:
+		case <-ctx.Done():
+			return
+		}
+
+		if err != nil {
+			retire()
+			return
+		}
+	}
+}
+
+// renderTile asks the worker at the passed URL to render the passed tile of the scene with
+// the passed name, and returns the film of the tile.
+func (co *Coordinator) renderTile(ctx context.Context, url, name string,
+	t camera.Tile) (*camera.TileFilm, error) {
+	ctx, cancel := context.WithTimeout(ctx, co.tileTimeout)
+	defer cancel()
+
+	var body bytes.Buffer
+	err := gob.NewEncoder(&body).Encode(tileRequest{Scene: name, Tile: t})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(url, "/")+TilePath, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := co.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("worker %s responded with %s: %s", url, resp.Status,
+			strings.TrimSpace(string(message)))
+	}
+
+	tf := &camera.TileFilm{}
+	err = gob.NewDecoder(resp.Body).Decode(tf)
+	if err != nil {
+		return nil, err
+	}
+	if tf.Tile != t {
+		return nil, fmt.Errorf("worker %s rendered tile %d instead of tile %d",
+			url, tf.Tile.Index, t.Index)
+	}
+
+	return tf, nil
+}
@@ -0,0 +1,26 @@
+// Package farm provides distributed rendering, where a coordinator splits the image of a scene
+// into tiles and hands them to worker processes over HTTP, which render them and send them back.
+package farm
+
+import (
+	"github.com/austingebauer/go-ray-tracer/camera"
+	"github.com/austingebauer/go-ray-tracer/world"
+)
+
+const (
+	// TilePath is the path of the HTTP endpoint that workers render tiles at.
+	TilePath = "/tile"
+)
+
+// Scene returns the camera and world of a scene. The coordinator and every worker
+// load a scene by calling its Scene function, which must return the same camera
+// and world each time it is called.
+type Scene func() (*camera.Camera, *world.World, error)
+
+// tileRequest is the body of a request to render a tile of a scene.
+type tileRequest struct {
+	// The name of the scene
+	Scene string
+	// The tile of the scene's image to render
+	Tile camera.Tile
+}
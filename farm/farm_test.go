@@ -0,0 +1,326 @@
+package farm
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"github.com/austingebauer/go-ray-tracer/camera"
+	"github.com/austingebauer/go-ray-tracer/matrix"
+	"github.com/austingebauer/go-ray-tracer/point"
+	"github.com/austingebauer/go-ray-tracer/vector"
+	"github.com/austingebauer/go-ray-tracer/world"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testScene returns the camera and world of a small scene for testing.
+func testScene() (*camera.Camera, *world.World, error) {
+	c, err := camera.NewCameraWithTransform(30, 20, math.Pi/2,
+		matrix.ViewTransformMatrix4(
+			*point.NewPoint(0, 0, -5),
+			*point.NewPoint(0, 0, 0),
+			*vector.NewVector(0, 1, 0)))
+	if err != nil {
+		return nil, nil, err
+	}
+	err = c.SetAntiAliasing(4, camera.JitteredSampling, camera.NewTentFilter(1.5))
+	if err != nil {
+		return nil, nil, err
+	}
+	err = c.SetTiles(8, camera.SpiralTileOrder)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return c, world.NewDefaultWorld(), nil
+}
+
+// startWorkers starts a test server for each of the passed handlers and returns their URLs.
+func startWorkers(t *testing.T, handlers ...http.Handler) ([]string, func()) {
+	var urls []string
+	var servers []*httptest.Server
+	for _, h := range handlers {
+		server := httptest.NewServer(h)
+		servers = append(servers, server)
+		urls = append(urls, server.URL)
+	}
+
+	return urls, func() {
+		for _, server := range servers {
+			server.Close()
+		}
+	}
+}
+
+// failingWorker responds to every request with an internal server error.
+var failingWorker = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "out of memory", http.StatusInternalServerError)
+})
+
+// flakyWorker responds to the first passed number of requests with an internal server
+// error, and passes every request after them to the passed handler.
+func flakyWorker(failures int, h http.Handler) http.Handler {
+	var mu sync.Mutex
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fail := failures > 0
+		failures--
+		mu.Unlock()
+
+		if fail {
+			failingWorker(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// hangingWorker doesn't respond to a request until the request is cancelled. The body is
+// read first, so that the server notices when the client cancels the request.
+var hangingWorker = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	io.Copy(ioutil.Discard, r.Body)
+	select {
+	case <-r.Context().Done():
+	case <-time.After(10 * time.Second):
+	}
+})
+
+func TestCoordinator_Render(t *testing.T) {
+	scenes := map[string]Scene{"test": testScene}
+
+	c, w, err := testScene()
+	assert.NoError(t, err)
+	want, err := camera.Render(c, w)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		handlers []http.Handler
+	}{
+		{
+			name:     "a single worker",
+			handlers: []http.Handler{NewWorker(scenes)},
+		},
+		{
+			name:     "multiple workers",
+			handlers: []http.Handler{NewWorker(scenes), NewWorker(scenes), NewWorker(scenes)},
+		},
+		{
+			name:     "a worker that fails",
+			handlers: []http.Handler{failingWorker, NewWorker(scenes), failingWorker},
+		},
+		{
+			name:     "a single worker that fails and then recovers",
+			handlers: []http.Handler{flakyWorker(2, NewWorker(scenes))},
+		},
+		{
+			name:     "a worker that times out",
+			handlers: []http.Handler{hangingWorker, NewWorker(scenes)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urls, stop := startWorkers(t, tt.handlers...)
+			defer stop()
+
+			co := NewCoordinator(urls...)
+			assert.NoError(t, co.SetRequestsPerWorker(2))
+			assert.NoError(t, co.SetTileTimeout(500*time.Millisecond))
+
+			got, err := co.Render(context.Background(), "test", testScene)
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestCoordinator_RenderFails(t *testing.T) {
+	scenes := map[string]Scene{"test": testScene}
+
+	t.Run("every worker fails", func(t *testing.T) {
+		urls, stop := startWorkers(t, failingWorker, hangingWorker)
+		defer stop()
+
+		co := NewCoordinator(urls...)
+		assert.NoError(t, co.SetTileTimeout(50*time.Millisecond))
+		image, err := co.Render(context.Background(), "test", testScene)
+		assert.Error(t, err)
+		assert.Nil(t, image)
+	})
+
+	t.Run("a tile fails too many times", func(t *testing.T) {
+		urls, stop := startWorkers(t, failingWorker, failingWorker, NewWorker(scenes))
+		defer stop()
+
+		co := NewCoordinator(urls...)
+		assert.NoError(t, co.SetMaxAttempts(1))
+		image, err := co.Render(context.Background(), "test", testScene)
+		assert.Error(t, err)
+		assert.True(t, strings.Contains(err.Error(), "failed on 1 workers"))
+		assert.Nil(t, image)
+	})
+
+	t.Run("workers don't know the scene", func(t *testing.T) {
+		urls, stop := startWorkers(t, NewWorker(scenes))
+		defer stop()
+
+		image, err := NewCoordinator(urls...).Render(context.Background(), "unknown", testScene)
+		assert.Error(t, err)
+		assert.Nil(t, image)
+	})
+
+	t.Run("the scene fails to load", func(t *testing.T) {
+		urls, stop := startWorkers(t, NewWorker(scenes))
+		defer stop()
+
+		loadErr := errors.New("missing texture")
+		image, err := NewCoordinator(urls...).Render(context.Background(), "test",
+			func() (*camera.Camera, *world.World, error) {
+				return nil, nil, loadErr
+			})
+		assert.Equal(t, loadErr, err)
+		assert.Nil(t, image)
+	})
+
+	t.Run("the context is cancelled", func(t *testing.T) {
+		urls, stop := startWorkers(t, hangingWorker)
+		defer stop()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		image, err := NewCoordinator(urls...).Render(ctx, "test", testScene)
+		assert.Equal(t, context.DeadlineExceeded, err)
+		assert.Nil(t, image)
+	})
+
+	t.Run("no workers", func(t *testing.T) {
+		image, err := NewCoordinator().Render(context.Background(), "test", testScene)
+		assert.Error(t, err)
+		assert.Nil(t, image)
+	})
+}
+
+func TestWorker_ServeHTTP(t *testing.T) {
+	loads := 0
+	worker := NewWorker(map[string]Scene{
+		"test": func() (*camera.Camera, *world.World, error) {
+			loads++
+			return testScene()
+		},
+		"broken": func() (*camera.Camera, *world.World, error) {
+			return nil, nil, errors.New("missing texture")
+		},
+	})
+
+	encode := func(req tileRequest) *bytes.Buffer {
+		var body bytes.Buffer
+		assert.NoError(t, gob.NewEncoder(&body).Encode(req))
+		return &body
+	}
+	tile := camera.Tile{Index: 0, X0: 0, Y0: 0, X1: 8, Y1: 8}
+
+	// Requests survive encoding
+	req := tileRequest{Scene: "test", Tile: tile}
+	decoded := tileRequest{}
+	assert.NoError(t, gob.NewDecoder(encode(req)).Decode(&decoded))
+	assert.Equal(t, req, decoded)
+
+	// The film of a tile is the same as the film rendered by RenderTile
+	c, wld, err := testScene()
+	assert.NoError(t, err)
+	want, err := camera.RenderTile(context.Background(), c, wld, tile)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       *bytes.Buffer
+		wantStatus int
+	}{
+		{
+			name:       "render a tile",
+			method:     http.MethodPost,
+			path:       TilePath,
+			body:       encode(tileRequest{Scene: "test", Tile: tile}),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "render another tile of a loaded scene",
+			method:     http.MethodPost,
+			path:       TilePath,
+			body:       encode(tileRequest{Scene: "test", Tile: camera.Tile{Index: 1, X0: 8, X1: 16, Y1: 8}}),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "render a tile that isn't in the image",
+			method:     http.MethodPost,
+			path:       TilePath,
+			body:       encode(tileRequest{Scene: "test", Tile: camera.Tile{Index: 99}}),
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "render a tile of an unknown scene",
+			method:     http.MethodPost,
+			path:       TilePath,
+			body:       encode(tileRequest{Scene: "unknown", Tile: tile}),
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "render a tile of a scene that fails to load",
+			method:     http.MethodPost,
+			path:       TilePath,
+			body:       encode(tileRequest{Scene: "broken", Tile: tile}),
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "invalid request body",
+			method:     http.MethodPost,
+			path:       TilePath,
+			body:       bytes.NewBufferString("not a tile request"),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "wrong method",
+			method:     http.MethodGet,
+			path:       TilePath,
+			body:       &bytes.Buffer{},
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "wrong path",
+			method:     http.MethodPost,
+			path:       "/tiles",
+			body:       encode(tileRequest{Scene: "test", Tile: tile}),
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			worker.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, tt.body))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+
+			if tt.wantStatus == http.StatusOK {
+				tf := &camera.TileFilm{}
+				assert.NoError(t, gob.NewDecoder(rec.Body).Decode(tf))
+				assert.True(t, tf.Rays > 0)
+				if tf.Tile == want.Tile {
+					assert.Equal(t, want, tf)
+				}
+			}
+		})
+	}
+
+	// The scene is only loaded once
+	assert.Equal(t, 1, loads)
+}
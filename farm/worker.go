@@ -0,0 +1,105 @@
+package farm
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"github.com/austingebauer/go-ray-tracer/camera"
+	"github.com/austingebauer/go-ray-tracer/world"
+	"net/http"
+	"sync"
+)
+
+// Worker is an http.Handler that renders tiles of the scenes that it knows of.
+type Worker struct {
+	// The scenes that the worker can render, by name
+	scenes map[string]Scene
+
+	// The scenes that have been loaded, by name
+	mu     sync.Mutex
+	loaded map[string]*loadedScene
+}
+
+// loadedScene is a scene whose camera and world have been loaded.
+type loadedScene struct {
+	once   sync.Once
+	camera *camera.Camera
+	world  *world.World
+	err    error
+}
+
+// NewWorker returns a new worker that renders tiles of the passed scenes, which
+// are keyed by name. Each scene is loaded the first time that it is rendered.
+func NewWorker(scenes map[string]Scene) *Worker {
+	return &Worker{
+		scenes: scenes,
+		loaded: make(map[string]*loadedScene),
+	}
+}
+
+// ServeHTTP renders the tile requested by a POST to TilePath,
+// and responds with the film of the rendered tile.
+func (wk *Worker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != TilePath {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req tileRequest
+	err := gob.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid tile request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	scene, ok := wk.scenes[req.Scene]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown scene %q", req.Scene), http.StatusNotFound)
+		return
+	}
+
+	c, wld, err := wk.load(req.Scene, scene)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tf, err := camera.RenderTile(r.Context(), c, wld, req.Tile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Encode the film before writing it, so that a failure isn't sent as a truncated film
+	var body bytes.Buffer
+	err = gob.NewEncoder(&body).Encode(tf)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = body.WriteTo(w)
+}
+
+// load returns the camera and world of the passed scene with the passed
+// name, loading the scene if it hasn't been loaded yet.
+func (wk *Worker) load(name string, scene Scene) (*camera.Camera, *world.World, error) {
+	wk.mu.Lock()
+	ls, ok := wk.loaded[name]
+	if !ok {
+		ls = &loadedScene{}
+		wk.loaded[name] = ls
+	}
+	wk.mu.Unlock()
+
+	ls.once.Do(func() {
+		ls.camera, ls.world, ls.err = scene()
+	})
+	return ls.camera, ls.world, ls.err
+}
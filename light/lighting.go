@@ -9,10 +9,33 @@ import (
 	"math"
 )
 
+// Shading holds the contributions of the Phong reflection model to the shading of a point.
+type Shading struct {
+	// Ambient is the light reflected from the surroundings, which stands in for indirect light
+	Ambient color.Color
+	// Diffuse is the light from the light source reflected equally in every direction
+	Diffuse color.Color
+	// Specular is the light from the light source reflected toward the eye
+	Specular color.Color
+}
+
+// Total returns the sum of the contributions to the shading, which is the shaded color.
+func (s Shading) Total() color.Color {
+	return *color.Add(*color.Add(s.Ambient, s.Diffuse), s.Specular)
+}
+
 // Lighting computes the shading for a material given the light source, point being illuminated,
 // eye and normal vectors, and shadow flag using the Phong reflection model.
 func Lighting(mat *material.Material, light *PointLight, pt *point.Point, eyeVec,
 	normalVec *vector.Vector, inShadow bool) *color.Color {
+	total := LightingComponents(mat, light, pt, eyeVec, normalVec, inShadow).Total()
+	return &total
+}
+
+// LightingComponents computes the shading for a material in the same way as Lighting,
+// but returns the ambient, diffuse, and specular contributions to the shading separately.
+func LightingComponents(mat *material.Material, light *PointLight, pt *point.Point, eyeVec,
+	normalVec *vector.Vector, inShadow bool) Shading {
 	// The three reflection contributions to get the
 	// final shading are ambient, diffuse, and specular.
 
@@ -20,11 +43,11 @@ func Lighting(mat *material.Material, light *PointLight, pt *point.Point, eyeVec
 	effectiveColor := color.Multiply(mat.Color, light.Intensity)
 
 	// Compute the ambient contribution
-	ambient := color.Scale(*effectiveColor, mat.Ambient)
+	shading := Shading{Ambient: *color.Scale(*effectiveColor, mat.Ambient)}
 
 	// If the point is in a shadow, use only the ambient contribution.
 	if inShadow {
-		return ambient
+		return shading
 	}
 
 	// Get the vector from the point to the light source
@@ -37,26 +60,23 @@ func Lighting(mat *material.Material, light *PointLight, pt *point.Point, eyeVec
 	// A negative number means the light is on the other side of the surface
 	// and only ambient light is present
 	if lightDotNormal < 0 {
-		return ambient
+		return shading
 	}
 
 	// Compute the diffuse contribution
-	diffuse := color.Scale(*color.Scale(*effectiveColor, mat.Diffuse), lightDotNormal)
+	shading.Diffuse = *color.Scale(*color.Scale(*effectiveColor, mat.Diffuse), lightDotNormal)
 
 	// reflectDotEye represents the cosine of the angle between the
 	// reflection vector and the eye vector.
 	reflectVec := vector.Reflect(*vector.Scale(*lightVec, -1), *normalVec)
 	reflectDotEye := vector.DotProduct(*reflectVec, *eyeVec)
 
-	// Compute the specular contribution
-	specular := color.NewColor(0, 0, 0)
-
 	// A positive number means that the light reflects into the eye.
 	// A zero or negative number means the light reflects away from (not into) the eye.
 	if reflectDotEye > 0 {
 		factor := math.Pow(reflectDotEye, mat.Shininess)
-		specular = color.Scale(*color.Scale(light.Intensity, mat.Specular), factor)
+		shading.Specular = *color.Scale(*color.Scale(light.Intensity, mat.Specular), factor)
 	}
 
-	return color.Add(*color.Add(*ambient, *diffuse), *specular)
+	return shading
 }
@@ -162,3 +162,25 @@ func TestLighting(t *testing.T) {
 		})
 	}
 }
+
+func TestLightingComponents(t *testing.T) {
+	l := NewPointLight(*point.NewPoint(0, 0, -10), *color.NewColor(1, 1, 1))
+	m := material.NewDefaultMaterial()
+	eyeVec := vector.NewVector(0, 0, -1)
+	normalVec := vector.NewVector(0, 0, -1)
+	pt := point.NewPoint(0, 0, 0)
+
+	// With the eye between the light and the surface, each contribution is at its maximum
+	shading := LightingComponents(m, l, pt, eyeVec, normalVec, false)
+	assert.True(t, color.Equals(*color.NewColor(0.1, 0.1, 0.1), shading.Ambient))
+	assert.True(t, color.Equals(*color.NewColor(0.9, 0.9, 0.9), shading.Diffuse))
+	assert.True(t, color.Equals(*color.NewColor(0.9, 0.9, 0.9), shading.Specular))
+	assert.True(t, color.Equals(*Lighting(m, l, pt, eyeVec, normalVec, false), shading.Total()))
+
+	// A point in shadow only has the ambient contribution
+	shading = LightingComponents(m, l, pt, eyeVec, normalVec, true)
+	assert.True(t, color.Equals(*color.NewColor(0.1, 0.1, 0.1), shading.Ambient))
+	assert.Equal(t, color.Color{}, shading.Diffuse)
+	assert.Equal(t, color.Color{}, shading.Specular)
+	assert.True(t, color.Equals(*Lighting(m, l, pt, eyeVec, normalVec, true), shading.Total()))
+}
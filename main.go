@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/austingebauer/go-ray-tracer/camera"
 	"github.com/austingebauer/go-ray-tracer/canvas"
 	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/austingebauer/go-ray-tracer/farm"
 	"github.com/austingebauer/go-ray-tracer/light"
 	"github.com/austingebauer/go-ray-tracer/material"
 	"github.com/austingebauer/go-ray-tracer/matrix"
@@ -13,23 +17,69 @@ import (
 	"github.com/austingebauer/go-ray-tracer/sphere"
 	"github.com/austingebauer/go-ray-tracer/vector"
 	"github.com/austingebauer/go-ray-tracer/world"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
 	"log"
 	"math"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
 
 type rendering struct {
-	routine    func() *canvas.Canvas
+	routine func() *canvas.Canvas
+	// The routine that also renders AOVs, or nil if the rendering has no AOVs
+	aovRoutine func(aovs ...string) (*canvas.Canvas, map[string]*canvas.Canvas)
 	outputFile string
+	// The output transform of the rendering, or nil to clamp its colors
+	outputTransform *canvas.OutputTransform
+}
+
+// scenes are the scenes that workers render tiles of and coordinators render, by name.
+var scenes = map[string]farm.Scene{
+	"world_shadow_3d": worldShadowScene,
 }
 
 func main() {
+	// The denoise, worker, and coordinator commands run instead of the renderings
+	if len(os.Args) > 1 {
+		commands := map[string]func([]string) error{
+			"denoise":     runDenoise,
+			"worker":      runWorker,
+			"coordinator": runCoordinator,
+		}
+		if command, ok := commands[os.Args[1]]; ok {
+			err := command(os.Args[2:])
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	// The AOVs, such as the features used by the denoise command, are written to EXR files
+	// next to the renderings that have them, which are named after the rendering and AOV.
+	// The renderings are then also written to EXR files with linear colors to denoise.
+	aovs := flag.String("aovs", "",
+		"a comma-separated list of the AOVs to write alongside renderings, such as "+
+			"albedo,normal,depth,variance for the denoise command")
+	flag.Parse()
+	var aovNames []string
+	if *aovs != "" {
+		aovNames = strings.Split(*aovs, ",")
+	}
+
 	renderings := []rendering{
 		{
-			routine:    RenderRayTracedWorld3D,
-			outputFile: "docs/renderings/world_shadow_3d/world_shadow_3d.ppm",
+			routine:         RenderRayTracedWorld3D,
+			aovRoutine:      RenderRayTracedWorld3DWithAOVs,
+			outputFile:      "docs/renderings/world_shadow_3d/world_shadow_3d.png",
+			outputTransform: canvas.NewOutputTransform(),
 		},
 		//{
 		//	routine:    RenderRayTracedSphere3D,
@@ -58,7 +108,33 @@ func main() {
 	for _, r := range renderings {
 		go (func(r rendering) {
 			defer wg.Done()
-			writeCanvasToFile(r.routine(), r.outputFile)
+			var c *canvas.Canvas
+			var aovs map[string]*canvas.Canvas
+			if r.aovRoutine != nil && len(aovNames) > 0 {
+				c, aovs = r.aovRoutine(aovNames...)
+			} else {
+				c = r.routine()
+			}
+			if r.outputTransform != nil {
+				c.OutputTransform = r.outputTransform
+			}
+
+			err := writeCanvasToFile(c, r.outputFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if len(aovs) > 0 && !isHDRFile(r.outputFile) {
+				err = writeCanvasToFile(c, aovFile(r.outputFile, ""))
+				if err != nil {
+					log.Fatal(err)
+				}
+			}
+			for name, aov := range aovs {
+				err = writeCanvasToFile(aov, aovFile(r.outputFile, name))
+				if err != nil {
+					log.Fatal(err)
+				}
+			}
 		})(r)
 	}
 
@@ -71,9 +147,40 @@ func main() {
 
 // RenderRayTracedWorld3D renders a 3D world.
 func RenderRayTracedWorld3D() *canvas.Canvas {
+	can, _ := RenderRayTracedWorld3DWithAOVs()
+	return can
+}
+
+// RenderRayTracedWorld3DWithAOVs renders a 3D world along with the passed AOVs.
+func RenderRayTracedWorld3DWithAOVs(aovs ...string) (*canvas.Canvas, map[string]*canvas.Canvas) {
+	c, w, err := worldShadowScene()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = c.SetAOVs(aovs...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Finally, render the world using the camera to produce an image.
+	can, aovCanvases, err := camera.RenderWithAOVs(c, w)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return can, aovCanvases
+}
+
+// worldShadowScene returns the camera and world of the 3D world with shadows, which is
+// the scene of RenderRayTracedWorld3D.
+func worldShadowScene() (*camera.Camera, *world.World, error) {
 	// The floor is an flattened sphere with a matte texture.
 	floor := sphere.NewUnitSphere("floor")
-	floor.Transform = matrix.NewScalingMatrix(10, 0.01, 10)
+	err := floor.SetTransform(matrix.NewScalingMatrix4(10, 0.01, 10))
+	if err != nil {
+		return nil, nil, err
+	}
 	floor.Material = material.NewDefaultMaterial()
 	floor.Material.Color = *color.NewColor(1, 0.9, 0.9)
 	floor.Material.Specular = 0
@@ -81,28 +188,33 @@ func RenderRayTracedWorld3D() *canvas.Canvas {
 	// The wall to the left has the same scale and color as the floor,
 	// but is also rotated and translated into place.
 	leftWall := sphere.NewUnitSphere("leftWall")
-	leftWall.Transform = matrix.Multiply4x4(
-		matrix.Multiply4x4(matrix.Multiply4x4(
-			matrix.NewTranslationMatrix(0, 0, 5),
-			matrix.NewYRotationMatrix(-1*(math.Pi/4))),
-			matrix.NewXRotationMatrix(math.Pi/2)),
-		matrix.NewScalingMatrix(10, 0.01, 10))
+	err = leftWall.SetTransform(matrix.NewTranslationMatrix4(0, 0, 5).
+		Multiply(matrix.NewYRotationMatrix4(-1 * (math.Pi / 4))).
+		Multiply(matrix.NewXRotationMatrix4(math.Pi / 2)).
+		Multiply(matrix.NewScalingMatrix4(10, 0.01, 10)))
+	if err != nil {
+		return nil, nil, err
+	}
 	leftWall.Material = floor.Material
 
 	// The wall to the right is identical to the left wall,
 	// but is rotated the opposite direction in y.
 	rightWall := sphere.NewUnitSphere("rightWall")
-	rightWall.Transform = matrix.Multiply4x4(
-		matrix.Multiply4x4(matrix.Multiply4x4(
-			matrix.NewTranslationMatrix(0, 0, 5),
-			matrix.NewYRotationMatrix(math.Pi/4)),
-			matrix.NewXRotationMatrix(math.Pi/2)),
-		matrix.NewScalingMatrix(10, 0.01, 10))
+	err = rightWall.SetTransform(matrix.NewTranslationMatrix4(0, 0, 5).
+		Multiply(matrix.NewYRotationMatrix4(math.Pi / 4)).
+		Multiply(matrix.NewXRotationMatrix4(math.Pi / 2)).
+		Multiply(matrix.NewScalingMatrix4(10, 0.01, 10)))
+	if err != nil {
+		return nil, nil, err
+	}
 	rightWall.Material = floor.Material
 
 	// The large sphere in the middle is a unit sphere that's translated upward slightly.
 	middle := sphere.NewUnitSphere("middle")
-	middle.Transform = matrix.NewTranslationMatrix(-0.5, 1, 0.5)
+	err = middle.SetTransform(matrix.NewTranslationMatrix4(-0.5, 1, 0.5))
+	if err != nil {
+		return nil, nil, err
+	}
 	middle.Material = material.NewDefaultMaterial()
 	middle.Material.Color = *color.NewColor(0, 1, 0.8)
 	middle.Material.Diffuse = 0.7
@@ -110,9 +222,11 @@ func RenderRayTracedWorld3D() *canvas.Canvas {
 
 	// The green sphere on the right is scaled in half.
 	right := sphere.NewUnitSphere("right")
-	right.Transform = matrix.Multiply4x4(
-		matrix.NewTranslationMatrix(1.5, 0.5, -0.5),
-		matrix.NewScalingMatrix(0.5, 0.5, 0.5))
+	err = right.SetTransform(matrix.NewTranslationMatrix4(1.5, 0.5, -0.5).
+		Multiply(matrix.NewScalingMatrix4(0.5, 0.5, 0.5)))
+	if err != nil {
+		return nil, nil, err
+	}
 	right.Material = material.NewDefaultMaterial()
 	right.Material.Color = *color.NewColor(0.2, 0.8, 1)
 	right.Material.Diffuse = 0.7
@@ -120,9 +234,11 @@ func RenderRayTracedWorld3D() *canvas.Canvas {
 
 	// The olive sphere on the left is scaled in 1/3.
 	left := sphere.NewUnitSphere("left")
-	left.Transform = matrix.Multiply4x4(
-		matrix.NewTranslationMatrix(-1.5, 0.33, -0.75),
-		matrix.NewScalingMatrix(0.33, 0.33, 0.33))
+	err = left.SetTransform(matrix.NewTranslationMatrix4(-1.5, 0.33, -0.75).
+		Multiply(matrix.NewScalingMatrix4(0.33, 0.33, 0.33)))
+	if err != nil {
+		return nil, nil, err
+	}
 	left.Material = material.NewDefaultMaterial()
 	left.Material.Color = *color.NewColor(0.8, 0.8, 1)
 	left.Material.Diffuse = 0.7
@@ -144,19 +260,16 @@ func RenderRayTracedWorld3D() *canvas.Canvas {
 		*color.NewColor(1, 1, 1))
 
 	// Create a camera and add a transform to the world relative to it.
-	c := camera.NewCameraWithTransform(600, 400, math.Pi/3,
-		matrix.ViewTransform(
+	c, err := camera.NewCameraWithTransform(600, 400, math.Pi/3,
+		matrix.ViewTransformMatrix4(
 			*point.NewPoint(0, 1.5, -5),
 			*point.NewPoint(0, 1, 0),
 			*vector.NewVector(0, 1, 0)))
-
-	// Finally, render the world using the camera to produce an image.
-	can, err := camera.Render(c, w)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, err
 	}
 
-	return can
+	return c, w, nil
 }
 
 // RenderRayTracedSphere3D renders a 3D ray traced sphere.
@@ -227,7 +340,10 @@ func renderSphere(shape *sphere.Sphere, l *light.PointLight, render3D bool) *can
 			r := ray.NewRay(*rayOrigin, *vector.Normalize(*point.Subtract(*position, *rayOrigin)))
 
 			// RaySphereIntersect the ray with the sphere
-			xs := ray.RaySphereIntersect(r, shape)
+			xs, err := ray.RaySphereIntersect(r, shape)
+			if err != nil {
+				log.Fatal(err)
+			}
 
 			// If there was a hit, write a pixel to the canvas
 			hit := ray.Hit(xs)
@@ -398,17 +514,245 @@ func tick(env *Environment, proj *Projectile) Projectile {
 	}
 }
 
-// writeCanvasToFile writes the passed canvas to a file at the passed path.
-func writeCanvasToFile(c *canvas.Canvas, filePath string) {
-	// Write the canvas to a PPM file
+// writeCanvasToFile writes the passed canvas to a file at the passed path. The format
+// of the file is selected by its extension, which may be .png, .jpg, .jpeg, or .ppm, or
+// one of .pfm, .hdr, or .exr for high dynamic range colors that aren't clamped.
+func writeCanvasToFile(c *canvas.Canvas, filePath string) error {
+	var write func(io.Writer) error
+	switch ext := strings.ToLower(filepath.Ext(filePath)); ext {
+	case ".png":
+		write = c.WritePNG
+	case ".jpg", ".jpeg":
+		write = func(w io.Writer) error {
+			return c.WriteJPEG(w, canvas.DefaultJPEGQuality)
+		}
+	case ".ppm":
+		write = c.WritePPMBinary
+	case ".pfm":
+		write = c.WritePFM
+	case ".hdr":
+		write = c.WriteHDR
+	case ".exr":
+		write = func(w io.Writer) error {
+			return c.WriteEXR(w, canvas.EXRHalf, canvas.EXRZIPCompression)
+		}
+	default:
+		return fmt.Errorf("unsupported output file extension %q", ext)
+	}
+
 	file, err := os.Create(filePath)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	err = write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
 	}
-	err = c.ToPPM(file, canvas.PixelMapTemplate)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("failed to write %v: %v", filePath, err)
 	}
 
-	fmt.Printf("Wrote ppm rendering to: %v\n\n", filePath)
+	fmt.Printf("Wrote rendering to: %v\n\n", filePath)
+	return nil
+}
+
+// aovFile returns the path of the EXR file of the AOV with the passed name of the
+// rendering written to the passed path, or of the rendering itself if the name is empty.
+func aovFile(filePath, name string) string {
+	base := strings.TrimSuffix(filePath, filepath.Ext(filePath))
+	if name == "" {
+		return base + ".exr"
+	}
+	return base + "_" + name + ".exr"
+}
+
+// isHDRFile returns true if the file at the passed path holds high dynamic range colors,
+// which are linear, rather than colors with 8 bits per color component.
+func isHDRFile(filePath string) bool {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".pfm", ".hdr", ".exr":
+		return true
+	default:
+		return false
+	}
+}
+
+// runDenoise runs the denoise command with the passed arguments, which denoises a rendering
+// using the feature buffers of its AOVs, such as the EXR files of a rendering and its AOVs
+// written by running the renderings with the -aovs flag.
+// The rendering and its features are read from files in any of the formats that renderings
+// are written in, although features with negative values, such as normals, need a format
+// for high dynamic range colors.
+func runDenoise(args []string) error {
+	flags := flag.NewFlagSet("denoise", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: denoise [flags] <input file> <output file>\n\n"+
+			"The input file and features are written by running the renderings with -aovs.\n")
+		flags.PrintDefaults()
+	}
+	albedoFile := flags.String("albedo", "", "the file of the albedo AOV")
+	normalFile := flags.String("normal", "", "the file of the normal AOV")
+	depthFile := flags.String("depth", "", "the file of the depth AOV")
+	varianceFile := flags.String("variance", "", "the file of the variance AOV")
+	opts := canvas.NewDenoiseOptions()
+	flags.IntVar(&opts.Radius, "radius", opts.Radius,
+		"the radius, in pixels, of the window of neighboring pixels that are averaged")
+	flags.Float64Var(&opts.NoiseSigma, "noise-sigma", opts.NoiseSigma,
+		"the sigma of color differences relative to the noise of the pixels")
+	flags.Float64Var(&opts.ColorSigma, "color-sigma", opts.ColorSigma,
+		"the sigma of color differences when there is no variance AOV")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("denoise needs an input file and an output file")
+	}
+
+	c, err := readCanvasFromFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var features canvas.DenoiseFeatures
+	for _, feature := range []struct {
+		file   string
+		canvas **canvas.Canvas
+	}{
+		{*albedoFile, &features.Albedo},
+		{*normalFile, &features.Normal},
+		{*depthFile, &features.Depth},
+		{*varianceFile, &features.Variance},
+	} {
+		if feature.file == "" {
+			continue
+		}
+		*feature.canvas, err = readCanvasFromFile(feature.file)
+		if err != nil {
+			return err
+		}
+	}
+
+	denoised, err := c.Denoise(features, opts)
+	if err != nil {
+		return err
+	}
+
+	// Linear colors written to a file with 8 bits per color component are transformed
+	// in the same way as renderings are
+	if isHDRFile(flags.Arg(0)) && !isHDRFile(flags.Arg(1)) {
+		denoised.OutputTransform = canvas.NewOutputTransform()
+	}
+
+	return writeCanvasToFile(denoised, flags.Arg(1))
+}
+
+// readCanvasFromFile reads a canvas from the file at the passed path. The format of the file
+// is selected by its extension, which may be any of the extensions of writeCanvasToFile.
+func readCanvasFromFile(filePath string) (*canvas.Canvas, error) {
+	var read func(io.Reader) (*canvas.Canvas, error)
+	switch ext := strings.ToLower(filepath.Ext(filePath)); ext {
+	case ".png", ".jpg", ".jpeg":
+		read = func(r io.Reader) (*canvas.Canvas, error) {
+			img, _, err := image.Decode(r)
+			if err != nil {
+				return nil, err
+			}
+			return canvas.FromImage(img), nil
+		}
+	case ".ppm":
+		read = canvas.ReadPPM
+	case ".pfm":
+		read = canvas.ReadPFM
+	case ".hdr":
+		read = canvas.ReadHDR
+	case ".exr":
+		read = canvas.ReadEXR
+	default:
+		return nil, fmt.Errorf("unsupported input file extension %q", ext)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	c, err := read(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %v: %v", filePath, err)
+	}
+
+	return c, nil
+}
+
+// runWorker runs the worker command with the passed arguments, which serves the tiles of
+// the scenes to coordinators over HTTP until it fails.
+func runWorker(args []string) error {
+	flags := flag.NewFlagSet("worker", flag.ContinueOnError)
+	listen := flags.String("listen", ":8080", "the address to serve tiles at")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Serving the tiles of the scenes at %v\n", *listen)
+	return http.ListenAndServe(*listen, farm.NewWorker(scenes))
+}
+
+// runCoordinator runs the coordinator command with the passed arguments, which renders a
+// scene by handing its tiles to the workers and writes the rendering to the output file.
+// Each of the workers must serve the scene, such as by running the worker command.
+func runCoordinator(args []string) error {
+	flags := flag.NewFlagSet("coordinator", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: coordinator [flags] <output file>\n\n")
+		flags.PrintDefaults()
+	}
+	workers := flags.String("workers", "",
+		"a comma-separated list of the base URLs of the workers, such as http://localhost:8080")
+	name := flags.String("scene", "world_shadow_3d", "the name of the scene to render")
+	requests := flags.Int("requests", 1, "the number of tiles sent to each worker at a time")
+	timeout := flags.Duration("tile-timeout", farm.DefaultTileTimeout,
+		"the time to wait for a worker to render a tile")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("coordinator needs an output file")
+	}
+	if *workers == "" {
+		return errors.New("coordinator needs at least one worker")
+	}
+	scene, ok := scenes[*name]
+	if !ok {
+		return fmt.Errorf("unknown scene %q", *name)
+	}
+
+	co := farm.NewCoordinator(strings.Split(*workers, ",")...)
+	err = co.SetRequestsPerWorker(*requests)
+	if err != nil {
+		return err
+	}
+	err = co.SetTileTimeout(*timeout)
+	if err != nil {
+		return err
+	}
+
+	startTime := time.Now()
+	c, err := co.Render(context.Background(), *name, scene)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Render time: %v seconds\n\n", time.Now().Sub(startTime).Seconds())
+
+	c.OutputTransform = canvas.NewOutputTransform()
+	return writeCanvasToFile(c, flags.Arg(0))
 }
@@ -1,6 +1,14 @@
 package main
 
-import "testing"
+import (
+	"github.com/austingebauer/go-ray-tracer/canvas"
+	"github.com/austingebauer/go-ray-tracer/color"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func BenchmarkRenderRayTracedWorld3D(b *testing.B) {
 	for i := 0; i < b.N; i++ {
@@ -31,3 +39,107 @@ func BenchmarkRenderProjectile(b *testing.B) {
 		RenderProjectile()
 	}
 }
+
+func TestRunDenoise(t *testing.T) {
+	dir, err := ioutil.TempDir("", "denoise")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// A flat gray image with one noisy pixel, and a flat white albedo
+	img := canvas.NewCanvas(8, 8)
+	albedo := canvas.NewCanvas(8, 8)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.Pixels[y][x] = *color.NewColor(0.5, 0.5, 0.5)
+			albedo.Pixels[y][x] = *color.NewColor(1, 1, 1)
+		}
+	}
+	img.Pixels[4][4] = *color.NewColor(0.6, 0.6, 0.6)
+
+	input := filepath.Join(dir, "input.pfm")
+	albedoFile := filepath.Join(dir, "albedo.pfm")
+	output := filepath.Join(dir, "output.pfm")
+	assert.NoError(t, writeCanvasToFile(img, input))
+	assert.NoError(t, writeCanvasToFile(albedo, albedoFile))
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{
+			name: "denoise with an albedo",
+			args: []string{"-albedo", albedoFile, "-radius", "2", input, output},
+		},
+		{
+			name:    "no output file",
+			args:    []string{input},
+			wantErr: true,
+		},
+		{
+			name:    "negative radius",
+			args:    []string{"-radius", "-1", input, output},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported input file extension",
+			args:    []string{filepath.Join(dir, "input.bmp"), output},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported output file extension",
+			args:    []string{input, filepath.Join(dir, "output.bmp")},
+			wantErr: true,
+		},
+		{
+			name:    "output file that can't be created",
+			args:    []string{input, filepath.Join(dir, "missing", "output.pfm")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runDenoise(tt.args)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			// The noisy pixel is smoothed toward its neighbors
+			denoised, err := readCanvasFromFile(tt.args[len(tt.args)-1])
+			assert.NoError(t, err)
+			assert.Equal(t, 8, denoised.Width)
+			assert.Equal(t, 8, denoised.Height)
+			assert.True(t, denoised.Pixels[4][4].Red >= 0.5)
+			assert.True(t, denoised.Pixels[4][4].Red < 0.6)
+		})
+	}
+}
+
+func TestAOVFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		filePath string
+		aov      string
+		want     string
+	}{
+		{
+			name:     "AOV of a PNG rendering",
+			filePath: "docs/world.png",
+			aov:      "albedo",
+			want:     "docs/world_albedo.exr",
+		},
+		{
+			name:     "linear copy of a PNG rendering",
+			filePath: "docs/world.png",
+			aov:      "",
+			want:     "docs/world.exr",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, aovFile(tt.filePath, tt.aov))
+		})
+	}
+}
@@ -17,6 +17,8 @@ const (
 // Material represents a material on the surface of an object.
 // It uses the Phong reflection model to simulate the reflection of light.
 type Material struct {
+	// The Name of the material, which identifies it in ID mattes. Default: empty
+	Name string
 	// The Color of the material. Default: white
 	Color color.Color
 	// The Ambient reflection of the material. Range is [0, 1]. Default is 0.1.
@@ -27,6 +29,8 @@ type Material struct {
 	Specular float64
 	// The Shininess of the material. Range is [10, 200]. Default is 200.0
 	Shininess float64
+	// If NoShadow is true, then objects with the material don't cast shadows. Default is false.
+	NoShadow bool
 }
 
 // NewDefaultMaterial returns a new Material with default values.
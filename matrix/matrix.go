@@ -301,3 +301,22 @@ func CheckInBounds(m *Matrix, row, col uint) error {
 
 	return nil
 }
+
+// Interpolate returns a new Matrix whose elements are linearly interpolated between
+// the elements of the passed matrices, where t is 0 at m1 and 1 at m2.
+// If the passed matrices do not have the same order, then an error is returned.
+//
+// Note that interpolating the elements of two rotation matrices does not produce
+// a rotation matrix, so rotations should be interpolated in small steps.
+func Interpolate(m1, m2 *Matrix, t float64) (*Matrix, error) {
+	if m1.rows != m2.rows || m1.cols != m2.cols {
+		return nil, errors.New("m1 and m2 must have equal row and column lengths")
+	}
+
+	interpM := NewMatrix(m1.rows, m1.cols)
+	for i := range interpM.data {
+		interpM.data[i] = m1.data[i] + (m2.data[i]-m1.data[i])*t
+	}
+
+	return interpM, nil
+}
@@ -0,0 +1,218 @@
+package matrix
+
+import (
+	"errors"
+	"github.com/austingebauer/go-ray-tracer/maths"
+	"github.com/austingebauer/go-ray-tracer/point"
+	"github.com/austingebauer/go-ray-tracer/vector"
+)
+
+// errNotInvertible is returned when a Matrix4 that is not invertible is inverted.
+// It's shared so that inverting a Matrix4 never allocates.
+var errNotInvertible = errors.New("the passed matrix is not invertible")
+
+// Matrix4 is a 4x4 matrix of floating point numbers, stored in row-major order.
+//
+// Unlike Matrix, a Matrix4 is a value that is never allocated on the heap by its
+// operations, which makes it suitable for transforming points and vectors while rendering.
+type Matrix4 [16]float64
+
+// Tuple is a 4-component column vector, whose last component
+// is 1 for a Point and 0 for a Vector.
+type Tuple [4]float64
+
+// NewIdentityMatrix4 returns the 4x4 identity Matrix4.
+func NewIdentityMatrix4() Matrix4 {
+	return Matrix4{
+		1, 0, 0, 0,
+		0, 1, 0, 0,
+		0, 0, 1, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// ToMatrix4 returns the Matrix4 that has the elements of the passed Matrix.
+// If the passed Matrix is not of 4x4 order, then an error is returned.
+func ToMatrix4(m *Matrix) (Matrix4, error) {
+	if m.rows != 4 || m.cols != 4 {
+		return Matrix4{}, errors.New("order of matrix m must be 4x4")
+	}
+
+	var m4 Matrix4
+	copy(m4[:], m.data)
+	return m4, nil
+}
+
+// ToMatrix returns a new Matrix that has the elements of this Matrix4.
+func (m Matrix4) ToMatrix() *Matrix {
+	mat := NewMatrix(4, 4)
+	copy(mat.data, m[:])
+	return mat
+}
+
+// At returns the value at the passed row and column in this Matrix4.
+func (m Matrix4) At(row, col int) float64 {
+	return m[row*4+col]
+}
+
+// Equals returns true if this Matrix4 has identical elements as the passed Matrix4.
+func (m Matrix4) Equals(m1 Matrix4) bool {
+	for i := range m {
+		if !maths.Float64Equals(m[i], m1[i], maths.Epsilon) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Multiply returns the result of multiplying this Matrix4 by the passed Matrix4.
+func (m Matrix4) Multiply(m1 Matrix4) Matrix4 {
+	var multM Matrix4
+	for r := 0; r < 4; r++ {
+		for c := 0; c < 4; c++ {
+			multM[r*4+c] = m[r*4]*m1[c] +
+				m[r*4+1]*m1[4+c] +
+				m[r*4+2]*m1[8+c] +
+				m[r*4+3]*m1[12+c]
+		}
+	}
+
+	return multM
+}
+
+// MultiplyTuple returns the result of multiplying this Matrix4 by the passed Tuple.
+func (m Matrix4) MultiplyTuple(t Tuple) Tuple {
+	return Tuple{
+		m[0]*t[0] + m[1]*t[1] + m[2]*t[2] + m[3]*t[3],
+		m[4]*t[0] + m[5]*t[1] + m[6]*t[2] + m[7]*t[3],
+		m[8]*t[0] + m[9]*t[1] + m[10]*t[2] + m[11]*t[3],
+		m[12]*t[0] + m[13]*t[1] + m[14]*t[2] + m[15]*t[3],
+	}
+}
+
+// MultiplyPoint returns the passed Point transformed by this Matrix4.
+func (m Matrix4) MultiplyPoint(pt point.Point) point.Point {
+	return point.Point{
+		X: m[0]*pt.X + m[1]*pt.Y + m[2]*pt.Z + m[3],
+		Y: m[4]*pt.X + m[5]*pt.Y + m[6]*pt.Z + m[7],
+		Z: m[8]*pt.X + m[9]*pt.Y + m[10]*pt.Z + m[11],
+	}
+}
+
+// MultiplyVector returns the passed Vector transformed by this Matrix4.
+// Unlike a Point, a Vector is not affected by the translation of the Matrix4.
+func (m Matrix4) MultiplyVector(vec vector.Vector) vector.Vector {
+	return vector.Vector{
+		X: m[0]*vec.X + m[1]*vec.Y + m[2]*vec.Z,
+		Y: m[4]*vec.X + m[5]*vec.Y + m[6]*vec.Z,
+		Z: m[8]*vec.X + m[9]*vec.Y + m[10]*vec.Z,
+	}
+}
+
+// Transpose returns the result of transposing this Matrix4.
+func (m Matrix4) Transpose() Matrix4 {
+	return Matrix4{
+		m[0], m[4], m[8], m[12],
+		m[1], m[5], m[9], m[13],
+		m[2], m[6], m[10], m[14],
+		m[3], m[7], m[11], m[15],
+	}
+}
+
+// Determinant returns the determinant of this Matrix4.
+func (m Matrix4) Determinant() float64 {
+	s, c := m.subfactors()
+	return determinant4(s, c)
+}
+
+// Inverse returns the inverse of this Matrix4.
+// If this Matrix4 is not invertible, then an error is returned.
+//
+// The inverse is computed in closed form from the determinants of the 2x2 submatrices
+// of the upper and lower halves of the matrix, rather than recursively from cofactors.
+func (m Matrix4) Inverse() (Matrix4, error) {
+	s, c := m.subfactors()
+	det := determinant4(s, c)
+	if det == 0 {
+		return Matrix4{}, errNotInvertible
+	}
+
+	invDet := 1 / det
+	return Matrix4{
+		(m[5]*c[5] - m[6]*c[4] + m[7]*c[3]) * invDet,
+		(-m[1]*c[5] + m[2]*c[4] - m[3]*c[3]) * invDet,
+		(m[13]*s[5] - m[14]*s[4] + m[15]*s[3]) * invDet,
+		(-m[9]*s[5] + m[10]*s[4] - m[11]*s[3]) * invDet,
+
+		(-m[4]*c[5] + m[6]*c[2] - m[7]*c[1]) * invDet,
+		(m[0]*c[5] - m[2]*c[2] + m[3]*c[1]) * invDet,
+		(-m[12]*s[5] + m[14]*s[2] - m[15]*s[1]) * invDet,
+		(m[8]*s[5] - m[10]*s[2] + m[11]*s[1]) * invDet,
+
+		(m[4]*c[4] - m[5]*c[2] + m[7]*c[0]) * invDet,
+		(-m[0]*c[4] + m[1]*c[2] - m[3]*c[0]) * invDet,
+		(m[12]*s[4] - m[13]*s[2] + m[15]*s[0]) * invDet,
+		(-m[8]*s[4] + m[9]*s[2] - m[11]*s[0]) * invDet,
+
+		(-m[4]*c[3] + m[5]*c[1] - m[6]*c[0]) * invDet,
+		(m[0]*c[3] - m[1]*c[1] + m[2]*c[0]) * invDet,
+		(-m[12]*s[3] + m[13]*s[1] - m[14]*s[0]) * invDet,
+		(m[8]*s[3] - m[9]*s[1] + m[10]*s[0]) * invDet,
+	}, nil
+}
+
+// subfactors returns the determinants of the 2x2 submatrices formed by pairs of
+// columns of the upper two rows, s, and of the lower two rows, c, of this Matrix4.
+func (m Matrix4) subfactors() (s, c [6]float64) {
+	s[0] = m[0]*m[5] - m[4]*m[1]
+	s[1] = m[0]*m[6] - m[4]*m[2]
+	s[2] = m[0]*m[7] - m[4]*m[3]
+	s[3] = m[1]*m[6] - m[5]*m[2]
+	s[4] = m[1]*m[7] - m[5]*m[3]
+	s[5] = m[2]*m[7] - m[6]*m[3]
+
+	c[0] = m[8]*m[13] - m[12]*m[9]
+	c[1] = m[8]*m[14] - m[12]*m[10]
+	c[2] = m[8]*m[15] - m[12]*m[11]
+	c[3] = m[9]*m[14] - m[13]*m[10]
+	c[4] = m[9]*m[15] - m[13]*m[11]
+	c[5] = m[10]*m[15] - m[14]*m[11]
+	return s, c
+}
+
+// determinant4 returns the determinant of a 4x4 matrix from its subfactors.
+func determinant4(s, c [6]float64) float64 {
+	return s[0]*c[5] - s[1]*c[4] + s[2]*c[3] + s[3]*c[2] - s[4]*c[1] + s[5]*c[0]
+}
+
+// InterpolateMatrix4 returns a Matrix4 whose elements are linearly interpolated between
+// the elements of the passed matrices, where t is 0 at m1 and 1 at m2.
+func InterpolateMatrix4(m1, m2 Matrix4, t float64) Matrix4 {
+	var interpM Matrix4
+	for i := range interpM {
+		interpM[i] = m1[i] + (m2[i]-m1[i])*t
+	}
+
+	return interpM
+}
+
+// PointToTuple returns the Tuple that represents the passed Point.
+func PointToTuple(pt point.Point) Tuple {
+	return Tuple{pt.X, pt.Y, pt.Z, 1}
+}
+
+// VectorToTuple returns the Tuple that represents the passed Vector.
+func VectorToTuple(vec vector.Vector) Tuple {
+	return Tuple{vec.X, vec.Y, vec.Z, 0}
+}
+
+// Point returns the Point represented by this Tuple.
+func (t Tuple) Point() point.Point {
+	return point.Point{X: t[0], Y: t[1], Z: t[2]}
+}
+
+// Vector returns the Vector represented by this Tuple.
+func (t Tuple) Vector() vector.Vector {
+	return vector.Vector{X: t[0], Y: t[1], Z: t[2]}
+}
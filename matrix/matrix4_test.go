@@ -0,0 +1,109 @@
+package matrix
+
+import (
+	"github.com/austingebauer/go-ray-tracer/point"
+	"github.com/austingebauer/go-ray-tracer/vector"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestToMatrix4(t *testing.T) {
+	m := NewTranslationMatrix(1, 2, 3)
+	m4, err := ToMatrix4(m)
+	assert.NoError(t, err)
+	assert.Equal(t, Matrix4{
+		1, 0, 0, 1,
+		0, 1, 0, 2,
+		0, 0, 1, 3,
+		0, 0, 0, 1,
+	}, m4)
+	assert.Equal(t, 2.0, m4.At(1, 3))
+	assert.Equal(t, m, m4.ToMatrix())
+
+	_, err = ToMatrix4(NewIdentityMatrix(3))
+	assert.Error(t, err)
+}
+
+func TestMatrix4_Multiply(t *testing.T) {
+	a := NewYRotationMatrix(math.Pi/3).Translate(1, -2, 3).Scale(2, 3, 4)
+	b := NewShearingMatrix(1, 2, 3, 4, 5, 6).RotateZ(0.5)
+	a4, _ := ToMatrix4(a)
+	b4, _ := ToMatrix4(b)
+
+	assert.True(t, a4.Multiply(b4).ToMatrix().Equals(Multiply4x4(a, b)))
+	assert.True(t, a4.Multiply(NewIdentityMatrix4()).Equals(a4))
+	assert.True(t, a4.Transpose().ToMatrix().Equals(Transpose(*a)))
+}
+
+func TestMatrix4_MultiplyPointAndVector(t *testing.T) {
+	m4, _ := ToMatrix4(NewTranslationMatrix(5, -3, 2).Scale(2, 3, 4))
+	pt := point.NewPoint(-4, 6, 8)
+	vec := vector.NewVector(-4, 6, 8)
+
+	wantPt := point.NewPoint(2, 9, 40)
+	wantVec := vector.NewVector(-8, 18, 32)
+	gotPt := m4.MultiplyPoint(*pt)
+	gotVec := m4.MultiplyVector(*vec)
+	assert.True(t, wantPt.Equals(&gotPt))
+	assert.True(t, wantVec.Equals(&gotVec))
+
+	tuplePt := m4.MultiplyTuple(PointToTuple(*pt)).Point()
+	tupleVec := m4.MultiplyTuple(VectorToTuple(*vec)).Vector()
+	assert.Equal(t, gotPt, tuplePt)
+	assert.Equal(t, gotVec, tupleVec)
+}
+
+func TestMatrix4_Inverse(t *testing.T) {
+	// Matches the cofactor inverse of random matrices
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		m := NewMatrix(4, 4)
+		for j := range m.data {
+			m.data[j] = rnd.Float64()*20 - 10
+		}
+		m4, _ := ToMatrix4(m)
+
+		want, err := Inverse(m)
+		assert.NoError(t, err)
+		got, err := m4.Inverse()
+		assert.NoError(t, err)
+		assert.True(t, got.ToMatrix().Equals(want))
+
+		det, _ := Determinant(m)
+		assert.InDelta(t, det, m4.Determinant(), 1e-9)
+		assert.True(t, m4.Multiply(got).Equals(NewIdentityMatrix4()))
+	}
+
+	// A matrix that is not invertible
+	singular := Matrix4{
+		-4, 2, -2, -3,
+		9, 6, 2, 6,
+		0, -5, 1, -5,
+		0, 0, 0, 0,
+	}
+	assert.Equal(t, 0.0, singular.Determinant())
+	_, err := singular.Inverse()
+	assert.Error(t, err)
+}
+
+func TestInterpolateMatrix4(t *testing.T) {
+	a := NewTranslationMatrix(-3, 0, 0)
+	b := NewTranslationMatrix(3, 2, 0)
+	a4, _ := ToMatrix4(a)
+	b4, _ := ToMatrix4(b)
+
+	want, _ := Interpolate(a, b, 0.25)
+	assert.True(t, InterpolateMatrix4(a4, b4, 0.25).ToMatrix().Equals(want))
+}
+
+func TestMatrix4_InverseAllocations(t *testing.T) {
+	m4, _ := ToMatrix4(NewXRotationMatrix(1).Translate(1, 2, 3))
+	pt := point.NewPoint(1, 2, 3)
+	allocs := testing.AllocsPerRun(100, func() {
+		inv, _ := m4.Inverse()
+		_ = inv.Transpose().MultiplyPoint(*pt)
+	})
+	assert.Equal(t, 0.0, allocs)
+}
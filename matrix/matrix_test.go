@@ -1721,3 +1721,69 @@ func TestMultiply4x4(t *testing.T) {
 		})
 	}
 }
+
+func TestInterpolate(t *testing.T) {
+	type args struct {
+		m1 *Matrix
+		m2 *Matrix
+		t  float64
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *Matrix
+		wantErr bool
+	}{
+		{
+			name: "interpolate halfway between translation matrices",
+			args: args{
+				m1: NewTranslationMatrix(0, 0, 0),
+				m2: NewTranslationMatrix(2, 4, -6),
+				t:  0.5,
+			},
+			want:    NewTranslationMatrix(1, 2, -3),
+			wantErr: false,
+		},
+		{
+			name: "interpolate at the start of scaling matrices",
+			args: args{
+				m1: NewScalingMatrix(1, 1, 1),
+				m2: NewScalingMatrix(3, 3, 3),
+				t:  0,
+			},
+			want:    NewScalingMatrix(1, 1, 1),
+			wantErr: false,
+		},
+		{
+			name: "interpolate at the end of scaling matrices",
+			args: args{
+				m1: NewScalingMatrix(1, 1, 1),
+				m2: NewScalingMatrix(3, 3, 3),
+				t:  1,
+			},
+			want:    NewScalingMatrix(3, 3, 3),
+			wantErr: false,
+		},
+		{
+			name: "interpolate matrices of different orders",
+			args: args{
+				m1: NewIdentityMatrix(4),
+				m2: NewIdentityMatrix(3),
+				t:  0.5,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Interpolate(tt.args.m1, tt.args.m2, tt.args.t)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package matrix
+
+import (
+	"github.com/austingebauer/go-ray-tracer/point"
+	"github.com/austingebauer/go-ray-tracer/vector"
+	"math"
+)
+
+// NewTranslationMatrix4 returns the Matrix4 that translates by the passed
+// x, y, and z values, which has the same form as NewTranslationMatrix.
+func NewTranslationMatrix4(x, y, z float64) Matrix4 {
+	return Matrix4{
+		1, 0, 0, x,
+		0, 1, 0, y,
+		0, 0, 1, z,
+		0, 0, 0, 1,
+	}
+}
+
+// NewScalingMatrix4 returns the Matrix4 that scales by the passed
+// x, y, and z values, which has the same form as NewScalingMatrix.
+func NewScalingMatrix4(x, y, z float64) Matrix4 {
+	return Matrix4{
+		x, 0, 0, 0,
+		0, y, 0, 0,
+		0, 0, z, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// NewXRotationMatrix4 returns the Matrix4 that rotates around the x-axis by the
+// passed number of radians, which has the same form as NewXRotationMatrix.
+func NewXRotationMatrix4(radians float64) Matrix4 {
+	sin, cos := math.Sincos(radians)
+	return Matrix4{
+		1, 0, 0, 0,
+		0, cos, -sin, 0,
+		0, sin, cos, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// NewYRotationMatrix4 returns the Matrix4 that rotates around the y-axis by the
+// passed number of radians, which has the same form as NewYRotationMatrix.
+func NewYRotationMatrix4(radians float64) Matrix4 {
+	sin, cos := math.Sincos(radians)
+	return Matrix4{
+		cos, 0, sin, 0,
+		0, 1, 0, 0,
+		-sin, 0, cos, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// NewZRotationMatrix4 returns the Matrix4 that rotates around the z-axis by the
+// passed number of radians, which has the same form as NewZRotationMatrix.
+func NewZRotationMatrix4(radians float64) Matrix4 {
+	sin, cos := math.Sincos(radians)
+	return Matrix4{
+		cos, -sin, 0, 0,
+		sin, cos, 0, 0,
+		0, 0, 1, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// NewShearingMatrix4 returns the Matrix4 that shears by the passed values,
+// which has the same form as NewShearingMatrix.
+func NewShearingMatrix4(xy, xz, yx, yz, zx, zy float64) Matrix4 {
+	return Matrix4{
+		1, xy, xz, 0,
+		yx, 1, yz, 0,
+		zx, zy, 1, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// ViewTransformMatrix4 returns the Matrix4 that transforms a camera view in a scene,
+// which has the same form as ViewTransform.
+func ViewTransformMatrix4(from, to point.Point, up vector.Vector) Matrix4 {
+	forwardVec := point.Subtract(to, from).Normalize()
+	leftVec := vector.CrossProduct(*forwardVec, *vector.Normalize(up))
+	trueUpVec := vector.CrossProduct(leftVec, *forwardVec)
+
+	orientation := Matrix4{
+		leftVec.X, leftVec.Y, leftVec.Z, 0,
+		trueUpVec.X, trueUpVec.Y, trueUpVec.Z, 0,
+		-forwardVec.X, -forwardVec.Y, -forwardVec.Z, 0,
+		0, 0, 0, 1,
+	}
+	return orientation.Multiply(NewTranslationMatrix4(-from.X, -from.Y, -from.Z))
+}
+
+// Translate returns this Matrix4 followed by a translation by the passed x, y, and z values.
+func (m Matrix4) Translate(x, y, z float64) Matrix4 {
+	return NewTranslationMatrix4(x, y, z).Multiply(m)
+}
+
+// Scale returns this Matrix4 followed by a scaling by the passed x, y, and z values.
+func (m Matrix4) Scale(x, y, z float64) Matrix4 {
+	return NewScalingMatrix4(x, y, z).Multiply(m)
+}
+
+// RotateX returns this Matrix4 followed by a rotation around the x-axis by the passed
+// number of radians.
+func (m Matrix4) RotateX(radians float64) Matrix4 {
+	return NewXRotationMatrix4(radians).Multiply(m)
+}
+
+// RotateY returns this Matrix4 followed by a rotation around the y-axis by the passed
+// number of radians.
+func (m Matrix4) RotateY(radians float64) Matrix4 {
+	return NewYRotationMatrix4(radians).Multiply(m)
+}
+
+// RotateZ returns this Matrix4 followed by a rotation around the z-axis by the passed
+// number of radians.
+func (m Matrix4) RotateZ(radians float64) Matrix4 {
+	return NewZRotationMatrix4(radians).Multiply(m)
+}
+
+// Shear returns this Matrix4 followed by a shearing by the passed values.
+func (m Matrix4) Shear(xy, xz, yx, yz, zx, zy float64) Matrix4 {
+	return NewShearingMatrix4(xy, xz, yx, yz, zx, zy).Multiply(m)
+}
@@ -0,0 +1,67 @@
+package matrix
+
+import (
+	"github.com/austingebauer/go-ray-tracer/point"
+	"github.com/austingebauer/go-ray-tracer/vector"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestTransformationMatrix4(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Matrix4
+		want *Matrix
+	}{
+		{
+			name: "translation",
+			got:  NewTranslationMatrix4(5, -3, 2),
+			want: NewTranslationMatrix(5, -3, 2),
+		},
+		{
+			name: "scaling",
+			got:  NewScalingMatrix4(2, 3, 4),
+			want: NewScalingMatrix(2, 3, 4),
+		},
+		{
+			name: "rotation around the x-axis",
+			got:  NewXRotationMatrix4(math.Pi / 3),
+			want: NewXRotationMatrix(math.Pi / 3),
+		},
+		{
+			name: "rotation around the y-axis",
+			got:  NewYRotationMatrix4(math.Pi / 3),
+			want: NewYRotationMatrix(math.Pi / 3),
+		},
+		{
+			name: "rotation around the z-axis",
+			got:  NewZRotationMatrix4(math.Pi / 3),
+			want: NewZRotationMatrix(math.Pi / 3),
+		},
+		{
+			name: "shearing",
+			got:  NewShearingMatrix4(1, 2, 3, 4, 5, 6),
+			want: NewShearingMatrix(1, 2, 3, 4, 5, 6),
+		},
+		{
+			name: "view transformation",
+			got: ViewTransformMatrix4(*point.NewPoint(1, 3, 2), *point.NewPoint(4, -2, 8),
+				*vector.NewVector(1, 1, 0)),
+			want: ViewTransform(*point.NewPoint(1, 3, 2), *point.NewPoint(4, -2, 8),
+				*vector.NewVector(1, 1, 0)),
+		},
+		{
+			name: "chained transformations",
+			got: NewIdentityMatrix4().RotateX(math.Pi/2).RotateY(math.Pi/4).RotateZ(math.Pi/5).
+				Shear(0, 1, 0, 0, 0, 0).Scale(5, 5, 5).Translate(10, 5, 7),
+			want: NewIdentityMatrix(4).RotateX(math.Pi/2).RotateY(math.Pi/4).RotateZ(math.Pi/5).
+				Shear(0, 1, 0, 0, 0, 0).Scale(5, 5, 5).Translate(10, 5, 7),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.True(t, tt.want.Equals(tt.got.ToMatrix()))
+		})
+	}
+}
@@ -2,7 +2,6 @@ package ray
 
 import (
 	"github.com/austingebauer/go-ray-tracer/maths"
-	"github.com/austingebauer/go-ray-tracer/matrix"
 	"github.com/austingebauer/go-ray-tracer/point"
 	"github.com/austingebauer/go-ray-tracer/sphere"
 	"github.com/austingebauer/go-ray-tracer/vector"
@@ -31,9 +30,9 @@ type IntersectionComputations struct {
 	// The Point at which the ray intersected the object
 	Point *point.Point
 
-	// The OverPoint is the Point that has been slightly adjusted in
-	// the direction of the NormalVec in order to avoid shadow acne from
-	// self-intersection when determining if an intersection is in shadow.
+	// The OverPoint is the Point that has been slightly adjusted in the direction
+	// of the NormalVec. Shadow rays start at the Point itself, and avoid shadow acne
+	// from self-intersection by starting their interval just past it instead.
 	OverPoint *point.Point
 
 	// The eye vector points in the opposite direction as the ray
@@ -45,6 +44,9 @@ type IntersectionComputations struct {
 	// If Inside is true, the intersection occurred from Inside of the object.
 	// Otherwise the intersection occurred from the outside of the object.
 	Inside bool
+
+	// The Time at which the ray that produced the intersection was cast
+	Time float64
 }
 
 // NewIntersection returns a new Intersection with the passed t value and object.
@@ -57,40 +59,76 @@ func NewIntersection(t float64, object *sphere.Sphere) *Intersection {
 
 // PrepareComputations computes and returns additional information related to an intersection.
 func PrepareComputations(i *Intersection, r *Ray) (*IntersectionComputations, error) {
-	comps := &IntersectionComputations{
-		Intersection: *i,
+	comps := &IntersectionComputations{}
+	err := PrepareComputationsInto(i, r, comps)
+	if err != nil {
+		return nil, err
 	}
 
+	return comps, nil
+}
+
+// PrepareComputationsInto computes additional information related to an intersection,
+// and fills the passed computations with it in place. The points and vectors that the
+// computations refer to are reused, so filling the same computations for each ray that
+// is cast only allocates them the first time.
+func PrepareComputationsInto(i *Intersection, r *Ray, comps *IntersectionComputations) error {
 	// Compute the Point at which the ray intersected the sphere
-	rayIntersectionPt := Position(r, comps.Intersection.T)
+	rayIntersectionPt := point.Point{
+		X: r.Origin.X + r.Direction.X*i.T,
+		Y: r.Origin.Y + r.Direction.Y*i.T,
+		Z: r.Origin.Z + r.Direction.Z*i.T,
+	}
 
 	// Compute the eye vector
-	eyeVec := vector.Scale(*r.Direction, -1)
+	eyeVec := vector.Vector{X: -r.Direction.X, Y: -r.Direction.Y, Z: -r.Direction.Z}
 
 	// Compute the normal vector on the surface of the sphere at the intersection Point
-	normalVec, err := sphere.NormalAt(comps.Intersection.Object, rayIntersectionPt)
+	normalVec, err := sphere.NormalVectorAt(i.Object, rayIntersectionPt, r.Time)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// If the dot product of the normal vector and ray direction vector is negative,
 	// then the intersection occurred from the Inside of the object. Otherwise,
 	// the intersection occurred from the outside of the object.
-	dotProduct := vector.DotProduct(*normalVec, *eyeVec)
+	inside := false
+	dotProduct := vector.DotProduct(normalVec, eyeVec)
 	if dotProduct < 0 {
-		comps.Inside = true
+		inside = true
 		normalVec.Negate()
 	}
 
-	comps.Point = rayIntersectionPt
-	comps.EyeVec = eyeVec
-	comps.NormalVec = normalVec
+	// Allocate the points and vectors of the computations the first time they're filled
+	if comps.Point == nil {
+		comps.Point = &point.Point{}
+	}
+	if comps.OverPoint == nil {
+		comps.OverPoint = &point.Point{}
+	}
+	if comps.EyeVec == nil {
+		comps.EyeVec = &vector.Vector{}
+	}
+	if comps.NormalVec == nil {
+		comps.NormalVec = &vector.Vector{}
+	}
+
+	comps.Intersection = *i
+	*comps.Point = rayIntersectionPt
+	*comps.EyeVec = eyeVec
+	*comps.NormalVec = normalVec
+	comps.Inside = inside
+	comps.Time = r.Time
 
 	// Compute the over point in order to avoid rendering shadow acne
 	// caused by the shadow ray intersecting with the object itself.
-	comps.OverPoint = point.Add(comps.Point, vector.Scale(*comps.NormalVec, maths.Epsilon))
+	*comps.OverPoint = point.Point{
+		X: rayIntersectionPt.X + normalVec.X*maths.Epsilon,
+		Y: rayIntersectionPt.Y + normalVec.Y*maths.Epsilon,
+		Z: rayIntersectionPt.Z + normalVec.Z*maths.Epsilon,
+	}
 
-	return comps, nil
+	return nil
 }
 
 // Intersections returns a slice of the passed Intersections.
@@ -99,28 +137,33 @@ func Intersections(intersections ...*Intersection) []*Intersection {
 }
 
 // Hit returns the Intersection with the lowest non-negative T value.
+// If there are no intersections with a non-negative T value, then nil is returned.
+//
+// The intersections are searched in a single pass, so they don't need to be sorted.
 func Hit(intersections []*Intersection) *Intersection {
-	if len(intersections) == 0 {
-		return nil
+	var hit *Intersection
+	for _, intersection := range intersections {
+		if intersection.T >= 0 && (hit == nil || intersection.T < hit.T) {
+			hit = intersection
+		}
 	}
 
-	// Sort the intersections ascending from index 0 on T.
-	SortIntersectionsAsc(intersections)
-
-	// Search the intersections until a non-negative T value is found
-	idx := 0
-	for idx < len(intersections) && intersections[idx].T < 0 {
-		idx++
-	}
+	return hit
+}
 
-	// If the entire slice was searched, then we didn't find a positive T value.
-	// No hit, return nil.
-	if idx == len(intersections) {
-		return nil
+// HitIndex returns the index of the Intersection with the lowest non-negative T value
+// in the passed intersections, or -1 if there are no intersections with a non-negative
+// T value. Like Hit, it searches the intersections in a single pass.
+func HitIndex(intersections []Intersection) int {
+	hit := -1
+	for idx := range intersections {
+		t := intersections[idx].T
+		if t >= 0 && (hit == -1 || t < intersections[hit].T) {
+			hit = idx
+		}
 	}
 
-	// idx is sitting at the Intersection with the lowest, non-negative T value
-	return intersections[idx]
+	return hit
 }
 
 // SortIntersectionsAsc sorts the passed intersections into ascending order.
@@ -145,42 +188,94 @@ func SortIntersectionsDesc(intersections []*Intersection) {
 // If the ray intersects with the sphere at two points, then two different intersection t values are returned.
 // If the ray intersects with the sphere at a single, tangent Point, then two equal t values are returned.
 // If the ray does not intersect with the sphere, then an empty slice is returned.
-func RaySphereIntersect(r *Ray, s *sphere.Sphere) []*Intersection {
+// Intersections outside of the interval of the ray, set using SetInterval, are discarded.
+// If the transform of the sphere is not invertible, then an error is returned.
+func RaySphereIntersect(r *Ray, s *sphere.Sphere) ([]*Intersection, error) {
+	var buf [2]Intersection
+	xs, err := IntersectSphere(r, s, buf[:0])
+	if err != nil {
+		return nil, err
+	}
+
+	intersections := make([]*Intersection, len(xs))
+	for idx := range xs {
+		intersections[idx] = &Intersection{
+			T:      xs[idx].T,
+			Object: xs[idx].Object,
+		}
+	}
+
+	return intersections, nil
+}
+
+// IntersectSphere intersects the passed ray with the passed sphere in the same way as
+// RaySphereIntersect, but appends the intersections to the passed buffer and returns
+// the extended buffer. Reusing a buffer that has room for the intersections, such as
+// one buffer for each goroutine that casts rays, avoids allocating them.
+func IntersectSphere(r *Ray, s *sphere.Sphere, buf []Intersection) ([]Intersection, error) {
+	t1, t2, ok, err := sphereRoots(r, s)
+	if err != nil || !ok {
+		return buf, err
+	}
+
+	// Append the intersection t values within the ray's interval and object in increasing order
+	if InInterval(r, t1) {
+		buf = append(buf, Intersection{T: t1, Object: s})
+	}
+	if InInterval(r, t2) {
+		buf = append(buf, Intersection{T: t2, Object: s})
+	}
+	return buf, nil
+}
+
+// IntersectsSphere returns true if the passed ray intersects with the passed sphere
+// within the interval of the ray. It's used to find out whether anything lies along
+// a ray, such as between a point and a light, without finding the intersections.
+func IntersectsSphere(r *Ray, s *sphere.Sphere) (bool, error) {
+	t1, t2, ok, err := sphereRoots(r, s)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	return InInterval(r, t1) || InInterval(r, t2), nil
+}
+
+// sphereRoots returns the t values at which the passed ray intersects with the passed
+// sphere in increasing order, or false if the ray does not intersect with the sphere.
+func sphereRoots(r *Ray, s *sphere.Sphere) (t1, t2 float64, ok bool, err error) {
 	// Details on calculation: https://en.wikipedia.org/wiki/Line%E2%80%93sphere_intersection
 
 	// transform the r by the inverse of the transformation associated with the s
-	// in order to use unit s. Moving the r makes for more simple math and
-	// same intersection results.
-	sphereTransformInverse, _ := matrix.Inverse(s.Transform)
-	transformedRay, _ := Transform(r, sphereTransformInverse)
+	// in order to use the untransformed s, which has its own origin and radius.
+	// Moving the r makes for more simple math and same intersection results.
+	// A moving s is transformed as it was at the time of r.
+	sphereTransformInverse, _, err := s.InverseTransformAt(r.Time)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	transformedOrigin := sphereTransformInverse.MultiplyPoint(*r.Origin)
+	transformedDirection := sphereTransformInverse.MultiplyVector(*r.Direction)
 
 	// The vector from the s origin to the r origin.
-	sphereToRayVec := point.Subtract(*transformedRay.Origin, *s.Origin)
+	sphereToRayVec := vector.Vector{
+		X: transformedOrigin.X - s.Origin.X,
+		Y: transformedOrigin.Y - s.Origin.Y,
+		Z: transformedOrigin.Z - s.Origin.Z,
+	}
 
 	// Compute the discriminant to tell whether the r intersects with the s at all.
-	a := vector.DotProduct(*transformedRay.Direction, *transformedRay.Direction)
-	b := 2 * vector.DotProduct(*transformedRay.Direction, *sphereToRayVec)
-	c := vector.DotProduct(*sphereToRayVec, *sphereToRayVec) - 1
-	discriminant := math.Pow(b, 2) - 4*a*c
+	a := vector.DotProduct(transformedDirection, transformedDirection)
+	b := 2 * vector.DotProduct(transformedDirection, sphereToRayVec)
+	c := vector.DotProduct(sphereToRayVec, sphereToRayVec) - s.Radius*s.Radius
+	discriminant := b*b - 4*a*c
 
 	// If the discriminant is negative, then the r misses the s and no intersections occur.
 	if discriminant < 0 {
-		return []*Intersection{}
+		return 0, 0, false, nil
 	}
 
 	// Compute the t values.
-	t1 := ((-1 * b) - math.Sqrt(discriminant)) / (2 * a)
-	t2 := ((-1 * b) + math.Sqrt(discriminant)) / (2 * a)
-
-	// Return the intersection t values and object in increasing order
-	return []*Intersection{
-		{
-			T:      t1,
-			Object: s,
-		},
-		{
-			T:      t2,
-			Object: s,
-		},
-	}
+	t1 = ((-1 * b) - math.Sqrt(discriminant)) / (2 * a)
+	t2 = ((-1 * b) + math.Sqrt(discriminant)) / (2 * a)
+	return t1, t2, true, nil
 }
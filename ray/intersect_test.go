@@ -327,7 +327,7 @@ func TestOverPoint(t *testing.T) {
 	// Assert that the hit offsets the over point field to avoid shadow acne
 	r := NewRay(*point.NewPoint(0, 0, -5), *vector.NewVector(0, 0, 1))
 	shape := sphere.NewUnitSphere("shape")
-	shape.SetTransform(matrix.NewTranslationMatrix(0, 0, 1))
+	assert.NoError(t, shape.SetTransform(matrix.NewTranslationMatrix4(0, 0, 1)))
 	i := NewIntersection(5, shape)
 	comps, err := PrepareComputations(i, r)
 	assert.NoError(t, err)
@@ -343,7 +343,7 @@ func TestRaySphereIntersect(t *testing.T) {
 	type args struct {
 		sphere    *sphere.Sphere
 		ray       *Ray
-		transform *matrix.Matrix
+		transform matrix.Matrix4
 	}
 	tests := []struct {
 		name string
@@ -429,7 +429,8 @@ func TestRaySphereIntersect(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			intersections := RaySphereIntersect(tt.args.ray, tt.args.sphere)
+			intersections, err := RaySphereIntersect(tt.args.ray, tt.args.sphere)
+			assert.NoError(t, err)
 
 			assert.Equal(t, len(tt.want), len(intersections))
 			assert.Equal(t, tt.want, intersections)
@@ -441,7 +442,7 @@ func TestIntersectWithSphereTransform(t *testing.T) {
 	type args struct {
 		sphere    *sphere.Sphere
 		ray       *Ray
-		transform *matrix.Matrix
+		transform matrix.Matrix4
 	}
 	tests := []struct {
 		name string
@@ -453,7 +454,7 @@ func TestIntersectWithSphereTransform(t *testing.T) {
 			args: args{
 				sphere:    sphere.NewUnitSphere("testID"),
 				ray:       NewRay(*point.NewPoint(0, 0, -5), *vector.NewVector(0, 0, 1)),
-				transform: matrix.NewScalingMatrix(2, 2, 2),
+				transform: matrix.NewScalingMatrix4(2, 2, 2),
 			},
 			want: []*Intersection{
 				{
@@ -469,8 +470,9 @@ func TestIntersectWithSphereTransform(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.args.sphere.SetTransform(tt.args.transform)
-			intersections := RaySphereIntersect(tt.args.ray, tt.args.sphere)
+			assert.NoError(t, tt.args.sphere.SetTransform(tt.args.transform))
+			intersections, err := RaySphereIntersect(tt.args.ray, tt.args.sphere)
+			assert.NoError(t, err)
 
 			assert.Equal(t, len(tt.want), len(intersections))
 
@@ -480,3 +482,363 @@ func TestIntersectWithSphereTransform(t *testing.T) {
 		})
 	}
 }
+
+func TestIntersectWithMovingSphere(t *testing.T) {
+	// The sphere moves from x=0 to x=4 between times 0 and 1
+	s := sphere.NewUnitSphere("testID")
+	assert.NoError(t, s.SetTransformKeyframes(
+		sphere.TransformKeyframe{Time: 0, Transform: matrix.NewTranslationMatrix4(0, 0, 0)},
+		sphere.TransformKeyframe{Time: 1, Transform: matrix.NewTranslationMatrix4(4, 0, 0)},
+	))
+
+	tests := []struct {
+		name string
+		time float64
+		want int
+	}{
+		{
+			name: "ray misses the sphere before it arrives",
+			time: 0,
+			want: 0,
+		},
+		{
+			name: "ray hits the sphere while it passes by",
+			time: 0.5,
+			want: 2,
+		},
+		{
+			name: "ray misses the sphere after it leaves",
+			time: 1,
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRay(*point.NewPoint(2, 0, -5), *vector.NewVector(0, 0, 1))
+			r.Time = tt.time
+			intersections, err := RaySphereIntersect(r, s)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, len(intersections))
+		})
+	}
+}
+
+func TestIntersectWithSingularSphereTransform(t *testing.T) {
+	s := sphere.NewUnitSphere("testID")
+	s.Transform = matrix.NewScalingMatrix4(0, 1, 1)
+
+	r := NewRay(*point.NewPoint(0, 0, -5), *vector.NewVector(0, 0, 1))
+	_, err := RaySphereIntersect(r, s)
+	assert.Error(t, err)
+}
+
+func TestPrepareComputationsWithMovingSphere(t *testing.T) {
+	s := sphere.NewUnitSphere("testID")
+	assert.NoError(t, s.SetTransformKeyframes(
+		sphere.TransformKeyframe{Time: 0, Transform: matrix.NewTranslationMatrix4(0, 0, 0)},
+		sphere.TransformKeyframe{Time: 1, Transform: matrix.NewTranslationMatrix4(4, 0, 0)},
+	))
+
+	// At time 0.5, the sphere is centered on x=2 and the ray hits it head on
+	r := NewRay(*point.NewPoint(2, 0, -5), *vector.NewVector(0, 0, 1))
+	r.Time = 0.5
+	comps, err := PrepareComputations(NewIntersection(4, s), r)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, comps.Time)
+	assert.True(t, vector.NewVector(0, 0, -1).Equals(comps.NormalVec))
+}
+
+func TestIntersectWithSphereOriginAndRadius(t *testing.T) {
+	type args struct {
+		origin    *point.Point
+		radius    float64
+		transform matrix.Matrix4
+		ray       *Ray
+	}
+	tests := []struct {
+		name string
+		args args
+		want []float64
+	}{
+		{
+			name: "ray intersects a sphere with a radius of 2",
+			args: args{
+				origin:    point.NewPoint(0, 0, 0),
+				radius:    2,
+				transform: matrix.NewIdentityMatrix4(),
+				ray:       NewRay(*point.NewPoint(0, 0, -5), *vector.NewVector(0, 0, 1)),
+			},
+			want: []float64{3, 7},
+		},
+		{
+			name: "ray intersects a sphere away from the world origin",
+			args: args{
+				origin:    point.NewPoint(3, 0, 4),
+				radius:    1,
+				transform: matrix.NewIdentityMatrix4(),
+				ray:       NewRay(*point.NewPoint(3, 0, -5), *vector.NewVector(0, 0, 1)),
+			},
+			want: []float64{8, 10},
+		},
+		{
+			name: "ray misses a sphere away from the world origin",
+			args: args{
+				origin:    point.NewPoint(3, 0, 4),
+				radius:    1,
+				transform: matrix.NewIdentityMatrix4(),
+				ray:       NewRay(*point.NewPoint(0, 0, -5), *vector.NewVector(0, 0, 1)),
+			},
+			want: []float64{},
+		},
+		{
+			name: "ray intersects a sphere with an origin, radius, and translation",
+			args: args{
+				origin:    point.NewPoint(1, 0, 0),
+				radius:    0.5,
+				transform: matrix.NewTranslationMatrix4(-1, 2, 0),
+				ray:       NewRay(*point.NewPoint(0, 2, -5), *vector.NewVector(0, 0, 1)),
+			},
+			want: []float64{4.5, 5.5},
+		},
+		{
+			name: "ray intersects a sphere with an origin, radius, and scaling",
+			args: args{
+				origin:    point.NewPoint(0, 0, 1),
+				radius:    2,
+				transform: matrix.NewScalingMatrix4(2, 2, 2),
+				ray:       NewRay(*point.NewPoint(0, 0, -10), *vector.NewVector(0, 0, 1)),
+			},
+			want: []float64{8, 16},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := sphere.NewSphere("testID", *tt.args.origin, tt.args.radius)
+			assert.NoError(t, s.SetTransform(tt.args.transform))
+
+			intersections, err := RaySphereIntersect(tt.args.ray, s)
+			assert.NoError(t, err)
+			assert.Equal(t, len(tt.want), len(intersections))
+			for idx, intersection := range intersections {
+				assert.InDelta(t, tt.want[idx], intersection.T, maths.Epsilon)
+			}
+		})
+	}
+}
+
+func TestIntersectWithSphereOriginAndRadiusMatchesTransform(t *testing.T) {
+	// A sphere with an origin and radius is the same as a unit sphere
+	// that is scaled by the radius and translated to the origin.
+	s := sphere.NewSphere("testID", *point.NewPoint(1, -2, 3), 1.5)
+	assert.NoError(t, s.SetTransform(matrix.NewXRotationMatrix4(0.3).Translate(0, 1, 0)))
+
+	unit := sphere.NewUnitSphere("testID")
+	assert.NoError(t, unit.SetTransform(
+		matrix.NewScalingMatrix4(1.5, 1.5, 1.5).Translate(1, -2, 3).RotateX(0.3).Translate(0, 1, 0)))
+
+	r := NewRay(*point.NewPoint(0.5, 0, -5), *vector.Normalize(*vector.NewVector(0.1, -0.2, 1)))
+	got, err := RaySphereIntersect(r, s)
+	assert.NoError(t, err)
+	want, err := RaySphereIntersect(r, unit)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, len(want))
+	assert.Equal(t, len(want), len(got))
+	for idx := range want {
+		assert.InDelta(t, want[idx].T, got[idx].T, maths.Epsilon)
+
+		pt := Position(r, got[idx].T)
+		gotNormal, err := sphere.NormalAt(s, pt)
+		assert.NoError(t, err)
+		wantNormal, err := sphere.NormalAt(unit, pt)
+		assert.NoError(t, err)
+		assert.True(t, wantNormal.Equals(gotNormal))
+	}
+}
+
+func TestHitIndex(t *testing.T) {
+	s := sphere.NewUnitSphere("testID")
+	tests := []struct {
+		name          string
+		intersections []Intersection
+		want          int
+	}{
+		{
+			name:          "no intersections",
+			intersections: []Intersection{},
+			want:          -1,
+		},
+		{
+			name: "all intersections have negative t",
+			intersections: []Intersection{
+				{T: -2, Object: s},
+				{T: -1, Object: s},
+			},
+			want: -1,
+		},
+		{
+			name: "hit is the lowest non-negative intersection in unsorted intersections",
+			intersections: []Intersection{
+				{T: 5, Object: s},
+				{T: 7, Object: s},
+				{T: -3, Object: s},
+				{T: 2, Object: s},
+			},
+			want: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, HitIndex(tt.intersections))
+		})
+	}
+}
+
+func TestIntersectSphere(t *testing.T) {
+	s := sphere.NewUnitSphere("testID")
+	assert.NoError(t, s.SetTransform(matrix.NewScalingMatrix4(2, 2, 2)))
+	r := NewRay(*point.NewPoint(0, 0, -5), *vector.NewVector(0, 0, 1))
+
+	// Intersections are appended to the buffer
+	buf := []Intersection{{T: 1}}
+	buf, err := IntersectSphere(r, s, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, []Intersection{{T: 1}, {T: 3, Object: s}, {T: 7, Object: s}}, buf)
+
+	// A buffer with room for the intersections is reused without allocating
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, _ = IntersectSphere(r, s, buf[:0])
+		HitIndex(buf)
+	})
+	assert.Equal(t, 0.0, allocs)
+}
+
+func TestPrepareComputationsInto(t *testing.T) {
+	s := sphere.NewUnitSphere("testID")
+	r := NewRay(*point.NewPoint(0, 0, -5), *vector.NewVector(0, 0, 1))
+	i := NewIntersection(4, s)
+
+	want, err := PrepareComputations(i, r)
+	assert.NoError(t, err)
+
+	var comps IntersectionComputations
+	assert.NoError(t, PrepareComputationsInto(i, r, &comps))
+	assert.Equal(t, *want, comps)
+
+	// Filling the computations again reuses their points and vectors
+	inside := NewIntersection(1, s)
+	insideRay := NewRay(*point.NewPoint(0, 0, 0), *vector.NewVector(0, 0, 1))
+	pt := comps.Point
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = PrepareComputationsInto(inside, insideRay, &comps)
+	})
+	assert.Equal(t, 0.0, allocs)
+	assert.True(t, pt == comps.Point)
+	assert.True(t, comps.Inside)
+	assert.True(t, point.NewPoint(0, 0, 1).Equals(comps.Point))
+	assert.True(t, vector.NewVector(0, 0, -1).Equals(comps.NormalVec))
+}
+
+func TestIntersectsSphere(t *testing.T) {
+	s := sphere.NewUnitSphere("testID")
+	r := NewRay(*point.NewPoint(0, 0, -5), *vector.NewVector(0, 0, 1))
+	tests := []struct {
+		name string
+		ray  *Ray
+		tMin float64
+		tMax float64
+		want bool
+	}{
+		{
+			name: "both intersections within the interval",
+			ray:  r,
+			tMin: 0,
+			tMax: 10,
+			want: true,
+		},
+		{
+			name: "only the far intersection within the interval",
+			ray:  r,
+			tMin: 5,
+			tMax: 10,
+			want: true,
+		},
+		{
+			name: "intersections beyond the interval",
+			ray:  r,
+			tMin: 0,
+			tMax: 4,
+			want: false,
+		},
+		{
+			name: "intersections before the interval",
+			ray:  r,
+			tMin: 6,
+			tMax: 10,
+			want: false,
+		},
+		{
+			name: "ray misses the sphere",
+			ray:  NewRay(*point.NewPoint(0, 2, -5), *vector.NewVector(0, 0, 1)),
+			tMin: 0,
+			tMax: 10,
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := *tt.ray
+			SetInterval(&r, tt.tMin, tt.tMax)
+			got, err := IntersectsSphere(&r, s)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRaySphereIntersectInterval(t *testing.T) {
+	tests := []struct {
+		name string
+		tMin float64
+		tMax float64
+		want []float64
+	}{
+		{
+			name: "both intersections within the interval",
+			tMin: 0,
+			tMax: 10,
+			want: []float64{4, 6},
+		},
+		{
+			name: "near intersection before the interval",
+			tMin: 5,
+			tMax: 10,
+			want: []float64{6},
+		},
+		{
+			name: "far intersection beyond the interval",
+			tMin: 0,
+			tMax: 5,
+			want: []float64{4},
+		},
+		{
+			name: "intersections at the bounds of the interval",
+			tMin: 4,
+			tMax: 6,
+			want: []float64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRay(*point.NewPoint(0, 0, -5), *vector.NewVector(0, 0, 1))
+			SetInterval(r, tt.tMin, tt.tMax)
+
+			intersections, err := RaySphereIntersect(r, sphere.NewUnitSphere("testID"))
+			assert.NoError(t, err)
+			assert.Equal(t, len(tt.want), len(intersections))
+			for idx, intersection := range intersections {
+				assert.Equal(t, tt.want[idx], intersection.T)
+			}
+		})
+	}
+}
@@ -2,7 +2,6 @@
 package ray
 
 import (
-	"errors"
 	"github.com/austingebauer/go-ray-tracer/matrix"
 	"github.com/austingebauer/go-ray-tracer/point"
 	"github.com/austingebauer/go-ray-tracer/vector"
@@ -12,9 +11,21 @@ import (
 type Ray struct {
 	Origin    *point.Point
 	Direction *vector.Vector
+
+	// Time is the moment at which the ray is cast. It's used to intersect
+	// the ray with objects that move while a camera's shutter is open.
+	Time float64
+
+	// Bounded is whether TMin and TMax bound the interval of t values along the ray
+	// at which it intersects with objects. When it's set, intersections at t values
+	// that are not greater than TMin and less than TMax are discarded. Rays that
+	// aren't bounded, which includes the zero value, intersect at any t value.
+	Bounded    bool
+	TMin, TMax float64
 }
 
-// NewRay returns a new Ray having the passed origin and direction.
+// NewRay returns a new Ray having the passed origin and direction,
+// which intersects with objects at any t value.
 func NewRay(origin point.Point, direction vector.Vector) *Ray {
 	return &Ray{
 		Origin:    &origin,
@@ -22,6 +33,19 @@ func NewRay(origin point.Point, direction vector.Vector) *Ray {
 	}
 }
 
+// SetInterval bounds the interval of t values along the passed Ray at which it intersects
+// with objects to the values greater than tMin and less than tMax.
+func SetInterval(ray *Ray, tMin, tMax float64) {
+	ray.Bounded = true
+	ray.TMin = tMin
+	ray.TMax = tMax
+}
+
+// InInterval returns true if the passed t value is within the interval of the passed Ray.
+func InInterval(ray *Ray, t float64) bool {
+	return !ray.Bounded || (t > ray.TMin && t < ray.TMax)
+}
+
 // Position returns the Point that lies any distance t along the passed ray.
 func Position(ray *Ray, t float64) *point.Point {
 	// Scale the direction vector by t
@@ -31,22 +55,19 @@ func Position(ray *Ray, t float64) *point.Point {
 	return point.Add(ray.Origin, scaledDirectionVec)
 }
 
-// Transform applies the passed 4x4 transformation Matrix to the passed Ray.
+// Transform applies the passed transformation Matrix4 to the passed Ray.
 // Returns a new Ray with the transformed origin and direction.
-func Transform(ray *Ray, m *matrix.Matrix) (*Ray, error) {
-	if m.GetRows() != 4 || m.GetCols() != 4 {
-		return nil, errors.New("order of matrix m must be 4x4")
+func Transform(ray *Ray, m matrix.Matrix4) Ray {
+	origin := m.MultiplyPoint(*ray.Origin)
+	direction := m.MultiplyVector(*ray.Direction)
+	return Ray{
+		Origin:    &origin,
+		Direction: &direction,
+		Time:      ray.Time,
+		Bounded:   ray.Bounded,
+		TMin:      ray.TMin,
+		TMax:      ray.TMax,
 	}
-
-	// transform the ray origin
-	originMatrix, _ := matrix.Multiply(m, matrix.PointToMatrix(ray.Origin))
-	transformedOriginPoint, _ := matrix.MatrixToPoint(originMatrix)
-
-	// transform the ray direction
-	directionMatrix, _ := matrix.Multiply(m, matrix.VectorToMatrix(ray.Direction))
-	transformedDirectionVector, _ := matrix.MatrixToVector(directionMatrix)
-
-	return NewRay(*transformedOriginPoint, *transformedDirectionVector), nil
 }
 
 // Equals returns true if both of the given rays have the same
@@ -5,6 +5,7 @@ import (
 
 	"github.com/austingebauer/go-ray-tracer/matrix"
 	"github.com/austingebauer/go-ray-tracer/point"
+	"github.com/austingebauer/go-ray-tracer/sphere"
 	"github.com/austingebauer/go-ray-tracer/vector"
 	"github.com/stretchr/testify/assert"
 )
@@ -31,6 +32,7 @@ func TestNewRay(t *testing.T) {
 			r := NewRay(*tt.args.origin, *tt.args.direction)
 			assert.Equal(t, tt.args.origin, r.Origin)
 			assert.Equal(t, tt.args.direction, r.Direction)
+			assert.False(t, r.Bounded)
 		})
 	}
 }
@@ -88,95 +90,70 @@ func TestPosition(t *testing.T) {
 func TestTransform(t *testing.T) {
 	type args struct {
 		ray *Ray
-		m   *matrix.Matrix
+		m   matrix.Matrix4
 	}
 	tests := []struct {
-		name    string
-		args    args
-		want    *Ray
-		wantErr bool
+		name string
+		args args
+		want *Ray
 	}{
 		{
 			name: "transform ray with translation matrix",
 			args: args{
 				ray: NewRay(*point.NewPoint(1, 2, 3), *vector.NewVector(0, 1, 0)),
-				m:   matrix.NewTranslationMatrix(3, 4, 5),
-			},
-			want:    NewRay(*point.NewPoint(4, 6, 8), *vector.NewVector(0, 1, 0)),
-			wantErr: false,
-		},
-		{
-			name: "transform ray with matrix not of 4x4 order for error",
-			args: args{
-				ray: NewRay(*point.NewPoint(1, 2, 3), *vector.NewVector(0, 1, 0)),
-				m:   matrix.NewMatrix(1, 4),
+				m:   matrix.NewTranslationMatrix4(3, 4, 5),
 			},
-			want:    NewRay(*point.NewPoint(4, 6, 8), *vector.NewVector(0, 1, 0)),
-			wantErr: true,
+			want: NewRay(*point.NewPoint(4, 6, 8), *vector.NewVector(0, 1, 0)),
 		},
 		{
 			name: "transform ray with scaling matrix",
 			args: args{
 				ray: NewRay(*point.NewPoint(1, 2, 3), *vector.NewVector(0, 1, 0)),
-				m:   matrix.NewScalingMatrix(2, 3, 4),
+				m:   matrix.NewScalingMatrix4(2, 3, 4),
 			},
-			want:    NewRay(*point.NewPoint(2, 6, 12), *vector.NewVector(0, 3, 0)),
-			wantErr: false,
+			want: NewRay(*point.NewPoint(2, 6, 12), *vector.NewVector(0, 3, 0)),
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := Transform(tt.args.ray, tt.args.m)
-
-			if tt.wantErr {
-				assert.Nil(t, got)
-				assert.Error(t, err)
-			} else {
-				assert.NoError(t, err)
-				assert.Equal(t, tt.want, got)
-			}
+			got := Transform(tt.args.ray, tt.args.m)
+			assert.Equal(t, *tt.want, got)
 		})
 	}
 }
 
-func TestEquals(t *testing.T) {
-	type args struct {
-		r1 *Ray
-		r2 *Ray
-	}
-	tests := []struct {
-		name string
-		args args
-		want bool
-	}{
-		{
-			name: "rays have equal origin and direction",
-			args: args{
-				r1: NewRay(*point.NewPoint(0, 0, 0), *vector.NewVector(1, 0, 0)),
-				r2: NewRay(*point.NewPoint(0, 0, 0), *vector.NewVector(1, 0, 0)),
-			},
-			want: true,
-		},
-		{
-			name: "rays do not have equal origin",
-			args: args{
-				r1: NewRay(*point.NewPoint(1, 0, 0), *vector.NewVector(1, 0, 0)),
-				r2: NewRay(*point.NewPoint(0, 0, 0), *vector.NewVector(1, 0, 0)),
-			},
-			want: false,
-		},
-		{
-			name: "rays do not have equal direction",
-			args: args{
-				r1: NewRay(*point.NewPoint(0, 0, 0), *vector.NewVector(-1, 0, 0)),
-				r2: NewRay(*point.NewPoint(0, 0, 0), *vector.NewVector(1, 0, 0)),
-			},
-			want: false,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			assert.Equal(t, tt.want, Equals(tt.args.r1, tt.args.r2))
-		})
+func TestTransformPreservesTime(t *testing.T) {
+	r := NewRay(*point.NewPoint(1, 2, 3), *vector.NewVector(0, 1, 0))
+	r.Time = 0.75
+	SetInterval(r, 1, 2)
+
+	got := Transform(r, matrix.NewTranslationMatrix4(3, 4, 5))
+	assert.Equal(t, 0.75, got.Time)
+	assert.Equal(t, 1.0, got.TMin)
+	assert.True(t, got.Bounded)
+	assert.Equal(t, 2.0, got.TMax)
+}
+
+func TestInInterval(t *testing.T) {
+	r := NewRay(*point.NewPoint(0, 0, 0), *vector.NewVector(0, 0, 1))
+	assert.True(t, InInterval(r, -1e9))
+	assert.True(t, InInterval(r, 1e9))
+
+	SetInterval(r, 1, 2)
+	assert.False(t, InInterval(r, 1))
+	assert.True(t, InInterval(r, 1.5))
+	assert.False(t, InInterval(r, 2))
+}
+
+func TestRayLiteralIsUnbounded(t *testing.T) {
+	r := &Ray{
+		Origin:    point.NewPoint(0, 0, -5),
+		Direction: vector.NewVector(0, 0, 1),
 	}
+	assert.True(t, InInterval(r, -1e9))
+	assert.True(t, InInterval(r, 0))
+
+	intersections, err := RaySphereIntersect(r, sphere.NewUnitSphere("testID"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(intersections))
 }
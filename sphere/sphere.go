@@ -2,19 +2,63 @@
 package sphere
 
 import (
+	"errors"
+	"fmt"
 	"github.com/austingebauer/go-ray-tracer/material"
 	"github.com/austingebauer/go-ray-tracer/matrix"
 	"github.com/austingebauer/go-ray-tracer/point"
 	"github.com/austingebauer/go-ray-tracer/vector"
+	"sort"
 )
 
 // Sphere is a sphere object with an origin and radius.
+//
+// The origin and radius place the sphere in object space, and the transform
+// of the sphere then places it in world space.
 type Sphere struct {
 	Id        string
 	Origin    *point.Point
 	Radius    float64
-	Transform *matrix.Matrix
+	Transform matrix.Matrix4
 	Material  *material.Material
+
+	// Keyframes animate the transform of the sphere over time.
+	// If there are no keyframes, then the sphere uses its Transform at all times.
+	Keyframes []TransformKeyframe
+
+	// The inverse and inverse-transpose of the Transform, cached by SetTransform
+	cache transformCache
+}
+
+// transformCache holds the inverse and inverse-transpose of a transform,
+// along with the transform that they were computed from.
+type transformCache struct {
+	valid            bool
+	transform        matrix.Matrix4
+	inverse          matrix.Matrix4
+	inverseTranspose matrix.Matrix4
+}
+
+// newTransformCache returns the cached inverse and inverse-transpose of the passed
+// transform. If the passed transform is not invertible, then an error is returned.
+func newTransformCache(transform matrix.Matrix4) (transformCache, error) {
+	inverse, err := transform.Inverse()
+	if err != nil {
+		return transformCache{}, err
+	}
+
+	return transformCache{
+		valid:            true,
+		transform:        transform,
+		inverse:          inverse,
+		inverseTranspose: inverse.Transpose(),
+	}, nil
+}
+
+// TransformKeyframe is the transform of an object at a moment in time.
+type TransformKeyframe struct {
+	Time      float64
+	Transform matrix.Matrix4
 }
 
 // NewUnitSphere returns a new Sphere with id, origin (0,0,0), and a radius of 1.
@@ -24,58 +68,163 @@ func NewUnitSphere(id string) *Sphere {
 
 // NewSphere returns a new Sphere with the passed id, origin, and radius.
 func NewSphere(id string, origin point.Point, radius float64) *Sphere {
+	cache, _ := newTransformCache(matrix.NewIdentityMatrix4())
 	return &Sphere{
 		Id:        id,
 		Origin:    &origin,
 		Radius:    radius,
-		Transform: matrix.NewIdentityMatrix(4),
+		Transform: matrix.NewIdentityMatrix4(),
 		Material:  material.NewDefaultMaterial(),
+		cache:     cache,
 	}
 }
 
-// SetTransform sets the transform of this Sphere.
-func (s *Sphere) SetTransform(m *matrix.Matrix) {
+// SetTransform sets the transform of this Sphere, and caches its inverse and
+// inverse-transpose for intersecting rays with the sphere and computing its normals.
+// If the passed transform is not invertible, then an error is returned and the
+// transform of the sphere is left unchanged.
+//
+// Assigning the Transform field directly invalidates the cache, which makes each
+// use of the transform invert it again.
+func (s *Sphere) SetTransform(m matrix.Matrix4) error {
+	cache, err := newTransformCache(m)
+	if err != nil {
+		return fmt.Errorf("transform of sphere %q is not invertible", s.Id)
+	}
+
 	s.Transform = m
+	s.cache = cache
+	return nil
+}
+
+// SetTransformKeyframes animates the transform of this Sphere using the passed keyframes.
+// The keyframes may be passed in any order, but no two keyframes may have the same time,
+// and the transform of each keyframe must be invertible.
+// Passing no keyframes stops the animation.
+func (s *Sphere) SetTransformKeyframes(keyframes ...TransformKeyframe) error {
+	sorted := make([]TransformKeyframe, len(keyframes))
+	copy(sorted, keyframes)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Time < sorted[j].Time
+	})
+
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Time == sorted[i-1].Time {
+			return errors.New("keyframes must not have the same time")
+		}
+	}
+
+	for _, keyframe := range sorted {
+		if keyframe.Transform.Determinant() == 0 {
+			return fmt.Errorf("transform of sphere %q at time %v is not invertible",
+				s.Id, keyframe.Time)
+		}
+	}
+
+	if len(sorted) == 0 {
+		sorted = nil
+	}
+	s.Keyframes = sorted
+	return nil
+}
+
+// TransformAt returns the transform of this Sphere at the passed time.
+//
+// The transform is linearly interpolated between the keyframes surrounding the passed time.
+// Before the first keyframe and after the last keyframe, the transform is held constant.
+// If the sphere has no keyframes, then its Transform is returned.
+func (s *Sphere) TransformAt(time float64) matrix.Matrix4 {
+	if len(s.Keyframes) == 0 {
+		return s.Transform
+	}
+
+	// Find the first keyframe that is after the passed time
+	next := sort.Search(len(s.Keyframes), func(i int) bool {
+		return s.Keyframes[i].Time > time
+	})
+
+	if next == 0 {
+		return s.Keyframes[0].Transform
+	}
+	if next == len(s.Keyframes) {
+		return s.Keyframes[len(s.Keyframes)-1].Transform
+	}
+
+	prev := s.Keyframes[next-1]
+	t := (time - prev.Time) / (s.Keyframes[next].Time - prev.Time)
+	return matrix.InterpolateMatrix4(prev.Transform, s.Keyframes[next].Transform, t)
+}
+
+// InverseTransformAt returns the inverse and inverse-transpose of the transform of this
+// Sphere at the passed time. If the transform is not invertible, then an error is returned.
+//
+// The inverses of a transform set using SetTransform are cached. The inverses of the
+// transform of an animated sphere are computed each time, because its transform is
+// interpolated between keyframes.
+func (s *Sphere) InverseTransformAt(time float64) (inverse, inverseTranspose matrix.Matrix4,
+	err error) {
+	transform := s.TransformAt(time)
+
+	// Use the cache unless the transform has changed since it was set
+	if s.cache.valid && s.cache.transform == transform {
+		return s.cache.inverse, s.cache.inverseTranspose, nil
+	}
+
+	cache, err := newTransformCache(transform)
+	if err != nil {
+		return matrix.Matrix4{}, matrix.Matrix4{},
+			fmt.Errorf("transform of sphere %q is not invertible", s.Id)
+	}
+
+	return cache.inverse, cache.inverseTranspose, nil
 }
 
 // NormalAt returns the normal vector on the passed Sphere, at the passed Point.
 // The function assumes that the passed Point will always be on the surface of the sphere.
+// For an animated sphere, the normal is computed using its transform at time 0.
 func NormalAt(s *Sphere, worldSpacePoint *point.Point) (*vector.Vector, error) {
-	// Get the inverse of the transform applied to the sphere
-	inverseTransform, err := matrix.Inverse(s.Transform)
-	if err != nil {
-		return nil, err
-	}
+	return NormalAtTime(s, worldSpacePoint, 0)
+}
 
-	// Convert the passed point in world space into a point in object space
-	objectSpacePointM, err := matrix.Multiply(inverseTransform,
-		matrix.PointToMatrix(worldSpacePoint))
+// NormalAtTime returns the normal vector on the passed Sphere, at the passed Point,
+// using the transform of the sphere at the passed time. The function assumes that
+// the passed Point will always be on the surface of the sphere at that time.
+func NormalAtTime(s *Sphere, worldSpacePoint *point.Point, time float64) (*vector.Vector, error) {
+	normalVec, err := NormalVectorAt(s, *worldSpacePoint, time)
 	if err != nil {
 		return nil, err
 	}
-	objectSpacePoint, err := matrix.MatrixToPoint(objectSpacePointM)
+
+	return &normalVec, nil
+}
+
+// NormalVectorAt returns the normal vector on the passed Sphere, at the passed Point,
+// using the transform of the sphere at the passed time, in the same way as NormalAtTime.
+// Unlike NormalAtTime, it returns the normal vector by value, which avoids allocating it.
+func NormalVectorAt(s *Sphere, worldSpacePoint point.Point, time float64) (vector.Vector, error) {
+	// Get the inverse and inverse-transpose of the transform applied to the sphere
+	inverseTransform, transposedInverseTransform, err := s.InverseTransformAt(time)
 	if err != nil {
-		return nil, err
+		return vector.Vector{}, err
 	}
 
-	// Get the normal vector in object space by subtracting the sphere
-	// origin (always point(0,0,0)) from the object space point.
-	objectSpaceNormal := point.Subtract(*objectSpacePoint, *s.Origin).Normalize()
+	// Convert the passed point in world space into a point in object space
+	objectSpacePoint := inverseTransform.MultiplyPoint(worldSpacePoint)
+
+	// Get the normal vector in object space by subtracting the sphere origin from the
+	// object space point, which is a vector as long as the radius of the sphere.
+	objectSpaceNormal := vector.Vector{
+		X: objectSpacePoint.X - s.Origin.X,
+		Y: objectSpacePoint.Y - s.Origin.Y,
+		Z: objectSpacePoint.Z - s.Origin.Z,
+	}
+	objectSpaceNormal.Normalize()
 
 	// Convert the object space normal vector back to world space by multiplying
 	// by the transposed, inverse of the transform applied to the sphere.
-	transposedInverseTransform := matrix.Transpose(*inverseTransform)
-	worldSpaceNormalM, err := matrix.Multiply(transposedInverseTransform,
-		matrix.VectorToMatrix(objectSpaceNormal))
-	if err != nil {
-		return nil, err
-	}
+	worldSpaceNormal := transposedInverseTransform.MultiplyVector(objectSpaceNormal)
 
 	// Normalize and return the world space normal vector
-	worldSpaceNormalVector, err := matrix.MatrixToVector(worldSpaceNormalM)
-	if err != nil {
-		return nil, err
-	}
-
-	return worldSpaceNormalVector.Normalize(), nil
+	worldSpaceNormal.Normalize()
+	return worldSpaceNormal, nil
 }
@@ -23,8 +23,9 @@ func TestNewUnitSphere(t *testing.T) {
 				Id:        "testID",
 				Origin:    point.NewPoint(0, 0, 0),
 				Radius:    1,
-				Transform: matrix.NewIdentityMatrix(4),
+				Transform: matrix.NewIdentityMatrix4(),
 				Material:  material.NewDefaultMaterial(),
+				cache:     identityTransformCache(),
 			},
 		},
 	}
@@ -57,8 +58,9 @@ func TestNewSphere(t *testing.T) {
 				Id:        "testID",
 				Origin:    point.NewPoint(1, 2, -3),
 				Radius:    11,
-				Transform: matrix.NewIdentityMatrix(4),
+				Transform: matrix.NewIdentityMatrix4(),
 				Material:  material.NewDefaultMaterial(),
+				cache:     identityTransformCache(),
 			},
 		},
 	}
@@ -69,38 +71,99 @@ func TestNewSphere(t *testing.T) {
 	}
 }
 
+// identityTransformCache returns the transform cache of a new sphere.
+func identityTransformCache() transformCache {
+	identity := matrix.NewIdentityMatrix4()
+	return transformCache{
+		valid:            true,
+		transform:        identity,
+		inverse:          identity,
+		inverseTranspose: identity,
+	}
+}
+
 func TestSphere_SetTransform(t *testing.T) {
 	type args struct {
-		m *matrix.Matrix
+		m matrix.Matrix4
 	}
 	tests := []struct {
-		name string
-		s    *Sphere
-		args args
-		want *matrix.Matrix
+		name    string
+		s       *Sphere
+		args    args
+		want    matrix.Matrix4
+		wantErr bool
 	}{
 		{
 			name: "set sphere transform",
 			s:    NewUnitSphere("testID"),
 			args: args{
-				m: matrix.NewMatrix(4, 4),
+				m: matrix.NewTranslationMatrix4(1, 2, 3),
 			},
-			want: matrix.NewMatrix(4, 4),
+			want:    matrix.NewTranslationMatrix4(1, 2, 3),
+			wantErr: false,
+		},
+		{
+			name: "set sphere transform that is not invertible for error",
+			s:    NewUnitSphere("testID"),
+			args: args{
+				m: matrix.Matrix4{},
+			},
+			want:    matrix.NewIdentityMatrix4(),
+			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.s.SetTransform(tt.args.m)
+			err := tt.s.SetTransform(tt.args.m)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
 			assert.Equal(t, tt.want, tt.s.Transform)
 		})
 	}
 }
 
+func TestSphere_InverseTransformAt(t *testing.T) {
+	s := NewUnitSphere("testID")
+	assert.NoError(t, s.SetTransform(matrix.NewScalingMatrix4(2, 4, 8)))
+
+	wantInverse := matrix.NewScalingMatrix4(0.5, 0.25, 0.125)
+	inverse, inverseTranspose, err := s.InverseTransformAt(0)
+	assert.NoError(t, err)
+	assert.Equal(t, wantInverse, inverse)
+	assert.Equal(t, wantInverse.Transpose(), inverseTranspose)
+
+	// Assigning the transform directly invalidates the cache
+	s.Transform = s.Transform.Translate(1, 0, 0)
+	wantInverse = matrix.NewTranslationMatrix4(-1, 0, 0).Scale(0.5, 0.25, 0.125)
+	inverse, inverseTranspose, err = s.InverseTransformAt(0)
+	assert.NoError(t, err)
+	assert.True(t, wantInverse.Equals(inverse))
+	assert.True(t, wantInverse.Transpose().Equals(inverseTranspose))
+
+	// Assigning a transform that is not invertible fails when it is used
+	s.Transform = matrix.NewScalingMatrix4(1, 0, 1)
+	_, _, err = s.InverseTransformAt(0)
+	assert.Error(t, err)
+	_, err = NormalAt(s, point.NewPoint(1, 0, 0))
+	assert.Error(t, err)
+
+	// Keyframes must be invertible
+	err = s.SetTransformKeyframes(
+		TransformKeyframe{Time: 0, Transform: matrix.NewIdentityMatrix4()},
+		TransformKeyframe{Time: 1, Transform: matrix.NewScalingMatrix4(0, 0, 0)},
+	)
+	assert.Error(t, err)
+	assert.Nil(t, s.Keyframes)
+}
+
 func TestNormalAt(t *testing.T) {
 	type args struct {
 		s          *Sphere
 		p          *point.Point
-		transforms []*matrix.Matrix
+		transforms []matrix.Matrix4
 	}
 	tests := []struct {
 		name string
@@ -112,7 +175,7 @@ func TestNormalAt(t *testing.T) {
 			args: args{
 				s:          NewUnitSphere("testID"),
 				p:          point.NewPoint(1, 0, 0),
-				transforms: []*matrix.Matrix{},
+				transforms: []matrix.Matrix4{},
 			},
 			want: vector.NewVector(1, 0, 0),
 		},
@@ -121,7 +184,7 @@ func TestNormalAt(t *testing.T) {
 			args: args{
 				s:          NewUnitSphere("testID"),
 				p:          point.NewPoint(0, 1, 0),
-				transforms: []*matrix.Matrix{},
+				transforms: []matrix.Matrix4{},
 			},
 			want: vector.NewVector(0, 1, 0),
 		},
@@ -130,7 +193,7 @@ func TestNormalAt(t *testing.T) {
 			args: args{
 				s:          NewUnitSphere("testID"),
 				p:          point.NewPoint(0, 0, 1),
-				transforms: []*matrix.Matrix{},
+				transforms: []matrix.Matrix4{},
 			},
 			want: vector.NewVector(0, 0, 1),
 		},
@@ -139,7 +202,7 @@ func TestNormalAt(t *testing.T) {
 			args: args{
 				s:          NewUnitSphere("testID"),
 				p:          point.NewPoint(math.Sqrt(3)/3, math.Sqrt(3)/3, math.Sqrt(3)/3),
-				transforms: []*matrix.Matrix{},
+				transforms: []matrix.Matrix4{},
 			},
 			want: vector.NewVector(math.Sqrt(3)/3, math.Sqrt(3)/3, math.Sqrt(3)/3),
 		},
@@ -148,8 +211,8 @@ func TestNormalAt(t *testing.T) {
 			args: args{
 				s: NewUnitSphere("testID"),
 				p: point.NewPoint(0, 1.70711, -0.70711),
-				transforms: []*matrix.Matrix{
-					matrix.NewTranslationMatrix(0, 1, 0),
+				transforms: []matrix.Matrix4{
+					matrix.NewTranslationMatrix4(0, 1, 0),
 				},
 			},
 			want: vector.NewVector(0, 0.70711, -0.70711),
@@ -159,9 +222,9 @@ func TestNormalAt(t *testing.T) {
 			args: args{
 				s: NewUnitSphere("testID"),
 				p: point.NewPoint(0, math.Sqrt(2)/2, -1*math.Sqrt(2)/2),
-				transforms: []*matrix.Matrix{
-					matrix.NewScalingMatrix(1, 0.5, 1),
-					matrix.NewZRotationMatrix(math.Pi / 5),
+				transforms: []matrix.Matrix4{
+					matrix.NewScalingMatrix4(1, 0.5, 1),
+					matrix.NewZRotationMatrix4(math.Pi / 5),
 				},
 			},
 			want: vector.NewVector(0, 0.97014, -0.24254),
@@ -169,15 +232,12 @@ func TestNormalAt(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			var err error
-
 			// Set up the transforms to apply to the sphere
 			transform := tt.args.s.Transform
 			for _, sphereTransform := range tt.args.transforms {
-				transform, _ = matrix.Multiply(transform, sphereTransform)
-				assert.NoError(t, err)
+				transform = transform.Multiply(sphereTransform)
 			}
-			tt.args.s.SetTransform(transform)
+			assert.NoError(t, tt.args.s.SetTransform(transform))
 
 			// Assert that normal vector is what we expect and is normalized
 			normalVector, err := NormalAt(tt.args.s, tt.args.p)
@@ -190,3 +250,208 @@ func TestNormalAt(t *testing.T) {
 		})
 	}
 }
+
+func TestSphere_SetTransformKeyframes(t *testing.T) {
+	type args struct {
+		keyframes []TransformKeyframe
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []TransformKeyframe
+		wantErr bool
+	}{
+		{
+			name: "keyframes are sorted by time",
+			args: args{
+				keyframes: []TransformKeyframe{
+					{Time: 1, Transform: matrix.NewTranslationMatrix4(1, 0, 0)},
+					{Time: 0, Transform: matrix.NewIdentityMatrix4()},
+				},
+			},
+			want: []TransformKeyframe{
+				{Time: 0, Transform: matrix.NewIdentityMatrix4()},
+				{Time: 1, Transform: matrix.NewTranslationMatrix4(1, 0, 0)},
+			},
+			wantErr: false,
+		},
+		{
+			name: "no keyframes stops the animation",
+			args: args{
+				keyframes: []TransformKeyframe{},
+			},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "keyframes with the same time",
+			args: args{
+				keyframes: []TransformKeyframe{
+					{Time: 1, Transform: matrix.NewTranslationMatrix4(1, 0, 0)},
+					{Time: 1, Transform: matrix.NewIdentityMatrix4()},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewUnitSphere("testID")
+			err := s.SetTransformKeyframes(tt.args.keyframes...)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, s.Keyframes)
+			}
+		})
+	}
+}
+
+func TestSphere_TransformAt(t *testing.T) {
+	animated := NewUnitSphere("testID")
+	err := animated.SetTransformKeyframes(
+		TransformKeyframe{Time: 0, Transform: matrix.NewTranslationMatrix4(0, 0, 0)},
+		TransformKeyframe{Time: 1, Transform: matrix.NewTranslationMatrix4(2, 0, 0)},
+		TransformKeyframe{Time: 2, Transform: matrix.NewTranslationMatrix4(2, 4, 0)},
+	)
+	assert.NoError(t, err)
+
+	still := NewUnitSphere("testID")
+	assert.NoError(t, still.SetTransform(matrix.NewScalingMatrix4(2, 2, 2)))
+
+	tests := []struct {
+		name string
+		s    *Sphere
+		time float64
+		want matrix.Matrix4
+	}{
+		{
+			name: "transform before the first keyframe",
+			s:    animated,
+			time: -1,
+			want: matrix.NewTranslationMatrix4(0, 0, 0),
+		},
+		{
+			name: "transform between the first and second keyframes",
+			s:    animated,
+			time: 0.25,
+			want: matrix.NewTranslationMatrix4(0.5, 0, 0),
+		},
+		{
+			name: "transform at the second keyframe",
+			s:    animated,
+			time: 1,
+			want: matrix.NewTranslationMatrix4(2, 0, 0),
+		},
+		{
+			name: "transform between the second and third keyframes",
+			s:    animated,
+			time: 1.5,
+			want: matrix.NewTranslationMatrix4(2, 2, 0),
+		},
+		{
+			name: "transform after the last keyframe",
+			s:    animated,
+			time: 3,
+			want: matrix.NewTranslationMatrix4(2, 4, 0),
+		},
+		{
+			name: "transform of a sphere without keyframes",
+			s:    still,
+			time: 0.5,
+			want: matrix.NewScalingMatrix4(2, 2, 2),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.True(t, tt.want.Equals(tt.s.TransformAt(tt.time)))
+		})
+	}
+}
+
+func TestNormalAtTime(t *testing.T) {
+	s := NewUnitSphere("testID")
+	err := s.SetTransformKeyframes(
+		TransformKeyframe{Time: 0, Transform: matrix.NewTranslationMatrix4(0, 0, 0)},
+		TransformKeyframe{Time: 1, Transform: matrix.NewTranslationMatrix4(0, 2, 0)},
+	)
+	assert.NoError(t, err)
+
+	// At time 1, the point (0, 1, 0) is on the bottom of the sphere
+	normalVector, err := NormalAtTime(s, point.NewPoint(0, 1, 0), 1)
+	assert.NoError(t, err)
+	assert.True(t, vector.NewVector(0, -1, 0).Equals(normalVector))
+
+	// At time 0, the point (0, 1, 0) is on the top of the sphere
+	normalVector, err = NormalAt(s, point.NewPoint(0, 1, 0))
+	assert.NoError(t, err)
+	assert.True(t, vector.NewVector(0, 1, 0).Equals(normalVector))
+}
+
+func TestNormalAtWithOriginAndRadius(t *testing.T) {
+	type args struct {
+		origin    *point.Point
+		radius    float64
+		transform matrix.Matrix4
+		p         *point.Point
+	}
+	tests := []struct {
+		name string
+		args args
+		want *vector.Vector
+	}{
+		{
+			name: "normal on a sphere with a radius of 2",
+			args: args{
+				origin:    point.NewPoint(0, 0, 0),
+				radius:    2,
+				transform: matrix.NewIdentityMatrix4(),
+				p:         point.NewPoint(0, 2, 0),
+			},
+			want: vector.NewVector(0, 1, 0),
+		},
+		{
+			name: "normal on a sphere away from the world origin",
+			args: args{
+				origin:    point.NewPoint(1, 2, 3),
+				radius:    3,
+				transform: matrix.NewIdentityMatrix4(),
+				p:         point.NewPoint(1+math.Sqrt(3), 2+math.Sqrt(3), 3+math.Sqrt(3)),
+			},
+			want: vector.NewVector(math.Sqrt(3)/3, math.Sqrt(3)/3, math.Sqrt(3)/3),
+		},
+		{
+			name: "normal on a translated sphere away from the world origin",
+			args: args{
+				origin:    point.NewPoint(0, 0, 5),
+				radius:    0.5,
+				transform: matrix.NewTranslationMatrix4(0, 1, 0),
+				p:         point.NewPoint(-0.5, 1, 5),
+			},
+			want: vector.NewVector(-1, 0, 0),
+		},
+		{
+			name: "normal on a scaled sphere away from the world origin",
+			args: args{
+				origin:    point.NewPoint(1, 0, 0),
+				radius:    2,
+				transform: matrix.NewScalingMatrix4(1, 0.5, 1),
+				p:         point.NewPoint(1, 1, 0),
+			},
+			want: vector.NewVector(0, 1, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSphere("testID", *tt.args.origin, tt.args.radius)
+			assert.NoError(t, s.SetTransform(tt.args.transform))
+
+			normalVector, err := NormalAt(s, tt.args.p)
+			assert.NoError(t, err)
+			if !assert.True(t, tt.want.Equals(normalVector)) {
+				assert.Equal(t, tt.want, normalVector)
+			}
+		})
+	}
+}
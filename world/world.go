@@ -5,11 +5,13 @@ import (
 	"github.com/austingebauer/go-ray-tracer/color"
 	"github.com/austingebauer/go-ray-tracer/light"
 	"github.com/austingebauer/go-ray-tracer/material"
+	"github.com/austingebauer/go-ray-tracer/maths"
 	"github.com/austingebauer/go-ray-tracer/matrix"
 	"github.com/austingebauer/go-ray-tracer/point"
 	"github.com/austingebauer/go-ray-tracer/ray"
 	"github.com/austingebauer/go-ray-tracer/sphere"
 	"github.com/austingebauer/go-ray-tracer/vector"
+	"sync"
 )
 
 // World represents a collection of all Objects that make up a scene.
@@ -41,7 +43,7 @@ func NewDefaultWorld() *World {
 
 	// Create a default sphere number 2
 	s2 := sphere.NewUnitSphere("s2")
-	s2.Transform = matrix.NewScalingMatrix(0.5, 0.5, 0.5)
+	_ = s2.SetTransform(matrix.NewScalingMatrix4(0.5, 0.5, 0.5))
 
 	return &World{
 		Objects: []*sphere.Sphere{s1, s2},
@@ -49,68 +51,263 @@ func NewDefaultWorld() *World {
 	}
 }
 
-// RayWorldIntersect intersects the passed ray with the passed world.
-func RayWorldIntersect(r *ray.Ray, w *World) []*ray.Intersection {
-	allObjectIntersections := make([]*ray.Intersection, 0)
-	for _, sphereObj := range w.Objects {
-		intersections := ray.RaySphereIntersect(r, sphereObj)
-		allObjectIntersections = append(allObjectIntersections, intersections...)
+// Buffer holds the intersections and computations of the rays that are cast into a world,
+// so that each ray reuses them rather than allocating its own. A Buffer must not be used
+// by more than one goroutine at a time, so each goroutine that casts rays, such as each
+// worker of a render, should use its own Buffer.
+type Buffer struct {
+	intersections []ray.Intersection
+	comps         ray.IntersectionComputations
+
+	// The shadow ray and the point and vector that it refers to
+	shadowRay       ray.Ray
+	shadowOrigin    point.Point
+	shadowDirection vector.Vector
+}
+
+// NewBuffer returns a new, empty Buffer.
+func NewBuffer() *Buffer {
+	buf := &Buffer{}
+	buf.shadowRay = ray.Ray{
+		Origin:    &buf.shadowOrigin,
+		Direction: &buf.shadowDirection,
+	}
+	return buf
+}
+
+// buffers pools the Buffers used by the functions that aren't passed a Buffer.
+var buffers = sync.Pool{
+	New: func() interface{} {
+		return NewBuffer()
+	},
+}
+
+// RayWorldIntersect intersects the passed ray with the passed world, and returns
+// the intersections within the interval of the ray sorted in ascending order.
+// If the transform of an object in the world is not invertible, then an error is returned.
+func RayWorldIntersect(r *ray.Ray, w *World) ([]*ray.Intersection, error) {
+	xs, err := IntersectWorld(r, w, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	allObjectIntersections := make([]*ray.Intersection, len(xs))
+	for idx := range xs {
+		allObjectIntersections[idx] = &xs[idx]
 	}
 
 	// Sort the entire collection of intersections
 	ray.SortIntersectionsAsc(allObjectIntersections)
 
-	return allObjectIntersections
+	return allObjectIntersections, nil
+}
+
+// IntersectWorld intersects the passed ray with the passed world in the same way as
+// RayWorldIntersect, but appends the intersections to the passed buffer, in no particular
+// order, and returns the extended buffer. Use ray.HitIndex to find the hit.
+func IntersectWorld(r *ray.Ray, w *World, buf []ray.Intersection) ([]ray.Intersection, error) {
+	var err error
+	for _, sphereObj := range w.Objects {
+		buf, err = ray.IntersectSphere(r, sphereObj, buf)
+		if err != nil {
+			return buf, err
+		}
+	}
+
+	return buf, nil
 }
 
 // ColorAt intersects the given ray with the given world and
 // returns the color at the resulting intersection.
 func ColorAt(w *World, r *ray.Ray) (*color.Color, error) {
-	intersections := RayWorldIntersect(r, w)
-	hit := ray.Hit(intersections)
-	if hit == nil {
-		return color.NewColor(0, 0, 0), nil
+	buf := buffers.Get().(*Buffer)
+	defer buffers.Put(buf)
+
+	return ColorAtWithBuffer(w, r, buf)
+}
+
+// ColorAtWithBuffer returns the color at the intersection of the given ray
+// with the given world in the same way as ColorAt, using the passed Buffer
+// for the intersections and computations of the ray.
+func ColorAtWithBuffer(w *World, r *ray.Ray, buf *Buffer) (*color.Color, error) {
+	clr, _, err := ColorAtWithCoverage(w, r, buf)
+	return clr, err
+}
+
+// ColorAtWithCoverage returns the color at the intersection of the given ray with the given
+// world in the same way as ColorAtWithBuffer, along with whether the ray hit an object.
+// A ray that misses every object is black, and its coverage lets the background be made
+// transparent instead.
+func ColorAtWithCoverage(w *World, r *ray.Ray, buf *Buffer) (*color.Color, bool, error) {
+	result := &TraceResult{}
+	err := TraceWithBuffer(w, r, buf, result)
+	if err != nil {
+		return nil, false, err
 	}
 
-	comps, err := ray.PrepareComputations(hit, r)
+	return &result.Color, result.Hit, nil
+}
+
+// TraceResult holds the color of a ray cast into a world, along with what the ray hit,
+// which renders use for their arbitrary output variables (AOVs). If the ray missed every
+// object, then every value of the result is zero.
+type TraceResult struct {
+	// The color at the intersection of the ray with the world
+	Color color.Color
+	// Whether the ray hit an object
+	Hit bool
+	// The units along the ray at which it hit the object
+	T float64
+	// The point at which the ray hit the object
+	Point point.Point
+	// The normal vector on the surface of the object at the point, facing the ray
+	Normal vector.Vector
+	// The object that the ray hit
+	Object *sphere.Sphere
+	// The color of the material of the object
+	Albedo color.Color
+	// The contributions of the Phong reflection model to the Color
+	Shading light.Shading
+	// Whether the point is in the shadow of another object
+	InShadow bool
+}
+
+// TraceWithBuffer casts the given ray into the given world and fills the passed result with
+// the color at its intersection and what it hit, using the passed Buffer for the intersections
+// and computations of the ray. The color is the same as the color returned by ColorAt.
+func TraceWithBuffer(w *World, r *ray.Ray, buf *Buffer, result *TraceResult) error {
+	*result = TraceResult{}
+
+	var err error
+	buf.intersections, err = IntersectWorld(r, w, buf.intersections[:0])
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return ShadeHit(w, comps), nil
+	hit := ray.HitIndex(buf.intersections)
+	if hit == -1 {
+		return nil
+	}
+
+	comps := &buf.comps
+	err = ray.PrepareComputationsInto(&buf.intersections[hit], r, comps)
+	if err != nil {
+		return err
+	}
+
+	result.Shading, result.InShadow, err = shadeHitComponents(w, comps, buf)
+	if err != nil {
+		return err
+	}
+
+	result.Color = result.Shading.Total()
+	result.Hit = true
+	result.T = comps.T
+	result.Point = *comps.Point
+	result.Normal = *comps.NormalVec
+	result.Object = comps.Object
+	result.Albedo = comps.Object.Material.Color
+	return nil
 }
 
 // ShadeHit returns the color at the intersection encapsulated by
 // an intersections computations.
-func ShadeHit(w *World, comps *ray.IntersectionComputations) *color.Color {
-	isShadowed := IsShadowed(w, comps.OverPoint)
+func ShadeHit(w *World, comps *ray.IntersectionComputations) (*color.Color, error) {
+	buf := buffers.Get().(*Buffer)
+	defer buffers.Put(buf)
+
+	return shadeHit(w, comps, buf)
+}
+
+// shadeHit returns the color at the intersection encapsulated by an intersections
+// computations, using the passed Buffer to determine whether it's in shadow.
+func shadeHit(w *World, comps *ray.IntersectionComputations, buf *Buffer) (*color.Color, error) {
+	shading, _, err := shadeHitComponents(w, comps, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	clr := shading.Total()
+	return &clr, nil
+}
+
+// shadeHitComponents returns the components of the light reflected at the intersection
+// encapsulated by an intersections computations, and whether it's in shadow, using the
+// passed Buffer to determine whether it's in shadow. Both ShadeHit and the traces of
+// TraceWithBuffer shade intersections with it, so that they always agree.
+func shadeHitComponents(w *World, comps *ray.IntersectionComputations,
+	buf *Buffer) (light.Shading, bool, error) {
+	inShadow, err := isShadowedAtTime(w, comps.Point, comps.Time, buf)
+	if err != nil {
+		return light.Shading{}, false, err
+	}
 
-	return light.Lighting(
+	return light.LightingComponents(
 		comps.Object.Material,
 		w.Light,
 		comps.Point,
 		comps.EyeVec,
 		comps.NormalVec,
-		isShadowed)
+		inShadow), inShadow, nil
 }
 
 // IsShadowed returns true if the passed point lies in
 // the shadow of an object in the passed world.
-func IsShadowed(world *World, pt *point.Point) bool {
+func IsShadowed(world *World, pt *point.Point) (bool, error) {
+	return IsShadowedAtTime(world, pt, 0)
+}
+
+// IsShadowedAtTime returns true if the passed point lies in the shadow of
+// an object in the passed world, with the objects placed where they are
+// at the passed time.
+func IsShadowedAtTime(world *World, pt *point.Point, time float64) (bool, error) {
+	buf := buffers.Get().(*Buffer)
+	defer buffers.Put(buf)
+
+	return isShadowedAtTime(world, pt, time, buf)
+}
+
+// isShadowedAtTime returns true if the passed point lies in the shadow of an object in the
+// passed world at the passed time, using the passed Buffer for the shadow ray.
+func isShadowedAtTime(world *World, pt *point.Point, time float64, buf *Buffer) (bool, error) {
 	// Create a ray from the point in question to the light source
-	vec := point.Subtract(world.Light.Position, *pt)
-	vecNormal := vector.Normalize(*vec)
-	shadowRay := ray.NewRay(*pt, *vecNormal)
+	vec := vector.Vector{
+		X: world.Light.Position.X - pt.X,
+		Y: world.Light.Position.Y - pt.Y,
+		Z: world.Light.Position.Z - pt.Z,
+	}
 
 	// Compute the distance from the point in question to the light source
 	distanceToLight := vec.Magnitude()
 
-	// Intersect the shadow ray with the world
-	intersections := RayWorldIntersect(shadowRay, world)
+	// The shadow ray's interval starts just past the point, so that an object doesn't
+	// shadow itself, and ends at the light, so that objects beyond it don't cast shadows.
+	buf.shadowOrigin = *pt
+	buf.shadowDirection = vec
+	buf.shadowDirection.Normalize()
+	buf.shadowRay.Time = time
+	ray.SetInterval(&buf.shadowRay, maths.Epsilon, distanceToLight)
+
+	// The point is in shadow if anything lies between it and the light
+	return IsOccluded(world, &buf.shadowRay)
+}
 
-	// Check to see if there way a hit
-	h := ray.Hit(intersections)
+// IsOccluded returns true if the passed ray intersects with an object in the passed world
+// that casts shadows within the interval of the ray. Unlike RayWorldIntersect, it returns
+// as soon as it finds such an intersection, which makes it suitable for shadow rays.
+// Objects whose material has NoShadow set are ignored.
+// If the transform of an object in the world is not invertible, then an error is returned.
+func IsOccluded(w *World, r *ray.Ray) (bool, error) {
+	for _, sphereObj := range w.Objects {
+		if sphereObj.Material != nil && sphereObj.Material.NoShadow {
+			continue
+		}
+
+		occluded, err := ray.IntersectsSphere(r, sphereObj)
+		if err != nil || occluded {
+			return occluded, err
+		}
+	}
 
-	// Return true if there was a hit that's t value is less than the distance to the light
-	return h != nil && h.T < distanceToLight
+	return false, nil
 }
@@ -2,6 +2,7 @@
 package world
 
 import (
+	"github.com/austingebauer/go-ray-tracer/maths"
 	"github.com/austingebauer/go-ray-tracer/matrix"
 	"github.com/austingebauer/go-ray-tracer/ray"
 	"github.com/austingebauer/go-ray-tracer/vector"
@@ -126,7 +127,8 @@ func TestRayWorldIntersect(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actualIntersections := RayWorldIntersect(tt.args.r, tt.args.w)
+			actualIntersections, err := RayWorldIntersect(tt.args.r, tt.args.w)
+			assert.NoError(t, err)
 			assert.Equal(t, len(tt.want), len(actualIntersections))
 
 			// Each actual intersection T value matches the expected T value
@@ -144,7 +146,8 @@ func TestShadeHitComingFromOutside(t *testing.T) {
 	i := ray.NewIntersection(4, shape)
 	comps, err := ray.PrepareComputations(i, r)
 	assert.NoError(t, err)
-	cActual := ShadeHit(w, comps)
+	cActual, err := ShadeHit(w, comps)
+	assert.NoError(t, err)
 
 	cExpected := color.NewColor(0.38066, 0.047583, 0.2855)
 	if !assert.True(t, color.Equals(*cExpected, *cActual)) {
@@ -162,7 +165,8 @@ func TestShadeHitComingFromInside(t *testing.T) {
 	i := ray.NewIntersection(0.5, shape)
 	comps, err := ray.PrepareComputations(i, r)
 	assert.NoError(t, err)
-	cActual := ShadeHit(w, comps)
+	cActual, err := ShadeHit(w, comps)
+	assert.NoError(t, err)
 
 	cExpected := color.NewColor(0.90498, 0.90498, 0.90498)
 	if !assert.True(t, color.Equals(*cExpected, *cActual)) {
@@ -178,7 +182,7 @@ func TestShadeHitInShadow(t *testing.T) {
 	s1 := sphere.NewUnitSphere("s1")
 	w.Objects = append(w.Objects, s1)
 	s2 := sphere.NewUnitSphere("s2")
-	s2.SetTransform(matrix.NewTranslationMatrix(0, 0, 10))
+	assert.NoError(t, s2.SetTransform(matrix.NewTranslationMatrix4(0, 0, 10)))
 	w.Objects = append(w.Objects, s2)
 
 	r := ray.NewRay(
@@ -189,7 +193,8 @@ func TestShadeHitInShadow(t *testing.T) {
 	assert.NoError(t, err)
 
 	cExpected := color.NewColor(0.1, 0.1, 0.1)
-	cActual := ShadeHit(w, comps)
+	cActual, err := ShadeHit(w, comps)
+	assert.NoError(t, err)
 	if !assert.True(t, color.Equals(*cActual, *cExpected)) {
 		assert.Equal(t, cExpected, cActual)
 	}
@@ -201,9 +206,10 @@ func TestColorAt(t *testing.T) {
 		r *ray.Ray
 	}
 	tests := []struct {
-		name string
-		args args
-		want *color.Color
+		name    string
+		args    args
+		want    *color.Color
+		wantHit bool
 	}{
 		{
 			name: "color when a ray misses the world",
@@ -223,9 +229,11 @@ func TestColorAt(t *testing.T) {
 					*point.NewPoint(0, 0, -5),
 					*vector.NewVector(0, 0, 1)),
 			},
-			want: color.NewColor(0.38066, 0.047583, 0.2855),
+			want:    color.NewColor(0.38066, 0.047583, 0.2855),
+			wantHit: true,
 		},
 	}
+	buf := NewBuffer()
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c, err := ColorAt(tt.args.w, tt.args.r)
@@ -233,10 +241,46 @@ func TestColorAt(t *testing.T) {
 			if !assert.True(t, color.Equals(*tt.want, *c)) {
 				assert.Equal(t, tt.want, c)
 			}
+
+			// The same buffer is reused for each ray
+			c, err = ColorAtWithBuffer(tt.args.w, tt.args.r, buf)
+			assert.NoError(t, err)
+			if !assert.True(t, color.Equals(*tt.want, *c)) {
+				assert.Equal(t, tt.want, c)
+			}
+
+			// The coverage of the ray tells whether it hit an object
+			c, hit, err := ColorAtWithCoverage(tt.args.w, tt.args.r, buf)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantHit, hit)
+			assert.True(t, color.Equals(*tt.want, *c))
 		})
 	}
 }
 
+func TestIntersectWorld(t *testing.T) {
+	w := NewDefaultWorld()
+	r := ray.NewRay(*point.NewPoint(0, 0, -5), *vector.NewVector(0, 0, 1))
+
+	xs, err := IntersectWorld(r, w, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(xs))
+	assert.Equal(t, 4.0, xs[ray.HitIndex(xs)].T)
+
+	// A buffer with room for the intersections is reused without allocating
+	allocs := testing.AllocsPerRun(100, func() {
+		xs, _ = IntersectWorld(r, w, xs[:0])
+	})
+	assert.Equal(t, 0.0, allocs)
+
+	// An object whose transform is not invertible fails the intersection
+	w.Objects[1].Transform = matrix.NewScalingMatrix4(0, 0, 0)
+	_, err = IntersectWorld(r, w, xs[:0])
+	assert.Error(t, err)
+	_, err = ColorAt(w, r)
+	assert.Error(t, err)
+}
+
 // This test case shows that we expect ColorAt() to use the hit
 // when computing the color. In this test, we put the ray inside
 // of the outer sphere, and pointing at the inner sphere. We expect
@@ -304,7 +348,166 @@ func TestIsShadowed(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			assert.Equal(t, tt.want, IsShadowed(tt.args.world, tt.args.pt))
+			isShadowed, err := IsShadowed(tt.args.world, tt.args.pt)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, isShadowed)
+		})
+	}
+}
+
+func TestIsShadowedAtTime(t *testing.T) {
+	// The blocker moves between the point and the light at time 1
+	w := NewWorld()
+	w.Light = light.NewPointLight(*point.NewPoint(0, 10, 0), *color.NewColor(1, 1, 1))
+	blocker := sphere.NewUnitSphere("blocker")
+	assert.NoError(t, blocker.SetTransformKeyframes(
+		sphere.TransformKeyframe{Time: 0, Transform: matrix.NewTranslationMatrix4(5, 5, 0)},
+		sphere.TransformKeyframe{Time: 1, Transform: matrix.NewTranslationMatrix4(0, 5, 0)},
+	))
+	w.Objects = append(w.Objects, blocker)
+
+	isShadowed, err := IsShadowedAtTime(w, point.NewPoint(0, 0, 0), 0)
+	assert.NoError(t, err)
+	assert.False(t, isShadowed)
+	isShadowed, err = IsShadowedAtTime(w, point.NewPoint(0, 0, 0), 1)
+	assert.NoError(t, err)
+	assert.True(t, isShadowed)
+	isShadowed, err = IsShadowed(w, point.NewPoint(0, 0, 0))
+	assert.NoError(t, err)
+	assert.False(t, isShadowed)
+}
+
+func TestIsOccluded(t *testing.T) {
+	w := NewDefaultWorld()
+	r := ray.NewRay(*point.NewPoint(0, 0, -5), *vector.NewVector(0, 0, 1))
+
+	tests := []struct {
+		name     string
+		distance float64
+		noShadow []bool
+		want     bool
+	}{
+		{
+			name:     "object before the distance",
+			distance: 10,
+			noShadow: []bool{false, false},
+			want:     true,
+		},
+		{
+			name:     "objects beyond the distance",
+			distance: 3.5,
+			noShadow: []bool{false, false},
+			want:     false,
+		},
+		{
+			name:     "inner object casts shadows when the outer object doesn't",
+			distance: 10,
+			noShadow: []bool{true, false},
+			want:     true,
+		},
+		{
+			name:     "no objects cast shadows",
+			distance: 10,
+			noShadow: []bool{true, true},
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for idx, noShadow := range tt.noShadow {
+				w.Objects[idx].Material.NoShadow = noShadow
+			}
+
+			ray.SetInterval(r, maths.Epsilon, tt.distance)
+			occluded, err := IsOccluded(w, r)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, occluded)
 		})
 	}
 }
+
+func TestShadeHitWithoutShadowCaster(t *testing.T) {
+	// The same scene as TestShadeHitInShadow, but the blocker doesn't cast shadows
+	w := NewWorld()
+	w.Light = light.NewPointLight(
+		*point.NewPoint(0, 0, -10),
+		*color.NewColor(1, 1, 1))
+	s1 := sphere.NewUnitSphere("s1")
+	s1.Material.NoShadow = true
+	w.Objects = append(w.Objects, s1)
+	s2 := sphere.NewUnitSphere("s2")
+	assert.NoError(t, s2.SetTransform(matrix.NewTranslationMatrix4(0, 0, 10)))
+	w.Objects = append(w.Objects, s2)
+
+	r := ray.NewRay(
+		*point.NewPoint(0, 0, 5),
+		*vector.NewVector(0, 0, 1))
+	i := ray.NewIntersection(4, s2)
+	comps, err := ray.PrepareComputations(i, r)
+	assert.NoError(t, err)
+
+	cExpected := color.NewColor(1.9, 1.9, 1.9)
+	cActual, err := ShadeHit(w, comps)
+	assert.NoError(t, err)
+	if !assert.True(t, color.Equals(*cActual, *cExpected)) {
+		assert.Equal(t, cExpected, cActual)
+	}
+}
+
+func TestRayWorldIntersectInterval(t *testing.T) {
+	// Clip the intersections with the outer sphere of the default world
+	r := ray.NewRay(*point.NewPoint(0, 0, -5), *vector.NewVector(0, 0, 1))
+	ray.SetInterval(r, 4.25, 5.75)
+
+	intersections, err := RayWorldIntersect(r, NewDefaultWorld())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(intersections))
+	assert.Equal(t, 4.5, intersections[0].T)
+	assert.Equal(t, 5.5, intersections[1].T)
+}
+
+func TestTraceWithBuffer(t *testing.T) {
+	w := NewDefaultWorld()
+	buf := NewBuffer()
+
+	// A ray that misses the world has an empty result
+	result := &TraceResult{Hit: true, T: 1}
+	err := TraceWithBuffer(w, ray.NewRay(*point.NewPoint(0, 0, -5), *vector.NewVector(0, 1, 0)),
+		buf, result)
+	assert.NoError(t, err)
+	assert.Equal(t, TraceResult{}, *result)
+
+	// A ray that hits the world describes the hit, and has the same color as ColorAt
+	r := ray.NewRay(*point.NewPoint(0, 0, -5), *vector.NewVector(0, 0, 1))
+	err = TraceWithBuffer(w, r, buf, result)
+	assert.NoError(t, err)
+	assert.True(t, result.Hit)
+	assert.Equal(t, 4.0, result.T)
+	assert.True(t, point.NewPoint(0, 0, -1).Equals(&result.Point))
+	assert.True(t, vector.NewVector(0, 0, -1).Equals(&result.Normal))
+	assert.Equal(t, w.Objects[0], result.Object)
+	assert.Equal(t, w.Objects[0].Material.Color, result.Albedo)
+	assert.False(t, result.InShadow)
+	assert.True(t, color.Equals(result.Shading.Total(), result.Color))
+
+	c, err := ColorAt(w, r)
+	assert.NoError(t, err)
+	assert.True(t, color.Equals(*c, result.Color))
+
+	// A point in shadow only has ambient light
+	w.Light.Position = *point.NewPoint(0, 0, 10)
+	err = TraceWithBuffer(w, r, buf, result)
+	assert.NoError(t, err)
+	assert.True(t, result.InShadow)
+	assert.Equal(t, color.Color{}, result.Shading.Diffuse)
+	assert.Equal(t, color.Color{}, result.Shading.Specular)
+
+	// Traces shade hits in the same way as ShadeHit
+	intersections, err := RayWorldIntersect(r, w)
+	assert.NoError(t, err)
+	comps, err := ray.PrepareComputations(ray.Hit(intersections), r)
+	assert.NoError(t, err)
+	c, err = ShadeHit(w, comps)
+	assert.NoError(t, err)
+	assert.True(t, color.Equals(*c, result.Color))
+}